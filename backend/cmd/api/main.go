@@ -1,6 +1,7 @@
 package main
 
 import (
+    "context"
     "log"
     "net/http"
     "time"
@@ -13,11 +14,21 @@ import (
     "telegraph/internal/acl"
 	"telegraph/internal/audit"
     "telegraph/internal/auth"
+    "telegraph/internal/backup"
+    "telegraph/internal/bots"
     "telegraph/internal/channels"
+    "telegraph/internal/commands"
     "telegraph/internal/config"
     "telegraph/internal/database"
+    "telegraph/internal/devices"
+    "telegraph/internal/groupkeys"
+    "telegraph/internal/keys"
+    "telegraph/internal/legalhold"
+    "telegraph/internal/moderation"
 	"telegraph/internal/messages"
     "telegraph/internal/users"
+    "telegraph/internal/webauthn"
+    "telegraph/internal/webhooks"
     "telegraph/internal/ws"
     mw "telegraph/internal/middleware"
 )
@@ -44,35 +55,113 @@ func main() {
 
 	// Repos
 	userRepo := users.NewMongoUserRepo(db)
+	verificationRepo := users.NewMongoVerificationRepo(db)
 	refreshRepo := auth.NewRefreshTokenRepo(db)
 	mfaRepo := auth.NewMFACodeRepo(db)
+	mfaChallengeRepo := auth.NewMFAChallengeRepo(db)
+	totpRepo := auth.NewTOTPRepo(db)
+	passwordResetRepo := auth.NewPasswordResetRepo(db)
+	webauthnRepo := webauthn.NewMongoWebAuthnRepo(db)
 	channelRepo := channels.NewMongoChannelRepo(db)
 	messageRepo := messages.NewMongoMessageRepo(db)
+	dataKeyRepo := messages.NewMongoDataKeyRepo(db)
+	holdRepo := legalhold.NewMongoHoldRepo(db)
+	if err := legalhold.EnsureIndexes(context.Background(), db); err != nil {
+		log.Fatal("Failed to create legal hold indexes:", err)
+	}
+	reportRepo := moderation.NewMongoReportRepo(db)
+	botTokenRepo := bots.NewMongoTokenRepo(db)
+	webhookSubRepo := webhooks.NewMongoSubscriptionRepo(db)
+	webhookDeliveryRepo := webhooks.NewMongoDeliveryRepo(db)
+	incomingHookRepo := webhooks.NewMongoIncomingHookRepo(db)
+	botCommandRepo := commands.NewMongoBotCommandRepo(db)
+	pollRepo := commands.NewMongoPollRepo(db)
+	keyRepo := keys.NewMongoKeyRepo(db)
+	groupKeyRepo := groupkeys.NewMongoKeyRepo(db)
+	backupRepo := backup.NewMongoBackupRepo(db)
+	deviceRepo := devices.NewMongoDeviceRepo(db)
 
 	// Utils & Managers
 	jwtMgr := users.NewJWTManager(cfg.JWTSecret, time.Hour*1)
-	refreshMgr := auth.NewRefreshTokenManager(refreshRepo, time.Hour*24*7)
+	if cfg.JWTKeysDir != "" {
+		keyRing, err := users.LoadKeyRing(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+		if err != nil {
+			log.Fatal("Failed to load JWT signing keys:", err)
+		}
+		if cfg.JWTSecret != "" {
+			keyRing.AcceptHMACSecret(cfg.JWTSecret)
+			log.Println("JWT_SECRET is set; still accepting access tokens signed with it")
+		}
+		jwtMgr = users.NewJWTManagerWithKeys(keyRing, time.Hour*1)
+	} else {
+		log.Println("JWT_KEYS_DIR not set; signing access tokens with the shared JWT_SECRET")
+	}
 	smtpSender := auth.NewSMTPSender(cfg.SMTPEmail, cfg.SMTPPassword, cfg.SMTPHost, cfg.SMTPPort)
-	mfaMgr := auth.NewMFAManager(mfaRepo, smtpSender)
+	totpMgr := auth.NewTOTPManager(totpRepo, "Telegraph")
+	mfaMgr := auth.NewMFAManager(mfaRepo, mfaChallengeRepo, totpMgr, smtpSender)
 	auditLogger := audit.NewLogger(db)
 
 	// WebSocket Hub
 	hub := ws.NewHub()
 	go hub.Run()
 
+	// Bots that long-poll get the same events through the update queue
+	botQueue := bots.NewUpdateQueue()
+	relay := bots.NewRelay(hub, botQueue)
+
+	// Refresh token reuse revokes all sessions and warns the user
+	refreshMgr := auth.NewRefreshTokenManager(refreshRepo, time.Hour*24*7, userRepo, smtpSender, relay, auditLogger)
+
+	// Outgoing webhooks (audit events go to global subscriptions)
+	dispatcher := webhooks.NewDispatcher(webhookSubRepo, webhookDeliveryRepo, nil)
+	auditLogger.AddHook(dispatcher.PublishAudit)
+	go dispatcher.Run(context.Background())
+
 	// Services
-	userSvc := users.NewUserService(userRepo)
-	channelSvc := channels.NewChannelService(channelRepo, userRepo, auditLogger)
-	messageSvc := messages.NewMessageService(messageRepo, channelRepo, auditLogger, hub)
+	holdSvc := legalhold.NewHoldService(holdRepo, userRepo, channelRepo, auditLogger)
+	userSvc := users.NewUserService(userRepo, holdSvc)
+	verificationSvc := users.NewVerificationService(verificationRepo, userRepo, smtpSender, auditLogger)
+	resetMgr := auth.NewPasswordResetManager(passwordResetRepo, userSvc, refreshMgr, smtpSender, auditLogger)
+	loginGuard := auth.NewLoginGuard(auditLogger)
+	channelSvc := channels.NewChannelService(channelRepo, userRepo, auditLogger, holdSvc, relay, dispatcher)
+	keySvc := keys.NewKeyService(keyRepo, userRepo, channelRepo, relay, auditLogger)
+	dataKeys := messages.NewDataKeyManager(dataKeyRepo, cfg.MasterEncryptionKey)
+	if cfg.MasterEncryptionKey == nil {
+		log.Println("MASTER_ENCRYPTION_KEY not set; server-managed channels cannot store messages")
+	}
+	messageSvc := messages.NewMessageService(messageRepo, channelRepo, auditLogger, relay, holdSvc, dispatcher, keySvc, dataKeys)
+	moderationSvc := moderation.NewModerationService(reportRepo, messageRepo, messageSvc, channelRepo, channelSvc, userSvc, refreshMgr, auditLogger)
+	webhookSvc := webhooks.NewWebhookService(webhookSubRepo, webhookDeliveryRepo, dispatcher, channelRepo, auditLogger)
+	incomingSvc := webhooks.NewIncomingService(incomingHookRepo, messageSvc, channelRepo, auditLogger)
+	commandSvc := commands.NewCommandService(botCommandRepo, pollRepo, channelRepo, channelSvc, userRepo, messageSvc, relay, auditLogger)
+	groupKeySvc := groupkeys.NewGroupKeyService(groupKeyRepo, channelRepo, relay, auditLogger)
+	backupSvc := backup.NewBackupService(backupRepo, userRepo, smtpSender, auditLogger)
+	deviceSvc := devices.NewDeviceService(deviceRepo, refreshMgr, keySvc, relay, auditLogger)
+	webauthnSvc := webauthn.NewWebAuthnService(webauthnRepo, webauthn.Config{RPID: cfg.WebAuthnRPID, Origins: cfg.WebAuthnOrigins}, auditLogger)
+	botSvc := bots.NewBotService(botTokenRepo, userRepo, userSvc, botQueue, auditLogger)
+
+	if err := botSvc.TrackAll(context.Background()); err != nil {
+		log.Println("warning: could not load bots:", err)
+	}
 
 	// Handlers
-	authHandler := auth.NewHandler(userSvc, refreshMgr, jwtMgr, mfaMgr)
-	userHandler := users.NewHandler(userSvc, jwtMgr)
+	authHandler := auth.NewHandler(userSvc, refreshMgr, jwtMgr, mfaMgr, totpMgr, webauthnSvc, deviceSvc, resetMgr, loginGuard)
+	userHandler := users.NewHandler(userSvc, jwtMgr, verificationSvc)
 	channelHandler := channels.NewHandler(channelSvc, userSvc)
 	messageHandler := messages.NewHandler(messageSvc)
+	holdHandler := legalhold.NewHandler(holdSvc)
+	moderationHandler := moderation.NewHandler(moderationSvc)
+	botHandler := bots.NewHandler(botSvc, botQueue)
+	webhookHandler := webhooks.NewHandler(webhookSvc, incomingSvc)
+	commandHandler := commands.NewHandler(commandSvc)
+	keyHandler := keys.NewHandler(keySvc)
+	groupKeyHandler := groupkeys.NewHandler(groupKeySvc)
+	backupHandler := backup.NewHandler(backupSvc)
+	deviceHandler := devices.NewHandler(deviceSvc)
 
 	// Router
 	r := chi.NewRouter()
+	r.Use(mw.RealIP(cfg.ClientIPHeader, cfg.TrustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	
@@ -86,13 +175,21 @@ func main() {
 		MaxAge:           300,
 	}))
 
+	// Public keys for verifying access tokens outside this service
+	r.Get("/.well-known/jwks.json", userHandler.JWKS)
+
 	r.Route("/api/v1", func(api chi.Router) {
 
 		// Public routes
 		api.Mount("/auth", authHandler.Routes())
 
+		// Incoming webhooks authenticate with the token in the URL
+		api.Post("/hooks/{id}/{token}", webhookHandler.PostIncoming)
+
 		api.Route("/users", func(ur chi.Router) {
 			ur.Post("/register", userHandler.Register)
+			ur.Post("/verify-email", userHandler.VerifyEmail)
+			ur.Post("/verify-email/resend", userHandler.ResendVerification)
 
 			// Protected user routes
 			ur.Group(func(pr chi.Router) {
@@ -107,13 +204,38 @@ func main() {
 
 		// Protected channel & message routes
 		api.Group(func(cr chi.Router) {
-			cr.Use(mw.JWTAuth(jwtMgr))
+			cr.Use(mw.Authenticate(jwtMgr, botSvc))
 			cr.Use(mw.LoadUser(userRepo))
 			
 			// WebSocket route
 			cr.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
 				userID := users.UserIDFromContext(r.Context())
-				ws.ServeWs(hub, userID)(w, r)
+				deviceID := users.DeviceIDFromContext(r.Context())
+				sessionID := users.SessionIDFromContext(r.Context())
+				user, ok := mw.GetUserFromContext(r.Context())
+				// A signed-out session must not reconnect with its leftover access token
+				if ok && sessionID != "" {
+					active, err := refreshMgr.SessionActive(r.Context(), user.ID, sessionID)
+					if err != nil {
+						http.Error(w, "internal_error", http.StatusInternalServerError)
+						return
+					}
+					if !active {
+						http.Error(w, "session_revoked", http.StatusUnauthorized)
+						return
+					}
+				}
+				// A removed device must not reconnect either
+				if ok && deviceID != "" {
+					if err := deviceSvc.Touch(r.Context(), user.ID, deviceID); err == devices.ErrDeviceNotFound {
+						http.Error(w, "device_removed", http.StatusUnauthorized)
+						return
+					} else if err != nil {
+						http.Error(w, "internal_error", http.StatusInternalServerError)
+						return
+					}
+				}
+				ws.ServeWs(hub, userID, deviceID, sessionID)(w, r)
 			})
 			
 			// Channel routes
@@ -122,7 +244,33 @@ func main() {
 			// Message routes (note: using separate path to avoid conflict)
 			cr.Post("/channels/{channelId}/messages", messageHandler.SendMessage)
 			cr.Get("/channels/{channelId}/messages", messageHandler.GetMessages)
+			cr.Post("/channels/{channelId}/encryption/rotate", messageHandler.RotateDataKey)
 			cr.Delete("/messages/{id}", messageHandler.DeleteMessage)
+			cr.Post("/channels/{channelId}/commands", commandHandler.Invoke)
+			cr.Get("/channels/{channelId}/commands", commandHandler.ListCommands)
+			cr.Get("/channels/{channelId}/keys", groupKeyHandler.GetMyKeys)
+			cr.Post("/channels/{channelId}/keys/rotate", groupKeyHandler.RotateKey)
+			cr.Post("/channels/{channelId}/keys/share", groupKeyHandler.ShareKeys)
+			cr.Post("/messages/{id}/report", moderationHandler.ReportMessage)
+
+			// Bot management and the bot update API
+			cr.Mount("/bots", botHandler.Routes())
+
+			// E2EE key directory (identity keys and prekey bundles)
+			cr.Mount("/keys", keyHandler.Routes())
+			cr.Mount("/backup", backupHandler.Routes())
+
+			// Logged-in devices
+			cr.Mount("/devices", deviceHandler.Routes())
+
+			// Bot-registered slash commands
+			cr.Mount("/commands", commandHandler.Routes())
+
+			// Outgoing webhooks (channel owners; admins for global)
+			cr.Mount("/webhooks", webhookHandler.Routes())
+
+			// Moderation queue
+			cr.With(mw.RequireRole(acl.RoleModerator)).Mount("/moderation", moderationHandler.Routes())
 		})
 
 		// Admin-only routes (example for broadcasting)
@@ -131,8 +279,9 @@ func main() {
 			ar.Use(mw.LoadUser(userRepo))
 			ar.Use(mw.RequireRole(acl.RoleAdmin))
 			
-			// Admin routes would go here
-			// Example: ar.Get("/admin/audit-logs", ...)
+			// Legal holds preserve user and channel data against deletion
+			ar.With(mw.RequirePermission(acl.PermissionManageLegalHolds)).
+				Mount("/admin/legal-holds", holdHandler.Routes())
 		})
 	})
 
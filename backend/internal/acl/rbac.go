@@ -45,6 +45,8 @@ const (
 	PermissionDeleteMessage   Permission = "message:delete"
 	PermissionDeleteAnyMessage Permission = "message:delete_any"
 	PermissionViewAuditLogs   Permission = "audit:view"
+	PermissionManageLegalHolds Permission = "legal:hold"
+	PermissionManageWebhooks  Permission = "webhook:manage_global"
 )
 
 // rolePermissions defines which roles have which permissions
@@ -68,6 +70,8 @@ var rolePermissions = map[Role][]Permission{
 		PermissionDeleteChannel,
 		PermissionBroadcast,
 		PermissionViewAuditLogs,
+		PermissionManageLegalHolds,
+		PermissionManageWebhooks,
 	},
 }
 
@@ -17,22 +17,78 @@ import (
 type EventType string
 
 const (
-	EventLogin          EventType = "login"
-	EventLoginFailed    EventType = "login_failed"
-	EventMFAVerified    EventType = "mfa_verified"
-	EventMFAFailed      EventType = "mfa_failed"
-	EventLogout         EventType = "logout"
-	EventAccessDenied   EventType = "access_denied"
-	EventChannelCreated EventType = "channel_created"
-	EventChannelDeleted EventType = "channel_deleted"
-	EventMessageSent    EventType = "message_sent"
-	EventMessageDeleted EventType = "message_deleted"
+	EventLogin                  EventType = "login"
+	EventLoginFailed            EventType = "login_failed"
+	EventMFAVerified            EventType = "mfa_verified"
+	EventMFAFailed              EventType = "mfa_failed"
+	EventLogout                 EventType = "logout"
+	EventAccessDenied           EventType = "access_denied"
+	EventChannelCreated         EventType = "channel_created"
+	EventChannelDeleted         EventType = "channel_deleted"
+	EventChannelUpdated         EventType = "channel_updated"
+	EventMessageSent            EventType = "message_sent"
+	EventMessageDeleted         EventType = "message_deleted"
+	EventLegalHoldPlaced        EventType = "legal_hold_placed"
+	EventLegalHoldReleased      EventType = "legal_hold_released"
+	EventMessageReported        EventType = "message_reported"
+	EventReportClaimed          EventType = "report_claimed"
+	EventReportResolved         EventType = "report_resolved"
+	EventUserSuspended          EventType = "user_suspended"
+	EventBotCreated             EventType = "bot_created"
+	EventBotDeleted             EventType = "bot_deleted"
+	EventBotTokenRotated        EventType = "bot_token_rotated"
+	EventWebhookCreated         EventType = "webhook_created"
+	EventWebhookDeleted         EventType = "webhook_deleted"
+	EventIncomingHookCreated    EventType = "incoming_webhook_created"
+	EventIncomingHookRevoked    EventType = "incoming_webhook_revoked"
+	EventCommandExecuted        EventType = "command_executed"
+	EventKeysPublished          EventType = "keys_published"
+	EventChannelKeyRotated      EventType = "channel_key_rotated"
+	EventKeyBackupStored        EventType = "key_backup_stored"
+	EventKeyBackupRestored      EventType = "key_backup_restored"
+	EventKeyBackupDeleted       EventType = "key_backup_deleted"
+	EventChannelDataKeyRotated  EventType = "channel_data_key_rotated"
+	EventContactVerified        EventType = "contact_verified"
+	EventDeviceRegistered       EventType = "device_registered"
+	EventDeviceRemoved          EventType = "device_removed"
+	EventPasskeyRegistered      EventType = "passkey_registered"
+	EventPasskeyRemoved         EventType = "passkey_removed"
+	EventRefreshTokenReuse      EventType = "refresh_token_reuse"
+	EventSessionRevoked         EventType = "session_revoked"
+	EventPasswordResetRequested EventType = "password_reset_requested"
+	EventPasswordReset          EventType = "password_reset"
+	EventEmailVerified          EventType = "email_verified"
 )
 
+// ActorType distinguishes who performed an audited action
+type ActorType string
+
+const (
+	ActorUser        ActorType = "user"
+	ActorBot         ActorType = "bot"
+	ActorIntegration ActorType = "integration"
+)
+
+type actorKey struct{}
+
+// ContextWithActor records what kind of account is acting in this request
+func ContextWithActor(ctx context.Context, actor ActorType) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the acting account type, defaulting to a human user
+func ActorFromContext(ctx context.Context) ActorType {
+	if actor, ok := ctx.Value(actorKey{}).(ActorType); ok {
+		return actor
+	}
+	return ActorUser
+}
+
 // AuditLog represents a single audit event
 type AuditLog struct {
 	ID        uuid.UUID  `bson:"_id"`
 	UserID    *uuid.UUID `bson:"user_id,omitempty"`
+	ActorType ActorType  `bson:"actor_type,omitempty"`
 	Action    EventType  `bson:"action"`
 	Resource  string     `bson:"resource,omitempty"`
 	IPAddress string     `bson:"ip_address,omitempty"`
@@ -41,11 +97,15 @@ type AuditLog struct {
 	Timestamp time.Time  `bson:"timestamp"`
 }
 
+// Hook is called after every recorded audit event
+type Hook func(ctx context.Context, event AuditLog)
+
 // Logger provides audit logging functionality
 type Logger struct {
 	collection *mongo.Collection
 	fileLogger *log.Logger
 	file       *os.File
+	hooks      []Hook
 }
 
 func NewLogger(db *mongo.Database) *Logger {
@@ -68,13 +128,17 @@ func NewLogger(db *mongo.Database) *Logger {
 func (l *Logger) Log(ctx context.Context, event AuditLog) error {
 	event.ID = uuid.New()
 	event.Timestamp = time.Now()
+	if event.ActorType == "" {
+		event.ActorType = ActorFromContext(ctx)
+	}
 
 	// Log to file
 	if l.fileLogger != nil {
-		logEntry := fmt.Sprintf("[%s] Action=%s UserID=%v Resource=%s Result=%s IP=%s Details=%s",
+		logEntry := fmt.Sprintf("[%s] Action=%s UserID=%v Actor=%s Resource=%s Result=%s IP=%s Details=%s",
 			event.Timestamp.Format(time.RFC3339),
 			event.Action,
 			event.UserID,
+			event.ActorType,
 			event.Resource,
 			event.Result,
 			event.IPAddress,
@@ -84,10 +148,23 @@ func (l *Logger) Log(ctx context.Context, event AuditLog) error {
 	}
 
 	// Log to MongoDB
-	_, err := l.collection.InsertOne(ctx, event)
+	var err error
+	if l.collection != nil {
+		_, err = l.collection.InsertOne(ctx, event)
+	}
+
+	for _, hook := range l.hooks {
+		hook(ctx, event)
+	}
 	return err
 }
 
+// AddHook registers a function that is notified of every audit event.
+// Hooks must be registered during startup, before the logger is in use.
+func (l *Logger) AddHook(hook Hook) {
+	l.hooks = append(l.hooks, hook)
+}
+
 // GetUserLogs retrieves audit logs for a specific user
 func (l *Logger) GetUserLogs(ctx context.Context, userID uuid.UUID, limit int) ([]AuditLog, error) {
 	if limit <= 0 || limit > 100 {
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"telegraph/internal/devices"
+)
+
+// MFAChallenge is a password login waiting for its second factor.
+// The device is only registered once the challenge is completed.
+type MFAChallenge struct {
+	TokenHash  string           `bson:"_id"`
+	UserID     uuid.UUID        `bson:"user_id"`
+	DeviceID   string           `bson:"device_id,omitempty"`
+	DeviceName string           `bson:"device_name,omitempty"`
+	Platform   devices.Platform `bson:"platform,omitempty"`
+	CreatedAt  time.Time        `bson:"created_at"`
+	ExpiresAt  time.Time        `bson:"expires_at"`
+}
+
+type MFAChallengeRepo interface {
+	Create(ctx context.Context, c *MFAChallenge) error
+	GetByHash(ctx context.Context, tokenHash string) (*MFAChallenge, error)
+	Delete(ctx context.Context, tokenHash string) error
+}
+
+type mongoMFAChallengeRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMFAChallengeRepo(db *mongo.Database) MFAChallengeRepo {
+	return &mongoMFAChallengeRepo{
+		collection: db.Collection("mfa_challenges"),
+	}
+}
+
+func (r *mongoMFAChallengeRepo) Create(ctx context.Context, c *MFAChallenge) error {
+	_, err := r.collection.InsertOne(ctx, c)
+	return err
+}
+
+func (r *mongoMFAChallengeRepo) GetByHash(ctx context.Context, tokenHash string) (*MFAChallenge, error) {
+	var c MFAChallenge
+	err := r.collection.FindOne(ctx, bson.M{"_id": tokenHash}).Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Delete fails with ErrChallengeNotFound if the challenge was already consumed
+func (r *mongoMFAChallengeRepo) Delete(ctx context.Context, tokenHash string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": tokenHash})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrChallengeNotFound
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package auth
+
+import "errors"
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid_refresh")
+	ErrRefreshTokenExpired = errors.New("refresh_token_expired")
+	ErrRefreshTokenReused  = errors.New("refresh_token_reused")
+	ErrSessionNotFound     = errors.New("session_not_found")
+
+	ErrResetTokenInvalid = errors.New("invalid_reset_token")
+	ErrResetTokenExpired = errors.New("reset_token_expired")
+
+	ErrChallengeNotFound = errors.New("mfa_challenge_not_found")
+	ErrChallengeExpired  = errors.New("mfa_challenge_expired")
+	ErrMFANotEnabled     = errors.New("mfa_not_enabled")
+	ErrMFAAlreadyEnabled = errors.New("mfa_already_enabled")
+	ErrMFAMethodInvalid  = errors.New("mfa_method_not_enabled")
+	ErrInvalidCode       = errors.New("invalid_code")
+	ErrCodeExpired       = errors.New("code_expired")
+	ErrTooManyAttempts   = errors.New("too_many_attempts")
+
+	ErrTOTPNotEnrolled     = errors.New("totp_not_enrolled")
+	ErrTOTPAlreadyEnrolled = errors.New("totp_already_enrolled")
+	ErrTOTPReplay          = errors.New("totp_code_already_used")
+	ErrInvalidRecoveryCode = errors.New("invalid_recovery_code")
+)
@@ -2,24 +2,44 @@ package auth
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/google/uuid"
 
+	"telegraph/internal/acl"
+	"telegraph/internal/audit"
+	"telegraph/internal/devices"
+	"telegraph/internal/middleware"
 	"telegraph/internal/users"
+	"telegraph/internal/webauthn"
+)
+
+// Authentication method references (RFC 8176) recorded in the amr claim
+const (
+	amrPassword    = "pwd"
+	amrOTP         = "otp"
+	amrHardwareKey = "hwk"
+	amrMulti       = "mfa" // a passkey that also verified the user (PIN or biometric)
 )
 
 type Handler struct {
-	userSvc users.UserService
-	refresh *RefreshTokenManager
-	jwt     *users.JWTManager
-	mfa     *MFAManager
+	userSvc  users.UserService
+	refresh  *RefreshTokenManager
+	jwt      *users.JWTManager
+	mfa      *MFAManager
+	totp     *TOTPManager
+	webauthn webauthn.WebAuthnService
+	devices  devices.DeviceService
+	resets   *PasswordResetManager
+	guard    *LoginGuard
 }
 
-func NewHandler(userSvc users.UserService, refresh *RefreshTokenManager, jwt *users.JWTManager, mfa *MFAManager) *Handler {
-	return &Handler{userSvc: userSvc, refresh: refresh, jwt: jwt, mfa: mfa}
+func NewHandler(userSvc users.UserService, refresh *RefreshTokenManager, jwt *users.JWTManager, mfa *MFAManager, totp *TOTPManager, webauthn webauthn.WebAuthnService, devices devices.DeviceService, resets *PasswordResetManager, guard *LoginGuard) *Handler {
+	return &Handler{userSvc: userSvc, refresh: refresh, jwt: jwt, mfa: mfa, totp: totp, webauthn: webauthn, devices: devices, resets: resets, guard: guard}
 }
 
 func (h *Handler) Routes() http.Handler {
@@ -28,8 +48,43 @@ func (h *Handler) Routes() http.Handler {
 	r.Post("/login", h.Login)
 	r.Post("/refresh", h.Refresh)
 	r.Post("/logout", h.Logout)
+
+	// Account recovery
+	r.Post("/password/forgot", h.ForgotPassword)
+	r.Post("/password/reset", h.ResetPassword)
+
+	// Second step of a login that returned mfa_required
 	r.Post("/mfa/send", h.SendMFA)
 	r.Post("/mfa/verify", h.VerifyMFA)
+	r.Post("/webauthn/mfa/begin", h.BeginWebAuthnMFA)
+	r.Post("/webauthn/mfa/finish", h.FinishWebAuthnMFA)
+
+	// Passwordless login with a passkey
+	r.Post("/webauthn/login/begin", h.BeginWebAuthnLogin)
+	r.Post("/webauthn/login/finish", h.FinishWebAuthnLogin)
+
+	// Enrolment is done from an existing session
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.JWTAuth(h.jwt))
+		r.Post("/mfa/code", h.SendMFACode)
+		r.Post("/mfa/enable", h.EnableMFA)
+		r.Post("/mfa/disable", h.DisableMFA)
+
+		r.Post("/mfa/totp/setup", h.SetupTOTP)
+		r.Post("/mfa/totp/confirm", h.ConfirmTOTP)
+		r.Post("/mfa/totp/disable", h.DisableTOTP)
+		r.Post("/mfa/recovery-codes", h.RegenerateRecoveryCodes)
+
+		r.Post("/webauthn/register/begin", h.BeginWebAuthnRegistration)
+		r.Post("/webauthn/register/finish", h.FinishWebAuthnRegistration)
+		r.Get("/webauthn/credentials", h.ListWebAuthnCredentials)
+		r.Delete("/webauthn/credentials/{id}", h.DeleteWebAuthnCredential)
+
+		r.Get("/sessions", h.ListSessions)
+		r.Delete("/sessions", h.RevokeAllSessions)
+		r.Delete("/sessions/others", h.RevokeOtherSessions)
+		r.Delete("/sessions/{id}", h.RevokeSession)
+	})
 
 	return r
 }
@@ -38,32 +93,92 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+
+		// Optional; a new device ID is assigned when omitted
+		DeviceID   string           `json:"device_id"`
+		DeviceName string           `json:"device_name"`
+		Platform   devices.Platform `json:"platform"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
 
+	attempt, wait := h.guard.Begin(LoginAccount(body.Email), clientInfo(r).IPAddress)
+	if wait > 0 {
+		respondThrottled(w, wait)
+		return
+	}
+
 	user, err := h.userSvc.Login(r.Context(), body.Email, body.Password)
 	if err != nil {
+		// Attribute the failure to the account if there is one
+		var uid *uuid.UUID
+		if u, lookupErr := h.userSvc.GetByEmail(r.Context(), strings.ToLower(strings.TrimSpace(body.Email))); lookupErr == nil {
+			uid = &u.ID
+		}
+		attempt.Fail(r.Context(), audit.EventLoginFailed, uid, err.Error())
+
+		if err == users.ErrAccountSuspended {
+			http.Error(w, "account_suspended", 403)
+			return
+		}
 		http.Error(w, "invalid_credentials", 401)
 		return
 	}
+	attempt.Succeed()
 
-	// access token
-	access, err := h.jwt.Generate(user.ID.String(), user.Role)
+	device := devices.RegisterDeviceRequest{
+		DeviceID: body.DeviceID,
+		Name:     body.DeviceName,
+		Platform: body.Platform,
+	}
+
+	// Hold back tokens until the second factor is verified
+	if user.MFAEnabled() {
+		challenge, err := h.mfa.StartChallenge(r.Context(), user, device)
+		if err != nil {
+			http.Error(w, "mfa_error", 500)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"mfa_required":    true,
+			"challenge_token": challenge,
+			"methods":         challengeMethods(user),
+			"expires_in":      int(challengeTTL.Seconds()),
+		})
+		return
+	}
+
+	h.issueTokens(w, r, user, device, []string{amrPassword})
+}
+
+// issueTokens registers the login device and responds with a new token pair
+func (h *Handler) issueTokens(w http.ResponseWriter, r *http.Request, user *users.User, req devices.RegisterDeviceRequest, amr []string) {
+	// device
+	device, err := h.devices.Register(r.Context(), user.ID, req)
 	if err != nil {
-		http.Error(w, "jwt_error", 500)
+		http.Error(w, err.Error(), 400)
 		return
 	}
 
 	// refresh
-	refreshToken, err := h.refresh.Generate(r.Context(), user.ID)
+	refreshToken, rt, err := h.refresh.Generate(r.Context(), user.ID, device.DeviceID, amr, clientInfo(r))
 	if err != nil {
 		http.Error(w, "refresh_error", 500)
 		return
 	}
 
+	// access token
+	access, err := h.jwt.GenerateClaims(loginClaims(user, rt))
+	if err != nil {
+		http.Error(w, "jwt_error", 500)
+		return
+	}
+
 	json.NewEncoder(w).Encode(map[string]any{
 		"access_token":  access,
 		"refresh_token": refreshToken,
+		"device_id":     device.DeviceID,
+		"session_id":    rt.SessionID(),
 		"user": map[string]any{
 			"id":       user.ID,
 			"username": user.Username,
@@ -72,25 +187,88 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// challengeMethods lists the ways a user may complete a login challenge.
+// Codes can be phished, so confidential users who have a passkey must use it.
+func challengeMethods(u *users.User) []string {
+	if u.SecurityLabel == string(acl.LabelConfidential) && u.HasMFAMethod(users.MFAMethodWebAuthn) {
+		return []string{users.MFAMethodWebAuthn}
+	}
+
+	methods := append([]string{}, u.EnabledMFAMethods()...)
+	if u.HasMFAMethod(users.MFAMethodTOTP) {
+		// Recovery codes stand in for the authenticator app
+		methods = append(methods, MFAMethodRecovery)
+	}
+	return methods
+}
+
+func canCompleteWith(u *users.User, method string) bool {
+	for _, m := range challengeMethods(u) {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// loginClaims builds access token claims for a session; more than one
+// login method means MFA
+func loginClaims(u *users.User, rt *RefreshToken) users.Claims {
+	return users.Claims{
+		UserID:    u.ID.String(),
+		Role:      u.Role,
+		DeviceID:  rt.DeviceID,
+		SessionID: rt.SessionID().String(),
+		AMR:       rt.AMR,
+		MFA:       len(rt.AMR) > 1,
+	}
+}
+
+// clientInfo records where a request came from for the sessions list
+func clientInfo(r *http.Request) ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return ClientInfo{IPAddress: ip, UserAgent: r.UserAgent()}
+}
+
 func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Token string `json:"refresh_token"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
 
-	uid, err := h.refresh.Verify(r.Context(), body.Token)
+	// rotate
+	rt, newToken, err := h.refresh.Rotate(r.Context(), body.Token, clientInfo(r))
+	switch err {
+	case nil:
+	case ErrInvalidRefreshToken, ErrRefreshTokenExpired, ErrRefreshTokenReused:
+		http.Error(w, err.Error(), 401)
+		return
+	default:
+		http.Error(w, "refresh_error", 500)
+		return
+	}
+
+	u, err := h.userSvc.GetByID(r.Context(), rt.UserID)
 	if err != nil {
 		http.Error(w, "invalid_refresh", 401)
 		return
 	}
+	if u.Suspended {
+		http.Error(w, "account_suspended", 403)
+		return
+	}
 
-	// rotate
-	_ = h.refresh.Revoke(r.Context(), body.Token)
-	newToken, _ := h.refresh.Generate(r.Context(), uid)
-
-	u, _ := h.userSvc.GetByID(r.Context(), uid)
-
-	access, _ := h.jwt.Generate(u.ID.String(), u.Role)
+	if rt.DeviceID != "" {
+		_ = h.devices.Touch(r.Context(), rt.UserID, rt.DeviceID)
+	}
+	access, err := h.jwt.GenerateClaims(loginClaims(u, rt))
+	if err != nil {
+		http.Error(w, "jwt_error", 500)
+		return
+	}
 
 	json.NewEncoder(w).Encode(map[string]any{
 		"access_token":  access,
@@ -107,16 +285,30 @@ func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(204)
 }
 
+// SendMFA emails the code for a pending login challenge
 func (h *Handler) SendMFA(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		UserID string `json:"user_id"`
+		ChallengeToken string `json:"challenge_token"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
 
-	uid, _ := uuid.Parse(body.UserID)
-	u, err := h.userSvc.GetByID(r.Context(), uid)
+	c, err := h.mfa.Challenge(r.Context(), body.ChallengeToken)
 	if err != nil {
-		http.Error(w, "not_found", 404)
+		http.Error(w, "invalid_challenge", 401)
+		return
+	}
+
+	u, err := h.userSvc.GetByID(r.Context(), c.UserID)
+	if err != nil {
+		http.Error(w, "invalid_challenge", 401)
+		return
+	}
+	if !canCompleteWith(u, users.MFAMethodEmail) {
+		http.Error(w, ErrMFAMethodInvalid.Error(), 400)
+		return
+	}
+	if wait := h.guard.AllowCodeSend(MFAAccount(u.ID)); wait > 0 {
+		respondThrottled(w, wait)
 		return
 	}
 
@@ -129,19 +321,316 @@ func (h *Handler) SendMFA(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(204)
 }
 
+// VerifyMFA completes a login challenge and issues tokens
 func (h *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		UserID string `json:"user_id"`
-		Code   string `json:"code"`
+		ChallengeToken string `json:"challenge_token"`
+		Method         string `json:"method"` // email (default), totp or recovery; see also /webauthn/mfa
+		Code           string `json:"code"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
+	if body.Method == "" {
+		body.Method = users.MFAMethodEmail
+	}
+
+	_, u, ok := h.pendingLogin(w, r, body.ChallengeToken)
+	if !ok {
+		return
+	}
 
-	uid, _ := uuid.Parse(body.UserID)
-	err := h.mfa.VerifyOTP(r.Context(), uid, body.Code)
+	if body.Method == users.MFAMethodWebAuthn || !canCompleteWith(u, body.Method) {
+		http.Error(w, ErrMFAMethodInvalid.Error(), 400)
+		return
+	}
+	attempt, ok := h.beginMFA(w, r, u.ID)
+	if !ok {
+		return
+	}
+
+	c, err := h.mfa.CompleteChallenge(r.Context(), body.ChallengeToken, body.Method, body.Code)
+	if err == ErrChallengeNotFound || err == ErrChallengeExpired {
+		attempt.Release()
+		http.Error(w, "invalid_challenge", 401)
+		return
+	}
 	if err != nil {
+		h.mfaFailed(r, attempt, u.ID, body.Method, err)
 		http.Error(w, "invalid_code", 400)
 		return
 	}
+	attempt.Succeed()
+
+	device := devices.RegisterDeviceRequest{
+		DeviceID: c.DeviceID,
+		Name:     c.DeviceName,
+		Platform: c.Platform,
+	}
+	h.issueTokens(w, r, u, device, []string{amrPassword, amrOTP})
+}
+
+// pendingLogin resolves a challenge token to the user logging in
+func (h *Handler) pendingLogin(w http.ResponseWriter, r *http.Request, token string) (*MFAChallenge, *users.User, bool) {
+	c, err := h.mfa.Challenge(r.Context(), token)
+	if err != nil {
+		http.Error(w, "invalid_challenge", 401)
+		return nil, nil, false
+	}
+
+	// The account may have been suspended while the challenge was pending
+	u, err := h.userSvc.GetByID(r.Context(), c.UserID)
+	if err != nil {
+		http.Error(w, "invalid_challenge", 401)
+		return nil, nil, false
+	}
+	if u.Suspended {
+		http.Error(w, "account_suspended", 403)
+		return nil, nil, false
+	}
+	return c, u, true
+}
+
+// SendMFACode emails a code to the signed-in user for enabling or disabling email MFA
+func (h *Handler) SendMFACode(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if wait := h.guard.AllowCodeSend(MFAAccount(u.ID)); wait > 0 {
+		respondThrottled(w, wait)
+		return
+	}
+
+	if _, err := h.mfa.SendOTP(r.Context(), u.ID, u.Email); err != nil {
+		http.Error(w, "send_failed", 500)
+		return
+	}
 
 	w.WriteHeader(204)
 }
+
+// EnableMFA turns on the email second factor once the user proves they receive codes
+func (h *Handler) EnableMFA(w http.ResponseWriter, r *http.Request) {
+	h.setMFA(w, r, true)
+}
+
+// DisableMFA turns the second factor off; it also requires a fresh code
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	h.setMFA(w, r, false)
+}
+
+func (h *Handler) setMFA(w http.ResponseWriter, r *http.Request, enabled bool) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	if enabled && u.HasMFAMethod(users.MFAMethodEmail) {
+		http.Error(w, ErrMFAAlreadyEnabled.Error(), 409)
+		return
+	}
+	if !enabled && !u.HasMFAMethod(users.MFAMethodEmail) {
+		http.Error(w, ErrMFANotEnabled.Error(), 409)
+		return
+	}
+
+	attempt, ok := h.beginMFA(w, r, u.ID)
+	if !ok {
+		return
+	}
+	if err := h.mfa.VerifyOTP(r.Context(), u.ID, body.Code); err != nil {
+		h.mfaFailed(r, attempt, u.ID, users.MFAMethodEmail, err)
+		http.Error(w, "invalid_code", 400)
+		return
+	}
+	attempt.Succeed()
+
+	if err := h.userSvc.SetMFAMethod(r.Context(), u.ID, users.MFAMethodEmail, enabled); err != nil {
+		http.Error(w, "update_failed", 500)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"method":  users.MFAMethodEmail,
+		"enabled": enabled,
+	})
+}
+
+// SetupTOTP starts authenticator-app enrolment. The secret is not used
+// for login until ConfirmTOTP sees a valid code from it.
+func (h *Handler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := h.totp.Setup(r.Context(), u.ID, u.Email)
+	if err == ErrTOTPAlreadyEnrolled {
+		http.Error(w, err.Error(), 409)
+		return
+	}
+	if err != nil {
+		http.Error(w, "totp_error", 500)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTOTP enables TOTP and returns recovery codes, which are only shown once
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	attempt, ok := h.beginMFA(w, r, u.ID)
+	if !ok {
+		return
+	}
+	codes, err := h.totp.Confirm(r.Context(), u.ID, body.Code)
+	if err != nil {
+		h.totpFailed(w, r, attempt, u.ID, err)
+		return
+	}
+	attempt.Succeed()
+
+	if err := h.userSvc.SetMFAMethod(r.Context(), u.ID, users.MFAMethodTOTP, true); err != nil {
+		http.Error(w, "update_failed", 500)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"method":         users.MFAMethodTOTP,
+		"enabled":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTOTP removes the authenticator; it requires a current code
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	attempt, ok := h.beginMFA(w, r, u.ID)
+	if !ok {
+		return
+	}
+	if err := h.totp.Disable(r.Context(), u.ID, body.Code); err != nil {
+		h.totpFailed(w, r, attempt, u.ID, err)
+		return
+	}
+	attempt.Succeed()
+
+	if err := h.userSvc.SetMFAMethod(r.Context(), u.ID, users.MFAMethodTOTP, false); err != nil {
+		http.Error(w, "update_failed", 500)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"method":  users.MFAMethodTOTP,
+		"enabled": false,
+	})
+}
+
+// RegenerateRecoveryCodes invalidates the old recovery codes and issues new ones
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	attempt, ok := h.beginMFA(w, r, u.ID)
+	if !ok {
+		return
+	}
+	codes, err := h.totp.RegenerateRecoveryCodes(r.Context(), u.ID, body.Code)
+	if err != nil {
+		h.totpFailed(w, r, attempt, u.ID, err)
+		return
+	}
+	attempt.Succeed()
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"recovery_codes": codes,
+	})
+}
+
+// totpFailed counts wrong authenticator codes against the account before responding
+func (h *Handler) totpFailed(w http.ResponseWriter, r *http.Request, attempt *Attempt, uid uuid.UUID, err error) {
+	if err == ErrInvalidCode || err == ErrTOTPReplay {
+		h.mfaFailed(r, attempt, uid, users.MFAMethodTOTP, err)
+	} else {
+		attempt.Release()
+	}
+	respondTOTPError(w, err)
+}
+
+func respondTOTPError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrTOTPNotEnrolled:
+		http.Error(w, err.Error(), 404)
+	case ErrTOTPAlreadyEnrolled:
+		http.Error(w, err.Error(), 409)
+	case ErrInvalidCode, ErrTOTPReplay:
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, "totp_error", 500)
+	}
+}
+
+// beginMFA reserves a second factor attempt, or rejects it while the account
+// is backing off
+func (h *Handler) beginMFA(w http.ResponseWriter, r *http.Request, uid uuid.UUID) (*Attempt, bool) {
+	attempt, wait := h.guard.Begin(MFAAccount(uid), clientInfo(r).IPAddress)
+	if wait > 0 {
+		respondThrottled(w, wait)
+		return nil, false
+	}
+	return attempt, true
+}
+
+// mfaFailed records a wrong second factor
+func (h *Handler) mfaFailed(r *http.Request, attempt *Attempt, uid uuid.UUID, method string, err error) {
+	attempt.Fail(r.Context(), audit.EventMFAFailed, &uid, method+": "+err.Error())
+}
+
+// currentUser loads the user behind the request's access token
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*users.User, bool) {
+	uid, err := uuid.Parse(users.UserIDFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, "unauthorized", 401)
+		return nil, false
+	}
+
+	u, err := h.userSvc.GetByID(r.Context(), uid)
+	if err != nil {
+		http.Error(w, "unauthorized", 401)
+		return nil, false
+	}
+	return u, true
+}
@@ -3,26 +3,41 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
+
+	"telegraph/internal/devices"
+	"telegraph/internal/users"
 )
 
+// challengeTTL bounds how long a password login may wait for its second factor
+const challengeTTL = 5 * time.Minute
+
+// MFAMethodRecovery completes a login challenge with a single-use recovery code
+const MFAMethodRecovery = "recovery"
+
+// maxOTPAttempts is how many wrong guesses an emailed code survives
+const maxOTPAttempts = 5
+
 type MFARepo interface {
-	Store(ctx context.Context, uid uuid.UUID, code string, exp time.Time) error
-	Find(ctx context.Context, uid uuid.UUID) (string, time.Time, error)
+	Store(ctx context.Context, uid uuid.UUID, codeHash string, exp time.Time) error
+	UseAttempt(ctx context.Context, uid uuid.UUID, maxAttempts int) (*MFACode, error)
+	Consume(ctx context.Context, uid uuid.UUID, codeHash string) (bool, error)
 	Delete(ctx context.Context, uid uuid.UUID) error
 }
 
 type MFAManager struct {
-	repo   MFARepo
-	sender EmailSender
+	repo       MFARepo
+	challenges MFAChallengeRepo
+	totp       *TOTPManager
+	sender     EmailSender
 }
 
-func NewMFAManager(repo MFARepo, sender EmailSender) *MFAManager {
-	return &MFAManager{repo: repo, sender: sender}
+func NewMFAManager(repo MFARepo, challenges MFAChallengeRepo, totp *TOTPManager, sender EmailSender) *MFAManager {
+	return &MFAManager{repo: repo, challenges: challenges, totp: totp, sender: sender}
 }
 
 func (m *MFAManager) SendOTP(ctx context.Context, uid uuid.UUID, email string) (string, error) {
@@ -30,8 +45,9 @@ func (m *MFAManager) SendOTP(ctx context.Context, uid uuid.UUID, email string) (
 	rand.Read(buf)
 	code := hex.EncodeToString(buf)
 
+	// Storing replaces any earlier code and its attempt count
 	exp := time.Now().Add(10 * time.Minute)
-	err := m.repo.Store(ctx, uid, code, exp)
+	err := m.repo.Store(ctx, uid, hashToken(code), exp)
 	if err != nil {
 		return "", err
 	}
@@ -44,20 +60,114 @@ func (m *MFAManager) SendOTP(ctx context.Context, uid uuid.UUID, email string) (
 	return code, nil
 }
 
+// VerifyOTP checks an emailed code. Each guess uses up one of the code's
+// maxOTPAttempts before it is compared, so parallel guesses can't exceed the
+// limit; after that a new code has to be sent.
 func (m *MFAManager) VerifyOTP(ctx context.Context, uid uuid.UUID, code string) error {
-	storedCode, exp, err := m.repo.Find(ctx, uid)
+	stored, err := m.repo.UseAttempt(ctx, uid, maxOTPAttempts)
 	if err != nil {
 		return err
 	}
 
-	if time.Now().After(exp) {
+	if time.Now().After(stored.ExpiresAt) {
 		_ = m.repo.Delete(ctx, uid)
-		return errors.New("expired")
+		return ErrCodeExpired
+	}
+
+	codeHash := hashToken(code)
+	if subtle.ConstantTimeCompare([]byte(codeHash), []byte(stored.CodeHash)) != 1 {
+		return ErrInvalidCode
+	}
+
+	// Only one of several requests with the right code gets to use it
+	consumed, err := m.repo.Consume(ctx, uid, codeHash)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// VerifyFactor checks a code for one of the user's second factors
+func (m *MFAManager) VerifyFactor(ctx context.Context, uid uuid.UUID, method, code string) error {
+	switch method {
+	case users.MFAMethodEmail:
+		return m.VerifyOTP(ctx, uid, code)
+	case users.MFAMethodTOTP:
+		return m.totp.Verify(ctx, uid, code)
+	case MFAMethodRecovery:
+		return m.totp.UseRecoveryCode(ctx, uid, code)
+	}
+	return ErrMFAMethodInvalid
+}
+
+// StartChallenge parks a password login until the user proves a second
+// factor. The returned token identifies the challenge; when email is the
+// user's only method the code is sent straight away.
+func (m *MFAManager) StartChallenge(ctx context.Context, u *users.User, device devices.RegisterDeviceRequest) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	now := time.Now()
+	err := m.challenges.Create(ctx, &MFAChallenge{
+		TokenHash:  hashToken(token),
+		UserID:     u.ID,
+		DeviceID:   device.DeviceID,
+		DeviceName: device.Name,
+		Platform:   device.Platform,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(challengeTTL),
+	})
+	if err != nil {
+		return "", err
 	}
 
-	if storedCode != code {
-		return errors.New("invalid_code")
+	methods := u.EnabledMFAMethods()
+	if len(methods) == 1 && methods[0] == users.MFAMethodEmail {
+		if _, err := m.SendOTP(ctx, u.ID, u.Email); err != nil {
+			return "", err
+		}
+	}
+	return token, nil
+}
+
+// Challenge looks up a pending login challenge
+func (m *MFAManager) Challenge(ctx context.Context, token string) (*MFAChallenge, error) {
+	c, err := m.challenges.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
 	}
+	if time.Now().After(c.ExpiresAt) {
+		_ = m.challenges.Delete(ctx, c.TokenHash)
+		return nil, ErrChallengeExpired
+	}
+	return c, nil
+}
+
+// CompleteChallenge checks the code for a pending login and consumes the
+// challenge, so each one yields at most one set of tokens. The caller must
+// make sure the method is one the user has enabled.
+func (m *MFAManager) CompleteChallenge(ctx context.Context, token, method, code string) (*MFAChallenge, error) {
+	c, err := m.Challenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.VerifyFactor(ctx, c.UserID, method, code); err != nil {
+		return nil, err
+	}
+	if err := m.ConsumeChallenge(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
-	return m.repo.Delete(ctx, uid)
+// ConsumeChallenge ends a challenge whose second factor was verified
+// elsewhere, e.g. by a WebAuthn assertion
+func (m *MFAManager) ConsumeChallenge(ctx context.Context, c *MFAChallenge) error {
+	return m.challenges.Delete(ctx, c.TokenHash)
 }
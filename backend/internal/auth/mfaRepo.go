@@ -15,6 +15,7 @@ import (
 type MFACode struct {
 	UserID    uuid.UUID `bson:"_id"`
 	CodeHash  string    `bson:"code_hash"`
+	Attempts  int       `bson:"attempts"`
 	ExpiresAt time.Time `bson:"expires_at"`
 }
 
@@ -44,16 +45,40 @@ func (r *mongoMFACodeRepo) Store(ctx context.Context, userID uuid.UUID, codeHash
 	return err
 }
 
-func (r *mongoMFACodeRepo) Find(ctx context.Context, userID uuid.UUID) (string, time.Time, error) {
+// UseAttempt counts a guess against the current code and returns the code,
+// as long as it has attempts left. A code without attempts left reports
+// ErrTooManyAttempts; a missing one ErrInvalidCode.
+func (r *mongoMFACodeRepo) UseAttempt(ctx context.Context, userID uuid.UUID, maxAttempts int) (*MFACode, error) {
+	filter := bson.M{"_id": userID, "attempts": bson.M{"$lt": maxAttempts}}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
 	var code MFACode
-	err := r.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&code)
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&code)
 	if err == mongo.ErrNoDocuments {
-		return "", time.Time{}, nil
+		n, countErr := r.collection.CountDocuments(ctx, bson.M{"_id": userID})
+		if countErr != nil {
+			return nil, countErr
+		}
+		if n > 0 {
+			return nil, ErrTooManyAttempts
+		}
+		return nil, ErrInvalidCode
+	}
+	if err != nil {
+		return nil, err
 	}
+	return &code, nil
+}
+
+// Consume deletes the code if it is still the one that was checked and
+// reports whether this call removed it
+func (r *mongoMFACodeRepo) Consume(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID, "code_hash": codeHash})
 	if err != nil {
-		return "", time.Time{}, err
+		return false, err
 	}
-	return code.CodeHash, code.ExpiresAt, nil
+	return res.DeletedCount == 1, nil
 }
 
 func (r *mongoMFACodeRepo) Delete(ctx context.Context, userID uuid.UUID) error {
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryMFARepo keeps emailed codes in memory for tests
+type memoryMFARepo struct {
+	mu    sync.Mutex
+	codes map[uuid.UUID]*MFACode
+}
+
+func newMemoryMFARepo() *memoryMFARepo {
+	return &memoryMFARepo{codes: map[uuid.UUID]*MFACode{}}
+}
+
+func (r *memoryMFARepo) Store(ctx context.Context, uid uuid.UUID, codeHash string, exp time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[uid] = &MFACode{UserID: uid, CodeHash: codeHash, ExpiresAt: exp}
+	return nil
+}
+
+func (r *memoryMFARepo) UseAttempt(ctx context.Context, uid uuid.UUID, maxAttempts int) (*MFACode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.codes[uid]
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	if c.Attempts >= maxAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	c.Attempts++
+	copied := *c
+	return &copied, nil
+}
+
+func (r *memoryMFARepo) Consume(ctx context.Context, uid uuid.UUID, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.codes[uid]
+	if !ok || c.CodeHash != codeHash {
+		return false, nil
+	}
+	delete(r.codes, uid)
+	return true, nil
+}
+
+func (r *memoryMFARepo) Delete(ctx context.Context, uid uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.codes, uid)
+	return nil
+}
+
+func TestVerifyOTP_ParallelGuessesAreCapped(t *testing.T) {
+	repo := newMemoryMFARepo()
+	m := NewMFAManager(repo, nil, nil, nil)
+	uid := uuid.New()
+	repo.Store(context.Background(), uid, hashToken("a1b2c3"), time.Now().Add(time.Minute))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	compared := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.VerifyOTP(context.Background(), uid, "000000"); err == ErrInvalidCode {
+				mu.Lock()
+				compared++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if compared != maxOTPAttempts {
+		t.Fatalf("%d guesses were compared, want %d", compared, maxOTPAttempts)
+	}
+	if err := m.VerifyOTP(context.Background(), uid, "a1b2c3"); err != ErrTooManyAttempts {
+		t.Fatalf("right code after the limit: err = %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+func TestVerifyOTP_CodeIsSingleUse(t *testing.T) {
+	repo := newMemoryMFARepo()
+	m := NewMFAManager(repo, nil, nil, nil)
+	uid := uuid.New()
+	repo.Store(context.Background(), uid, hashToken("a1b2c3"), time.Now().Add(time.Minute))
+
+	if err := m.VerifyOTP(context.Background(), uid, "a1b2c3"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := m.VerifyOTP(context.Background(), uid, "a1b2c3"); err != ErrInvalidCode {
+		t.Fatalf("second use: err = %v, want %v", err, ErrInvalidCode)
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"telegraph/internal/users"
+)
+
+// resetSendTimeout bounds the background work of a forgot-password request
+const resetSendTimeout = time.Minute
+
+// ForgotPassword emails a reset token. The response is the same, and takes
+// the same time, whether or not the address belongs to an account: the
+// lookup and the email happen after responding.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		http.Error(w, "bad_request", 400)
+		return
+	}
+	if wait := h.resets.AllowRequest(body.Email, clientInfo(r).IPAddress); wait > 0 {
+		respondThrottled(w, wait)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), resetSendTimeout)
+	go func() {
+		defer cancel()
+		if err := h.resets.RequestReset(ctx, body.Email); err != nil {
+			log.Printf("Password reset request failed: %v", err)
+		}
+	}()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Token == "" {
+		http.Error(w, "bad_request", 400)
+		return
+	}
+
+	err := h.resets.ResetPassword(r.Context(), body.Token, body.Password)
+	switch err {
+	case nil:
+		w.WriteHeader(204)
+	case users.ErrPasswordTooShort:
+		http.Error(w, err.Error(), 400)
+	case ErrResetTokenInvalid, ErrResetTokenExpired:
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, "reset_failed", 500)
+	}
+}
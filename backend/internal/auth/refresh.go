@@ -7,22 +7,56 @@ import (
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type RefreshTokenRepo interface {
-	Create(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	Create(ctx context.Context, token *RefreshToken) error
 	GetByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	MarkRotated(ctx context.Context, id, successorID uuid.UUID, successorSeal []byte) error
 	Revoke(ctx context.Context, tokenHash string) error
 	RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error
+	RevokeDeviceTokens(ctx context.Context, userID uuid.UUID, deviceID string) error
+	ListActive(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error)
+	RevokeFamily(ctx context.Context, userID, familyID uuid.UUID) error
+	RevokeAllExceptFamily(ctx context.Context, userID, familyID uuid.UUID) error
 }
 
+// RefreshToken is one link in a family: a login creates the first token and
+// every refresh replaces the current one with a child. Only the newest token
+// in a family is ever valid, and a family is what users see as a session.
 type RefreshToken struct {
-	ID        uuid.UUID `bson:"_id"`
-	UserID    uuid.UUID `bson:"user_id"`
-	TokenHash string    `bson:"token_hash"`
-	CreatedAt time.Time `bson:"created_at"`
-	ExpiresAt time.Time `bson:"expires_at"`
-	Revoked   bool      `bson:"revoked"`
+	ID        uuid.UUID  `bson:"_id"`
+	UserID    uuid.UUID  `bson:"user_id"`
+	FamilyID  uuid.UUID  `bson:"family_id"`
+	ParentID  *uuid.UUID `bson:"parent_id,omitempty"`
+	DeviceID  string     `bson:"device_id,omitempty"`
+	TokenHash string     `bson:"token_hash"`
+	AMR       []string   `bson:"amr,omitempty"` // Login methods, carried over to refreshed access tokens
+	CreatedAt time.Time  `bson:"created_at"`    // Doubles as the session's last use
+
+	// Session metadata: when the family started and the client that last used it
+	SessionStartedAt time.Time `bson:"session_started_at"`
+	IPAddress        string    `bson:"ip_address,omitempty"`
+	UserAgent        string    `bson:"user_agent,omitempty"`
+
+	ExpiresAt time.Time  `bson:"expires_at"`
+	Revoked   bool       `bson:"revoked"`
+	RotatedAt *time.Time `bson:"rotated_at,omitempty"` // Set when replaced by a child token
+
+	// The child token, encrypted with this token, so a client that retries a
+	// refresh within the grace period gets the same child back
+	SuccessorID   *uuid.UUID `bson:"successor_id,omitempty"`
+	SuccessorSeal []byte     `bson:"successor_seal,omitempty"`
+}
+
+// SessionID identifies the token's family. Tokens issued before families
+// were tracked are their own family.
+func (t *RefreshToken) SessionID() uuid.UUID {
+	if t.FamilyID == uuid.Nil {
+		return t.ID
+	}
+	return t.FamilyID
 }
 
 type mongoRefreshTokenRepo struct {
@@ -35,30 +69,45 @@ func NewRefreshTokenRepo(db *mongo.Database) RefreshTokenRepo {
 	}
 }
 
-func (r *mongoRefreshTokenRepo) Create(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
-	token := RefreshToken{
-		ID:        uuid.New(),
-		UserID:    userID,
-		TokenHash: tokenHash,
-		CreatedAt: time.Now(),
-		ExpiresAt: expiresAt,
-		Revoked:   false,
-	}
+func (r *mongoRefreshTokenRepo) Create(ctx context.Context, token *RefreshToken) error {
 	_, err := r.collection.InsertOne(ctx, token)
 	return err
 }
 
+// GetByHash also returns revoked tokens so reuse of a rotated one can be detected
 func (r *mongoRefreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*RefreshToken, error) {
 	var token RefreshToken
 	err := r.collection.FindOne(ctx, bson.M{
 		"token_hash": tokenHash,
-		"revoked":    false,
 	}).Decode(&token)
 	
 	if err == mongo.ErrNoDocuments {
-		return nil, nil
+		return nil, ErrInvalidRefreshToken
 	}
-	return &token, err
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRotated retires a token that is being replaced by successorID. It
+// fails with ErrRefreshTokenReused if the token was already retired, so two
+// concurrent refreshes with the same token cannot both succeed.
+func (r *mongoRefreshTokenRepo) MarkRotated(ctx context.Context, id, successorID uuid.UUID, successorSeal []byte) error {
+	update := bson.M{"$set": bson.M{
+		"revoked":        true,
+		"rotated_at":     time.Now(),
+		"successor_id":   successorID,
+		"successor_seal": successorSeal,
+	}}
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "revoked": false}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
 }
 
 func (r *mongoRefreshTokenRepo) Revoke(ctx context.Context, tokenHash string) error {
@@ -72,3 +121,66 @@ func (r *mongoRefreshTokenRepo) RevokeAllUserTokens(ctx context.Context, userID
 	_, err := r.collection.UpdateMany(ctx, bson.M{"user_id": userID}, update)
 	return err
 }
+
+func (r *mongoRefreshTokenRepo) RevokeDeviceTokens(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	_, err := r.collection.UpdateMany(ctx, bson.M{"user_id": userID, "device_id": deviceID}, update)
+	return err
+}
+
+// ListActive returns the current token of every live session
+func (r *mongoRefreshTokenRepo) ListActive(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error) {
+	filter := bson.M{
+		"user_id":    userID,
+		"revoked":    false,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []*RefreshToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// familyFilter matches a family; tokens issued before families were
+// tracked are their own family
+func familyFilter(familyID uuid.UUID) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"family_id": familyID},
+		bson.M{"_id": familyID, "family_id": bson.M{"$exists": false}},
+	}}
+}
+
+func (r *mongoRefreshTokenRepo) RevokeFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	filter := familyFilter(familyID)
+	filter["user_id"] = userID
+	filter["revoked"] = false
+
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	res, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *mongoRefreshTokenRepo) RevokeAllExceptFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	filter := bson.M{
+		"user_id": userID,
+		"revoked": false,
+		"$nor":    bson.A{familyFilter(familyID)},
+	}
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
@@ -2,53 +2,199 @@ package auth
 
 import (
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/users"
 )
 
+// RotationGracePeriod is how long a rotated refresh token may be presented
+// again, e.g. by a second tab refreshing at the same time, and still get the
+// same successor instead of being treated as stolen
+const RotationGracePeriod = 30 * time.Second
+
+// Hub interface for telling a user's connected clients about their sessions
+type Hub interface {
+	SendToUser(userID string, message interface{})
+	DisconnectSession(userID, sessionID string)
+	DisconnectAllSessions(userID, keepSessionID string)
+}
+
+// ClientInfo describes the client a refresh token was last used from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// Session is a login as shown to the user: one refresh token family
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	DeviceID   string    `json:"device_id,omitempty"`
+	DeviceName string    `json:"device_name,omitempty"`
+	Platform   string    `json:"platform,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
 // RefreshTokenManager manages refresh tokens
 type RefreshTokenManager struct {
-	repo RefreshTokenRepo
-	ttl  time.Duration
+	repo     RefreshTokenRepo
+	ttl      time.Duration
+	userRepo users.UserRepo
+	email    EmailSender
+	hub      Hub
+	audit    *audit.Logger
 }
 
-func NewRefreshTokenManager(repo RefreshTokenRepo, ttl time.Duration) *RefreshTokenManager {
-	return &RefreshTokenManager{repo: repo, ttl: ttl}
+func NewRefreshTokenManager(repo RefreshTokenRepo, ttl time.Duration, userRepo users.UserRepo, email EmailSender, hub Hub, audit *audit.Logger) *RefreshTokenManager {
+	return &RefreshTokenManager{repo: repo, ttl: ttl, userRepo: userRepo, email: email, hub: hub, audit: audit}
 }
 
-func (m *RefreshTokenManager) Generate(ctx context.Context, userID uuid.UUID) (string, error) {
-	// Generate random token
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		return "", err
+// Generate issues a refresh token bound to one of the user's devices,
+// starting a new token family (session). amr records how the user logged
+// in so refreshes keep the same claims.
+func (m *RefreshTokenManager) Generate(ctx context.Context, userID uuid.UUID, deviceID string, amr []string, client ClientInfo) (string, *RefreshToken, error) {
+	id := uuid.New()
+	return m.issue(ctx, &RefreshToken{
+		ID:               id,
+		UserID:           userID,
+		FamilyID:         id,
+		DeviceID:         deviceID,
+		AMR:              amr,
+		SessionStartedAt: time.Now(),
+		IPAddress:        client.IPAddress,
+		UserAgent:        client.UserAgent,
+	})
+}
+
+// Rotate exchanges a refresh token for its successor in the same family.
+// Presenting a token that was already rotated means it leaked: whoever
+// refreshed first may be an attacker, so every session of the user is
+// revoked and the user is warned. Within RotationGracePeriod the client gets
+// the same successor again instead.
+func (m *RefreshTokenManager) Rotate(ctx context.Context, token string, client ClientInfo) (*RefreshToken, string, error) {
+	current, err := m.repo.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		return nil, "", err
 	}
-	token := hex.EncodeToString(tokenBytes)
-	
-	// Hash for storage
-	tokenHash := hashToken(token)
-	
-	expiresAt := time.Now().Add(m.ttl)
-	return token, m.repo.Create(ctx, userID, tokenHash, expiresAt)
+	if current.RotatedAt != nil {
+		return m.rotatedAgain(ctx, current, token)
+	}
+	if current.Revoked {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, "", ErrRefreshTokenExpired
+	}
+
+	// Tokens issued before families were tracked start their own
+	family := current.SessionID()
+	started := current.SessionStartedAt
+	if started.IsZero() {
+		started = current.CreatedAt
+	}
+
+	// The successor is stored first so a concurrent refresh that loses the
+	// race below can already find it
+	newToken, next, err := m.issue(ctx, &RefreshToken{
+		ID:               uuid.New(),
+		UserID:           current.UserID,
+		FamilyID:         family,
+		ParentID:         &current.ID,
+		DeviceID:         current.DeviceID,
+		AMR:              current.AMR,
+		SessionStartedAt: started,
+		IPAddress:        client.IPAddress,
+		UserAgent:        client.UserAgent,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	seal, err := sealSuccessor(token, newToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := m.repo.MarkRotated(ctx, current.ID, next.ID, seal); err != nil {
+		if rerr := m.repo.Revoke(ctx, next.TokenHash); rerr != nil {
+			log.Printf("Failed to revoke unused refresh token %s: %v", next.ID, rerr)
+		}
+		if err != ErrRefreshTokenReused {
+			return nil, "", err
+		}
+		// Another refresh with the same token won
+		if current, err = m.repo.GetByHash(ctx, hashToken(token)); err != nil {
+			return nil, "", err
+		}
+		return m.rotatedAgain(ctx, current, token)
+	}
+	return next, newToken, nil
+}
+
+// rotatedAgain answers a token that was already exchanged: with its
+// successor within the grace period, as reuse otherwise
+func (m *RefreshTokenManager) rotatedAgain(ctx context.Context, current *RefreshToken, token string) (*RefreshToken, string, error) {
+	if current.RotatedAt == nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+	if time.Since(*current.RotatedAt) <= RotationGracePeriod {
+		if next, successor, ok := m.successor(ctx, current, token); ok {
+			return next, successor, nil
+		}
+	}
+	m.handleReuse(ctx, current)
+	return nil, "", ErrRefreshTokenReused
+}
+
+// successor returns the token current was rotated to, as long as that is
+// still the live token of the session
+func (m *RefreshTokenManager) successor(ctx context.Context, current *RefreshToken, token string) (*RefreshToken, string, bool) {
+	if len(current.SuccessorSeal) == 0 {
+		return nil, "", false
+	}
+	successor, err := openSuccessor(token, current.SuccessorSeal)
+	if err != nil {
+		return nil, "", false
+	}
+	next, err := m.repo.GetByHash(ctx, hashToken(successor))
+	if err != nil || next.Revoked || time.Now().After(next.ExpiresAt) {
+		return nil, "", false
+	}
+	return next, successor, true
 }
 
-func (m *RefreshTokenManager) Verify(ctx context.Context, token string) (uuid.UUID, error) {
+// Verify returns the stored refresh token if it can still be used
+func (m *RefreshTokenManager) Verify(ctx context.Context, token string) (*RefreshToken, error) {
 	tokenHash := hashToken(token)
 	
 	refreshToken, err := m.repo.GetByHash(ctx, tokenHash)
-	if err != nil || refreshToken == nil {
-		return uuid.UUID{}, err
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken.Revoked {
+		return nil, ErrInvalidRefreshToken
 	}
 	
 	// Check expiry
 	if time.Now().After(refreshToken.ExpiresAt) {
-		return uuid.UUID{}, err
+		return nil, ErrRefreshTokenExpired
 	}
 	
-	return refreshToken.UserID, nil
+	return refreshToken, nil
 }
 
 func (m *RefreshTokenManager) Revoke(ctx context.Context, token string) error {
@@ -56,6 +202,198 @@ func (m *RefreshTokenManager) Revoke(ctx context.Context, token string) error {
 	return m.repo.Revoke(ctx, tokenHash)
 }
 
+// RevokeDevice revokes every refresh token issued to a device
+func (m *RefreshTokenManager) RevokeDevice(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	return m.repo.RevokeDeviceTokens(ctx, userID, deviceID)
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+// currentID marks the session the request was made from.
+func (m *RefreshTokenManager) ListSessions(ctx context.Context, userID uuid.UUID, currentID string) ([]*Session, error) {
+	tokens, err := m.repo.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(tokens))
+	for _, t := range tokens {
+		s := &Session{
+			ID:         t.SessionID(),
+			DeviceID:   t.DeviceID,
+			IPAddress:  t.IPAddress,
+			UserAgent:  t.UserAgent,
+			CreatedAt:  t.SessionStartedAt,
+			LastUsedAt: t.CreatedAt,
+			ExpiresAt:  t.ExpiresAt,
+		}
+		if s.CreatedAt.IsZero() {
+			s.CreatedAt = t.CreatedAt
+		}
+		s.Current = s.ID.String() == currentID
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// SessionActive reports whether a session can still be refreshed
+func (m *RefreshTokenManager) SessionActive(ctx context.Context, userID uuid.UUID, sessionID string) (bool, error) {
+	tokens, err := m.repo.ListActive(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, t := range tokens {
+		if t.SessionID().String() == sessionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RevokeSession signs one session out and closes its WebSocket connections
+func (m *RefreshTokenManager) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	if err := m.repo.RevokeFamily(ctx, userID, sessionID); err != nil {
+		return err
+	}
+	if m.hub != nil {
+		m.hub.DisconnectSession(userID.String(), sessionID.String())
+	}
+	m.logSessionRevoke(ctx, userID, "refresh_token_family:"+sessionID.String(), "Signed out one session")
+	return nil
+}
+
+// RevokeOtherSessions signs out everywhere except the given session
+func (m *RefreshTokenManager) RevokeOtherSessions(ctx context.Context, userID, keepID uuid.UUID) error {
+	if err := m.repo.RevokeAllExceptFamily(ctx, userID, keepID); err != nil {
+		return err
+	}
+	if m.hub != nil {
+		m.hub.DisconnectAllSessions(userID.String(), keepID.String())
+	}
+	m.logSessionRevoke(ctx, userID, "user:"+userID.String(), "Signed out all other sessions")
+	return nil
+}
+
+// RevokeAllSessions signs the user out everywhere
+func (m *RefreshTokenManager) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	if err := m.repo.RevokeAllUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	if m.hub != nil {
+		m.hub.DisconnectAllSessions(userID.String(), "")
+	}
+	m.logSessionRevoke(ctx, userID, "user:"+userID.String(), "Signed out all sessions")
+	return nil
+}
+
+func (m *RefreshTokenManager) logSessionRevoke(ctx context.Context, userID uuid.UUID, resource, details string) {
+	m.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventSessionRevoked,
+		Resource: resource,
+		Result:   "success",
+		Details:  details,
+	})
+}
+
+// issue fills in a new token's secret and lifetime and stores it
+func (m *RefreshTokenManager) issue(ctx context.Context, rt *RefreshToken) (string, *RefreshToken, error) {
+	// Generate random token
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	// Hash for storage
+	rt.TokenHash = hashToken(token)
+	rt.CreatedAt = time.Now()
+	rt.ExpiresAt = rt.CreatedAt.Add(m.ttl)
+
+	if err := m.repo.Create(ctx, rt); err != nil {
+		return "", nil, err
+	}
+	return token, rt, nil
+}
+
+// handleReuse revokes every session of a user whose rotated refresh token
+// was presented again, and tells them about it
+func (m *RefreshTokenManager) handleReuse(ctx context.Context, rt *RefreshToken) {
+	if err := m.repo.RevokeAllUserTokens(ctx, rt.UserID); err != nil {
+		log.Printf("Failed to revoke sessions of user %s after token reuse: %v", rt.UserID, err)
+	}
+
+	userID := rt.UserID
+	m.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventRefreshTokenReuse,
+		Resource: "refresh_token_family:" + rt.FamilyID.String(),
+		Result:   "failure",
+		Details:  fmt.Sprintf("Rotated refresh token %s presented again; all sessions revoked", rt.ID),
+	})
+
+	// Queued messages are written before the close frame, so clients see
+	// the reason they were disconnected
+	if m.hub != nil {
+		m.hub.SendToUser(userID.String(), map[string]interface{}{
+			"type":   "SESSIONS_REVOKED",
+			"reason": "refresh_token_reuse",
+		})
+		m.hub.DisconnectAllSessions(userID.String(), "")
+	}
+
+	if m.email == nil {
+		return
+	}
+	user, err := m.userRepo.GetByID(ctx, userID)
+	if err != nil || user.Email == "" {
+		return
+	}
+	body := "Someone tried to use an old sign-in token for your Telegraph account. " +
+		"As a precaution you have been signed out on all devices. " +
+		"If this wasn't you, change your password."
+	if err := m.email.Send(user.Email, "Telegraph: you were signed out everywhere", body); err != nil {
+		log.Printf("Failed to send security email to user %s: %v", userID, err)
+	}
+}
+
+// sealSuccessor encrypts the successor token with a key derived from the
+// token it replaces, which the server only stores hashed
+func sealSuccessor(token, successor string) ([]byte, error) {
+	gcm, err := successorCipher(token)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, []byte(successor), nil), nil
+}
+
+func openSuccessor(token string, seal []byte) (string, error) {
+	gcm, err := successorCipher(token)
+	if err != nil {
+		return "", err
+	}
+	if len(seal) < gcm.NonceSize() {
+		return "", errors.New("successor seal too short")
+	}
+	plain, err := gcm.Open(nil, seal[:gcm.NonceSize()], seal[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func successorCipher(token string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("refresh-successor:" + token))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 func hashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(hash[:])
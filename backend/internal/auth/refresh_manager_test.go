@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+)
+
+// memoryRefreshRepo keeps refresh tokens in memory for tests
+type memoryRefreshRepo struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*RefreshToken
+}
+
+func newMemoryRefreshRepo() *memoryRefreshRepo {
+	return &memoryRefreshRepo{tokens: map[uuid.UUID]*RefreshToken{}}
+}
+
+func (r *memoryRefreshRepo) Create(ctx context.Context, t *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *t
+	r.tokens[t.ID] = &copied
+	return nil
+}
+
+func (r *memoryRefreshRepo) GetByHash(ctx context.Context, tokenHash string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, ErrInvalidRefreshToken
+}
+
+func (r *memoryRefreshRepo) MarkRotated(ctx context.Context, id, successorID uuid.UUID, successorSeal []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[id]
+	if !ok || t.Revoked {
+		return ErrRefreshTokenReused
+	}
+	now := time.Now()
+	t.Revoked = true
+	t.RotatedAt = &now
+	t.SuccessorID = &successorID
+	t.SuccessorSeal = successorSeal
+	return nil
+}
+
+func (r *memoryRefreshRepo) Revoke(ctx context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash {
+			t.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshRepo) RevokeAllUserTokens(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			t.Revoked = true
+		}
+	}
+	return nil
+}
+
+func (r *memoryRefreshRepo) RevokeDeviceTokens(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	return nil
+}
+
+func (r *memoryRefreshRepo) ListActive(ctx context.Context, userID uuid.UUID) ([]*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var active []*RefreshToken
+	for _, t := range r.tokens {
+		if t.UserID == userID && !t.Revoked {
+			active = append(active, t)
+		}
+	}
+	return active, nil
+}
+
+func (r *memoryRefreshRepo) RevokeFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	return nil
+}
+
+func (r *memoryRefreshRepo) RevokeAllExceptFamily(ctx context.Context, userID, familyID uuid.UUID) error {
+	return nil
+}
+
+// age moves a token's rotation into the past
+func (r *memoryRefreshRepo) age(id uuid.UUID, by time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rotated := r.tokens[id].RotatedAt.Add(-by)
+	r.tokens[id].RotatedAt = &rotated
+}
+
+func TestRotate_RetryWithinGraceGetsSameSuccessor(t *testing.T) {
+	repo := newMemoryRefreshRepo()
+	m := NewRefreshTokenManager(repo, time.Hour, nil, nil, nil, &audit.Logger{})
+	ctx := context.Background()
+	userID := uuid.New()
+
+	token, _, err := m.Generate(ctx, userID, "phone", nil, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, firstToken, err := m.Rotate(ctx, token, ClientInfo{})
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+	second, secondToken, err := m.Rotate(ctx, token, ClientInfo{})
+	if err != nil {
+		t.Fatalf("refresh within the grace period: %v", err)
+	}
+	if second.ID != first.ID || secondToken != firstToken {
+		t.Fatal("retry got a different successor")
+	}
+	if active, _ := repo.ListActive(ctx, userID); len(active) != 1 {
+		t.Fatalf("%d active tokens, want 1", len(active))
+	}
+}
+
+func TestRotate_ConcurrentRefreshesShareSuccessor(t *testing.T) {
+	repo := newMemoryRefreshRepo()
+	m := NewRefreshTokenManager(repo, time.Hour, nil, nil, nil, &audit.Logger{})
+	ctx := context.Background()
+	userID := uuid.New()
+
+	token, _, err := m.Generate(ctx, userID, "phone", nil, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 4)
+	errs := make([]error, 4)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, tokens[i], errs[i] = m.Rotate(ctx, token, ClientInfo{})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range tokens {
+		if errs[i] != nil {
+			t.Fatalf("refresh %d: %v", i, errs[i])
+		}
+		if tokens[i] != tokens[0] {
+			t.Fatal("concurrent refreshes got different successors")
+		}
+	}
+}
+
+func TestRotate_ReuseAfterGraceRevokesEverything(t *testing.T) {
+	repo := newMemoryRefreshRepo()
+	m := NewRefreshTokenManager(repo, time.Hour, nil, nil, nil, &audit.Logger{})
+	ctx := context.Background()
+	userID := uuid.New()
+
+	token, rt, err := m.Generate(ctx, userID, "phone", nil, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Rotate(ctx, token, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	repo.age(rt.ID, RotationGracePeriod+time.Second)
+
+	if _, _, err := m.Rotate(ctx, token, ClientInfo{}); err != ErrRefreshTokenReused {
+		t.Fatalf("got %v, want ErrRefreshTokenReused", err)
+	}
+	if active, _ := repo.ListActive(ctx, userID); len(active) != 0 {
+		t.Fatalf("%d tokens still active after reuse", len(active))
+	}
+}
+
+func TestRotate_GraceEndsOnceSuccessorIsUsed(t *testing.T) {
+	repo := newMemoryRefreshRepo()
+	m := NewRefreshTokenManager(repo, time.Hour, nil, nil, nil, &audit.Logger{})
+	ctx := context.Background()
+	userID := uuid.New()
+
+	token, _, err := m.Generate(ctx, userID, "phone", nil, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, next, err := m.Rotate(ctx, token, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Rotate(ctx, next, ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := m.Rotate(ctx, token, ClientInfo{}); err != ErrRefreshTokenReused {
+		t.Fatalf("got %v, want ErrRefreshTokenReused", err)
+	}
+}
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/ratelimit"
+	"telegraph/internal/users"
+)
+
+// resetTTL bounds how long an emailed password reset token stays valid
+const resetTTL = 30 * time.Minute
+
+// Forgot-password limits, so nobody can flood an inbox with reset emails
+var (
+	resetEmailTiers = []ratelimit.Tier{{Limit: 3, Window: time.Hour}}
+	resetIPTiers    = []ratelimit.Tier{{Limit: 20, Window: time.Hour}}
+)
+
+// PasswordReset is an outstanding reset request. Only the token hash is stored.
+type PasswordReset struct {
+	TokenHash string    `bson:"_id"`
+	UserID    uuid.UUID `bson:"user_id"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type PasswordResetRepo interface {
+	Create(ctx context.Context, pr *PasswordReset) error
+	Consume(ctx context.Context, tokenHash string) (*PasswordReset, error)
+	DeleteForUser(ctx context.Context, userID uuid.UUID) error
+}
+
+type mongoPasswordResetRepo struct {
+	collection *mongo.Collection
+}
+
+func NewPasswordResetRepo(db *mongo.Database) PasswordResetRepo {
+	return &mongoPasswordResetRepo{
+		collection: db.Collection("password_resets"),
+	}
+}
+
+func (r *mongoPasswordResetRepo) Create(ctx context.Context, pr *PasswordReset) error {
+	_, err := r.collection.InsertOne(ctx, pr)
+	return err
+}
+
+// Consume deletes and returns a reset so each token works at most once
+func (r *mongoPasswordResetRepo) Consume(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	var pr PasswordReset
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": tokenHash}).Decode(&pr)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+func (r *mongoPasswordResetRepo) DeleteForUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// SessionRevoker signs a user out everywhere
+type SessionRevoker interface {
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+// PasswordResetManager runs the forgot-password flow
+type PasswordResetManager struct {
+	repo     PasswordResetRepo
+	userSvc  users.UserService
+	sessions SessionRevoker
+	sender   EmailSender
+	audit    *audit.Logger
+	limiter  *ratelimit.Limiter
+}
+
+func NewPasswordResetManager(repo PasswordResetRepo, userSvc users.UserService, sessions SessionRevoker, sender EmailSender, audit *audit.Logger) *PasswordResetManager {
+	return &PasswordResetManager{
+		repo:     repo,
+		userSvc:  userSvc,
+		sessions: sessions,
+		sender:   sender,
+		audit:    audit,
+		limiter:  ratelimit.NewLimiter(),
+	}
+}
+
+// AllowRequest counts a forgot-password request against the email and the
+// client IP and reports how long to wait when either is over its limit.
+// It applies to every address, registered or not.
+func (m *PasswordResetManager) AllowRequest(email, ip string) time.Duration {
+	emailKey := "email:" + strings.ToLower(strings.TrimSpace(email))
+	at, wait := m.limiter.Reserve(emailKey, resetEmailTiers...)
+	if wait > 0 {
+		return wait
+	}
+	if ip != "" {
+		if _, wait := m.limiter.Reserve("ip:"+ip, resetIPTiers...); wait > 0 {
+			m.limiter.Release(emailKey, at)
+			return wait
+		}
+	}
+	return 0
+}
+
+// RequestReset emails a reset token to the account registered under email.
+// Unknown addresses, bots and suspended accounts are silently ignored so the
+// caller cannot tell which emails are registered.
+func (m *PasswordResetManager) RequestReset(ctx context.Context, email string) error {
+	u, err := m.userSvc.GetByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil || u.Bot || u.Suspended {
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(buf)
+
+	// A new request supersedes any earlier link
+	if err := m.repo.DeleteForUser(ctx, u.ID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = m.repo.Create(ctx, &PasswordReset{
+		TokenHash: hashToken(token),
+		UserID:    u.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(resetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	body := "Someone asked to reset the password of your Telegraph account.\n\n" +
+		"Your reset token: " + token + "\n\n" +
+		"It expires in 30 minutes. If this wasn't you, ignore this email."
+	if err := m.sender.Send(u.Email, "Telegraph: reset your password", body); err != nil {
+		return err
+	}
+
+	userID := u.ID
+	m.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventPasswordResetRequested,
+		Resource: "user:" + userID.String(),
+		Result:   "success",
+	})
+	return nil
+}
+
+// ResetPassword sets a new password using an emailed token and signs the
+// user out everywhere. If the sign-out fails the token is put back so the
+// client can retry; a reset must not leave a hijacker's sessions alive.
+func (m *PasswordResetManager) ResetPassword(ctx context.Context, token, password string) error {
+	if len(password) < users.MinPasswordLength {
+		return users.ErrPasswordTooShort
+	}
+
+	pr, err := m.repo.Consume(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if time.Now().After(pr.ExpiresAt) {
+		return ErrResetTokenExpired
+	}
+
+	userID := pr.UserID
+	if err := m.userSvc.SetPassword(ctx, userID, password); err != nil {
+		if err == users.ErrUserNotFound {
+			return ErrResetTokenInvalid
+		}
+		return err
+	}
+	if err := m.sessions.RevokeAllSessions(ctx, userID); err != nil {
+		m.restore(ctx, pr)
+		return err
+	}
+	if err := m.repo.DeleteForUser(ctx, userID); err != nil {
+		m.restore(ctx, pr)
+		return err
+	}
+
+	m.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventPasswordReset,
+		Resource: "user:" + userID.String(),
+		Result:   "success",
+		Details:  "Password reset by email; all sessions revoked",
+	})
+
+	if u, err := m.userSvc.GetByID(ctx, userID); err == nil && u.Email != "" {
+		body := "The password of your Telegraph account was just reset and you were signed out on all devices. " +
+			"If this wasn't you, contact support immediately."
+		if err := m.sender.Send(u.Email, "Telegraph: your password was changed", body); err != nil {
+			log.Printf("Failed to send security email to user %s: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// restore puts a consumed reset back after a failure later in ResetPassword
+func (m *PasswordResetManager) restore(ctx context.Context, pr *PasswordReset) {
+	if err := m.repo.Create(ctx, pr); err != nil {
+		log.Printf("Failed to restore password reset of user %s: %v", pr.UserID, err)
+	}
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/users"
+)
+
+// memoryResetRepo keeps password resets in memory for tests
+type memoryResetRepo struct {
+	mu     sync.Mutex
+	resets map[string]*PasswordReset
+}
+
+func (r *memoryResetRepo) Create(ctx context.Context, pr *PasswordReset) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *pr
+	r.resets[pr.TokenHash] = &copied
+	return nil
+}
+
+func (r *memoryResetRepo) Consume(ctx context.Context, tokenHash string) (*PasswordReset, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pr, ok := r.resets[tokenHash]
+	if !ok {
+		return nil, ErrResetTokenInvalid
+	}
+	delete(r.resets, tokenHash)
+	return pr, nil
+}
+
+func (r *memoryResetRepo) DeleteForUser(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, pr := range r.resets {
+		if pr.UserID == userID {
+			delete(r.resets, hash)
+		}
+	}
+	return nil
+}
+
+// resetUsers implements the lookups the reset flow needs
+type resetUsers struct {
+	users.UserService
+	user     *users.User
+	password string
+}
+
+func (s *resetUsers) GetByEmail(ctx context.Context, email string) (*users.User, error) {
+	if email != s.user.Email {
+		return nil, users.ErrUserNotFound
+	}
+	return s.user, nil
+}
+
+func (s *resetUsers) GetByID(ctx context.Context, id uuid.UUID) (*users.User, error) {
+	if id != s.user.ID {
+		return nil, users.ErrUserNotFound
+	}
+	return s.user, nil
+}
+
+func (s *resetUsers) SetPassword(ctx context.Context, id uuid.UUID, pw string) error {
+	s.password = pw
+	return nil
+}
+
+type recordingSender struct {
+	bodies []string
+}
+
+func (s *recordingSender) Send(to, subject, body string) error {
+	s.bodies = append(s.bodies, body)
+	return nil
+}
+
+// token extracts the reset token from the first email sent
+func (s *recordingSender) token(t *testing.T) string {
+	t.Helper()
+	if len(s.bodies) == 0 {
+		t.Fatal("no reset email was sent")
+	}
+	_, rest, ok := strings.Cut(s.bodies[0], "Your reset token: ")
+	if !ok {
+		t.Fatalf("reset email has no token: %q", s.bodies[0])
+	}
+	token, _, _ := strings.Cut(rest, "\n")
+	return token
+}
+
+type fakeRevoker struct {
+	err     error
+	revoked int
+}
+
+func (f *fakeRevoker) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.revoked++
+	return nil
+}
+
+func newTestResetManager() (*PasswordResetManager, *resetUsers, *recordingSender, *fakeRevoker) {
+	u := &resetUsers{user: &users.User{ID: uuid.New(), Email: "ada@example.com"}}
+	sender := &recordingSender{}
+	revoker := &fakeRevoker{}
+	repo := &memoryResetRepo{resets: map[string]*PasswordReset{}}
+	return NewPasswordResetManager(repo, u, revoker, sender, &audit.Logger{}), u, sender, revoker
+}
+
+func TestResetPassword_TokenWorksOnce(t *testing.T) {
+	m, u, sender, revoker := newTestResetManager()
+	ctx := context.Background()
+
+	if err := m.RequestReset(ctx, "Ada@Example.com"); err != nil {
+		t.Fatalf("RequestReset: %v", err)
+	}
+	token := sender.token(t)
+
+	if err := m.ResetPassword(ctx, token, "correct horse battery"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if u.password != "correct horse battery" || revoker.revoked != 1 {
+		t.Fatalf("password %q, revoked %d times", u.password, revoker.revoked)
+	}
+	if err := m.ResetPassword(ctx, token, "another password"); err != ErrResetTokenInvalid {
+		t.Fatalf("reused token: got %v, want ErrResetTokenInvalid", err)
+	}
+}
+
+func TestResetPassword_RevokeFailureKeepsToken(t *testing.T) {
+	m, _, sender, revoker := newTestResetManager()
+	ctx := context.Background()
+
+	if err := m.RequestReset(ctx, "ada@example.com"); err != nil {
+		t.Fatalf("RequestReset: %v", err)
+	}
+	token := sender.token(t)
+
+	revoker.err = errors.New("database unavailable")
+	if err := m.ResetPassword(ctx, token, "correct horse battery"); err != revoker.err {
+		t.Fatalf("got %v, want the revoke error", err)
+	}
+
+	revoker.err = nil
+	if err := m.ResetPassword(ctx, token, "correct horse battery"); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if revoker.revoked != 1 {
+		t.Fatalf("sessions revoked %d times, want 1", revoker.revoked)
+	}
+}
+
+func TestRequestReset_UnknownEmailSendsNothing(t *testing.T) {
+	m, _, sender, _ := newTestResetManager()
+
+	if err := m.RequestReset(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("RequestReset: %v", err)
+	}
+	if len(sender.bodies) != 0 {
+		t.Fatalf("sent %d emails for an unknown address", len(sender.bodies))
+	}
+}
+
+func TestAllowRequest_LimitsPerEmailAndIP(t *testing.T) {
+	m, _, _, _ := newTestResetManager()
+
+	for i := 0; i < resetEmailTiers[0].Limit; i++ {
+		if wait := m.AllowRequest("ada@example.com", "203.0.113.7"); wait > 0 {
+			t.Fatalf("request %d throttled", i+1)
+		}
+	}
+	if wait := m.AllowRequest(" ADA@example.com", "198.51.100.1"); wait <= 0 {
+		t.Fatal("expected the email to be throttled regardless of case or IP")
+	}
+
+	for i := 0; i < resetIPTiers[0].Limit-resetEmailTiers[0].Limit; i++ {
+		m.AllowRequest(uuid.NewString()+"@example.com", "203.0.113.7")
+	}
+	if wait := m.AllowRequest("grace@example.com", "203.0.113.7"); wait <= 0 {
+		t.Fatal("expected the IP to be throttled")
+	}
+	if wait := m.AllowRequest("grace@example.com", "198.51.100.1"); wait > 0 {
+		t.Fatal("a throttled IP must not use up the email's allowance")
+	}
+}
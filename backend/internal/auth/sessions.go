@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"telegraph/internal/users"
+)
+
+// ListSessions returns the caller's active sessions
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	uid, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.refresh.ListSessions(r.Context(), uid, users.SessionIDFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, "sessions_error", 500)
+		return
+	}
+
+	// Name sessions after the devices they were opened on
+	if devs, err := h.devices.List(r.Context(), uid); err == nil {
+		for _, s := range sessions {
+			for _, d := range devs {
+				if d.DeviceID == s.DeviceID {
+					s.DeviceName = d.Name
+					s.Platform = string(d.Platform)
+					break
+				}
+			}
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession signs out a single session
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	uid, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid_session_id", 400)
+		return
+	}
+
+	if err := h.refresh.RevokeSession(r.Context(), uid, sessionID); err != nil {
+		if err == ErrSessionNotFound {
+			http.Error(w, err.Error(), 404)
+			return
+		}
+		http.Error(w, "sessions_error", 500)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions signs out every session except the caller's
+func (h *Handler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	uid, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := uuid.Parse(users.SessionIDFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, "no_current_session", 400)
+		return
+	}
+
+	if err := h.refresh.RevokeOtherSessions(r.Context(), uid, current); err != nil {
+		http.Error(w, "sessions_error", 500)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllSessions signs out every session, including the caller's
+func (h *Handler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	uid, ok := sessionUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.refresh.RevokeAllSessions(r.Context(), uid); err != nil {
+		http.Error(w, "sessions_error", 500)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sessionUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	uid, err := uuid.Parse(users.UserIDFromContext(r.Context()))
+	if err != nil {
+		http.Error(w, "unauthorized", 401)
+		return uuid.Nil, false
+	}
+	return uid, true
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/ratelimit"
+)
+
+// Each tier is stricter about the rate and longer about the wait, so
+// repeated failures are slowed down progressively until the key is locked out
+var (
+	accountTiers = []ratelimit.Tier{
+		{Limit: 3, Window: 30 * time.Second},
+		{Limit: 5, Window: 5 * time.Minute},
+		{Limit: 10, Window: 15 * time.Minute}, // lockout
+	}
+	ipTiers = []ratelimit.Tier{
+		{Limit: 20, Window: time.Minute},
+		{Limit: 100, Window: time.Hour}, // lockout
+	}
+	// codeSendTiers limits emailed second factor codes per account, since
+	// each new code also starts a fresh attempt count
+	codeSendTiers = []ratelimit.Tier{
+		{Limit: 1, Window: 30 * time.Second},
+		{Limit: 5, Window: time.Hour},
+	}
+)
+
+// LoginGuard counts failed password and second factor attempts per account
+// and per client IP and tells callers how long to back off
+type LoginGuard struct {
+	limiter *ratelimit.Limiter
+	audit   *audit.Logger
+}
+
+func NewLoginGuard(audit *audit.Logger) *LoginGuard {
+	return &LoginGuard{limiter: ratelimit.NewLimiter(), audit: audit}
+}
+
+// Attempt is a login or second factor attempt that holds a failure slot for
+// its account and IP until it ends with Succeed, Fail or Release
+type Attempt struct {
+	guard     *LoginGuard
+	account   string
+	ip        string
+	accountAt time.Time
+	ipAt      time.Time
+}
+
+// Begin reserves an attempt before the credentials are checked. The slot is
+// counted as a failure straight away, so a parallel burst cannot all pass
+// the check before the first failure is recorded. A non-zero wait means the
+// account or IP must back off and no attempt was reserved.
+func (g *LoginGuard) Begin(account, ip string) (*Attempt, time.Duration) {
+	a := &Attempt{guard: g, account: account, ip: ip}
+	var wait time.Duration
+	if a.accountAt, wait = g.limiter.Reserve("account:"+account, accountTiers...); wait > 0 {
+		return nil, wait
+	}
+	if ip != "" {
+		if a.ipAt, wait = g.limiter.Reserve("ip:"+ip, ipTiers...); wait > 0 {
+			g.limiter.Release("account:"+account, a.accountAt)
+			return nil, wait
+		}
+	}
+	return a, 0
+}
+
+// Fail keeps the reserved slot as a failure and audits it. userID is nil
+// when the account is unknown.
+func (a *Attempt) Fail(ctx context.Context, event audit.EventType, userID *uuid.UUID, reason string) {
+	a.guard.audit.Log(ctx, audit.AuditLog{
+		UserID:    userID,
+		Action:    event,
+		Resource:  a.account,
+		IPAddress: a.ip,
+		Result:    "failure",
+		Details:   reason,
+	})
+}
+
+// Succeed clears the account's failures. IP failures other than this
+// attempt's own slot are kept, so one valid login cannot be used to keep
+// guessing other accounts.
+func (a *Attempt) Succeed() {
+	a.guard.limiter.Reset("account:" + a.account)
+	a.Release()
+}
+
+// Release gives the reserved slots back for an attempt that ended without
+// a wrong guess, e.g. because of a server error
+func (a *Attempt) Release() {
+	a.guard.limiter.Release("account:"+a.account, a.accountAt)
+	if a.ip != "" {
+		a.guard.limiter.Release("ip:"+a.ip, a.ipAt)
+	}
+}
+
+// AllowCodeSend reports how long the account must wait before another
+// second factor code may be emailed. Zero means the send is allowed and was counted.
+func (g *LoginGuard) AllowCodeSend(account string) time.Duration {
+	_, wait := g.limiter.Reserve("send:"+account, codeSendTiers...)
+	return wait
+}
+
+// LoginAccount is the guard key for password logins
+func LoginAccount(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// MFAAccount is the guard key for second factor attempts
+func MFAAccount(userID uuid.UUID) string {
+	return "mfa:" + userID.String()
+}
+
+// respondThrottled rejects an attempt made while backing off
+func respondThrottled(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, ErrTooManyAttempts.Error(), http.StatusTooManyRequests)
+}
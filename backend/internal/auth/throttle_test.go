@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+
+	"telegraph/internal/audit"
+)
+
+func TestFailureTiersBackOff(t *testing.T) {
+	g := NewLoginGuard(&audit.Logger{})
+	account := LoginAccount("Alice@Example.com ")
+
+	for i := 0; i < accountTiers[0].Limit; i++ {
+		if _, wait := g.Begin(account, ""); wait != 0 {
+			t.Fatalf("attempt %d: unexpected wait %v", i+1, wait)
+		}
+	}
+
+	_, wait := g.Begin(account, "")
+	if wait <= 0 || wait > accountTiers[0].Window {
+		t.Fatalf("wait after first tier = %v", wait)
+	}
+
+	if _, other := g.Begin(LoginAccount("bob@example.com"), ""); other != 0 {
+		t.Fatalf("other account throttled for %v", other)
+	}
+}
+
+func TestBeginReservesConcurrentAttempts(t *testing.T) {
+	g := NewLoginGuard(&audit.Logger{})
+	account := LoginAccount("alice@example.com")
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, wait := g.Begin(account, "203.0.113.7"); wait == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != accountTiers[0].Limit {
+		t.Fatalf("%d parallel attempts got through, want %d", allowed, accountTiers[0].Limit)
+	}
+}
+
+func TestAttemptSucceedReleasesSlots(t *testing.T) {
+	g := NewLoginGuard(&audit.Logger{})
+	account := LoginAccount("alice@example.com")
+
+	for i := 0; i < ipTiers[0].Limit+5; i++ {
+		a, wait := g.Begin(account, "203.0.113.7")
+		if wait != 0 {
+			t.Fatalf("successful attempt %d throttled for %v", i+1, wait)
+		}
+		a.Succeed()
+	}
+}
+
+func TestLoginAccountNormalizesEmail(t *testing.T) {
+	if LoginAccount(" Alice@Example.COM") != LoginAccount("alice@example.com") {
+		t.Fatal("login account keys differ by case or whitespace")
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RFC 6238 parameters. These are the defaults every authenticator app
+// understands, so they are not configurable.
+const (
+	totpPeriod     = 30 * time.Second
+	totpDigits     = 6
+	totpSecretSize = 20 // 160 bits, as recommended by RFC 4226
+	totpSkew       = 1  // accept one step either side for clock drift
+
+	recoveryCodeCount = 10
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPManager handles authenticator-app enrolment and verification
+type TOTPManager struct {
+	repo   TOTPRepo
+	issuer string
+}
+
+func NewTOTPManager(repo TOTPRepo, issuer string) *TOTPManager {
+	return &TOTPManager{repo: repo, issuer: issuer}
+}
+
+// Setup creates a new unconfirmed secret and returns it with the
+// otpauth:// URI to show as a QR code. A pending setup is replaced.
+func (m *TOTPManager) Setup(ctx context.Context, uid uuid.UUID, account string) (string, string, error) {
+	buf := make([]byte, totpSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret := b32.EncodeToString(buf)
+
+	err := m.repo.SavePending(ctx, &TOTPEnrolment{
+		UserID:    uid,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return secret, ProvisioningURI(m.issuer, account, secret), nil
+}
+
+// Confirm activates a pending secret once the user proves their app
+// produces matching codes, and returns the initial recovery codes
+func (m *TOTPManager) Confirm(ctx context.Context, uid uuid.UUID, code string) ([]string, error) {
+	e, err := m.repo.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if e.Confirmed {
+		return nil, ErrTOTPAlreadyEnrolled
+	}
+
+	counter, ok := validateTOTP(e.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.repo.Confirm(ctx, uid, counter, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Enrolled reports whether the user has a confirmed authenticator
+func (m *TOTPManager) Enrolled(ctx context.Context, uid uuid.UUID) (bool, error) {
+	e, err := m.repo.Get(ctx, uid)
+	if err == ErrTOTPNotEnrolled {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return e.Confirmed, nil
+}
+
+// Verify checks a code from the user's authenticator. Each time step
+// can only be used once, so an observed code cannot be replayed.
+func (m *TOTPManager) Verify(ctx context.Context, uid uuid.UUID, code string) error {
+	e, err := m.repo.Get(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if !e.Confirmed {
+		return ErrTOTPNotEnrolled
+	}
+
+	counter, ok := validateTOTP(e.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidCode
+	}
+	return m.repo.AdvanceCounter(ctx, uid, counter)
+}
+
+// Disable removes the authenticator and its recovery codes
+func (m *TOTPManager) Disable(ctx context.Context, uid uuid.UUID, code string) error {
+	if err := m.Verify(ctx, uid, code); err != nil {
+		return err
+	}
+	return m.repo.Delete(ctx, uid)
+}
+
+// RegenerateRecoveryCodes replaces all unused recovery codes
+func (m *TOTPManager) RegenerateRecoveryCodes(ctx context.Context, uid uuid.UUID, code string) ([]string, error) {
+	if err := m.Verify(ctx, uid, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.repo.SetRecoveryCodes(ctx, uid, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode consumes one recovery code
+func (m *TOTPManager) UseRecoveryCode(ctx context.Context, uid uuid.UUID, code string) error {
+	return m.repo.UseRecoveryCode(ctx, uid, hashToken(normalizeRecoveryCode(code)))
+}
+
+// ProvisioningURI builds the Key URI Format understood by authenticator apps
+func ProvisioningURI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// validateTOTP returns the time step a code matches, checking the steps
+// either side of now to allow for clock skew
+func validateTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / int64(totpPeriod.Seconds())
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		want := hotp(key, uint64(step), totpDigits)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// hotp computes an RFC 4226 one-time password
+func hotp(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// newRecoveryCodes returns fresh codes for the user and their hashes for storage
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(b32.EncodeToString(buf)) // 8 characters
+		codes[i] = raw[:4] + "-" + raw[4:]
+		hashes[i] = hashToken(raw)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
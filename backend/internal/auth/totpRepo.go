@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TOTPEnrolment is a user's authenticator-app secret
+type TOTPEnrolment struct {
+	UserID      uuid.UUID  `bson:"_id"`
+	Secret      string     `bson:"secret"` // base32
+	Confirmed   bool       `bson:"confirmed"`
+	LastCounter int64      `bson:"last_counter"` // Last accepted time step, for replay prevention
+	CreatedAt   time.Time  `bson:"created_at"`
+	ConfirmedAt *time.Time `bson:"confirmed_at,omitempty"`
+
+	// SHA-256 hashes of the unused recovery codes
+	RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+}
+
+type TOTPRepo interface {
+	Get(ctx context.Context, uid uuid.UUID) (*TOTPEnrolment, error)
+	SavePending(ctx context.Context, e *TOTPEnrolment) error
+	Confirm(ctx context.Context, uid uuid.UUID, counter int64, recoveryHashes []string) error
+	AdvanceCounter(ctx context.Context, uid uuid.UUID, counter int64) error
+	SetRecoveryCodes(ctx context.Context, uid uuid.UUID, hashes []string) error
+	UseRecoveryCode(ctx context.Context, uid uuid.UUID, hash string) error
+	Delete(ctx context.Context, uid uuid.UUID) error
+}
+
+type mongoTOTPRepo struct {
+	collection *mongo.Collection
+}
+
+func NewTOTPRepo(db *mongo.Database) TOTPRepo {
+	return &mongoTOTPRepo{
+		collection: db.Collection("totp_secrets"),
+	}
+}
+
+func (r *mongoTOTPRepo) Get(ctx context.Context, uid uuid.UUID) (*TOTPEnrolment, error) {
+	var e TOTPEnrolment
+	err := r.collection.FindOne(ctx, bson.M{"_id": uid}).Decode(&e)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SavePending replaces an unconfirmed secret. If a confirmed one exists the
+// filter misses, the upsert collides on _id and the setup is rejected.
+func (r *mongoTOTPRepo) SavePending(ctx context.Context, e *TOTPEnrolment) error {
+	filter := bson.M{"_id": e.UserID, "confirmed": false}
+	opts := options.Replace().SetUpsert(true)
+
+	_, err := r.collection.ReplaceOne(ctx, filter, e, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrTOTPAlreadyEnrolled
+	}
+	return err
+}
+
+func (r *mongoTOTPRepo) Confirm(ctx context.Context, uid uuid.UUID, counter int64, recoveryHashes []string) error {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"confirmed":      true,
+		"confirmed_at":   now,
+		"last_counter":   counter,
+		"recovery_codes": recoveryHashes,
+	}}
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": uid, "confirmed": false}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrTOTPAlreadyEnrolled
+	}
+	return nil
+}
+
+// AdvanceCounter records a used time step; it only succeeds for steps newer
+// than the last one, which rejects replays even across concurrent requests
+func (r *mongoTOTPRepo) AdvanceCounter(ctx context.Context, uid uuid.UUID, counter int64) error {
+	filter := bson.M{
+		"_id":          uid,
+		"confirmed":    true,
+		"last_counter": bson.M{"$lt": counter},
+	}
+	update := bson.M{"$set": bson.M{"last_counter": counter}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrTOTPReplay
+	}
+	return nil
+}
+
+func (r *mongoTOTPRepo) SetRecoveryCodes(ctx context.Context, uid uuid.UUID, hashes []string) error {
+	update := bson.M{"$set": bson.M{"recovery_codes": hashes}}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": uid, "confirmed": true}, update)
+	return err
+}
+
+// UseRecoveryCode removes a code so it cannot be used twice
+func (r *mongoTOTPRepo) UseRecoveryCode(ctx context.Context, uid uuid.UUID, hash string) error {
+	filter := bson.M{"_id": uid, "confirmed": true, "recovery_codes": hash}
+	update := bson.M{"$pull": bson.M{"recovery_codes": hash}}
+
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return ErrInvalidRecoveryCode
+	}
+	return nil
+}
+
+func (r *mongoTOTPRepo) Delete(ctx context.Context, uid uuid.UUID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": uid})
+	return err
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B test vectors for SHA-1
+func TestHOTPMatchesRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, v := range vectors {
+		got := hotp(key, uint64(v.unix/30), 8)
+		if got != v.want {
+			t.Errorf("T=%d: expected %s, got %s", v.unix, v.want, got)
+		}
+	}
+}
+
+func TestValidateTOTPAllowsOneStepOfSkew(t *testing.T) {
+	key := []byte("12345678901234567890")
+	secret := b32.EncodeToString(key)
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / 30
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code := hotp(key, uint64(step+offset), totpDigits)
+		got, ok := validateTOTP(secret, code, now)
+		if !ok || got != step+offset {
+			t.Errorf("offset %d: expected step %d to validate, got %d, %v", offset, step+offset, got, ok)
+		}
+	}
+
+	for _, offset := range []int64{-2, 2} {
+		code := hotp(key, uint64(step+offset), totpDigits)
+		if _, ok := validateTOTP(secret, code, now); ok {
+			t.Errorf("offset %d: expected code outside the window to be rejected", offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedCodes(t *testing.T) {
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := validateTOTP(secret, code, now); ok {
+			t.Errorf("expected %q to be rejected", code)
+		}
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Telegraph", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("unexpected URI prefix: %s", uri)
+	}
+	if u.Path != "/Telegraph:alice@example.com" {
+		t.Errorf("unexpected label: %s", u.Path)
+	}
+
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "Telegraph" {
+		t.Errorf("unexpected query: %s", u.RawQuery)
+	}
+	if q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("unexpected parameters: %s", u.RawQuery)
+	}
+}
+
+func TestRecoveryCodesAreHashedAndNormalized(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || len(hashes) != recoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", recoveryCodeCount, len(codes))
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if strings.Contains(hashes[i], strings.ReplaceAll(code, "-", "")) {
+			t.Errorf("hash contains the plain code")
+		}
+		// Users may retype codes in upper case or without the dash
+		typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+		if hashToken(normalizeRecoveryCode(typed)) != hashes[i] {
+			t.Errorf("code %q does not match its hash after normalization", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"telegraph/internal/devices"
+	"telegraph/internal/users"
+	"telegraph/internal/webauthn"
+)
+
+// BeginWebAuthnRegistration returns options for navigator.credentials.create()
+func (h *Handler) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	opts, err := h.webauthn.BeginRegistration(r.Context(), u.ID, u.Username)
+	if err != nil {
+		respondWebAuthnError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(opts)
+}
+
+// FinishWebAuthnRegistration stores a new passkey and enables it as a second factor
+func (h *Handler) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	var body webauthn.FinishRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad_request", 400)
+		return
+	}
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	cred, err := h.webauthn.FinishRegistration(r.Context(), u.ID, body)
+	if err != nil {
+		respondWebAuthnError(w, err)
+		return
+	}
+
+	if !u.HasMFAMethod(users.MFAMethodWebAuthn) {
+		if err := h.userSvc.SetMFAMethod(r.Context(), u.ID, users.MFAMethodWebAuthn, true); err != nil {
+			http.Error(w, "update_failed", 500)
+			return
+		}
+	}
+
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(cred)
+}
+
+func (h *Handler) ListWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	creds, err := h.webauthn.ListCredentials(r.Context(), u.ID)
+	if err != nil {
+		http.Error(w, "webauthn_error", 500)
+		return
+	}
+	json.NewEncoder(w).Encode(creds)
+}
+
+// DeleteWebAuthnCredential removes a passkey; removing the last one
+// disables it as a second factor
+func (h *Handler) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "invalid_id", 400)
+		return
+	}
+
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	remaining, err := h.webauthn.DeleteCredential(r.Context(), u.ID, id)
+	if err != nil {
+		respondWebAuthnError(w, err)
+		return
+	}
+
+	if remaining == 0 {
+		if err := h.userSvc.SetMFAMethod(r.Context(), u.ID, users.MFAMethodWebAuthn, false); err != nil {
+			http.Error(w, "update_failed", 500)
+			return
+		}
+	}
+	w.WriteHeader(204)
+}
+
+// BeginWebAuthnMFA returns assertion options for a login challenge
+func (h *Handler) BeginWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	c, u, ok := h.pendingLogin(w, r, body.ChallengeToken)
+	if !ok {
+		return
+	}
+	if !canCompleteWith(u, users.MFAMethodWebAuthn) {
+		http.Error(w, ErrMFAMethodInvalid.Error(), 400)
+		return
+	}
+
+	opts, err := h.webauthn.BeginLogin(r.Context(), &c.UserID, webauthn.PurposeMFA)
+	if err != nil {
+		respondWebAuthnError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(opts)
+}
+
+// FinishWebAuthnMFA completes a login challenge with a passkey assertion
+func (h *Handler) FinishWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		webauthn.FinishLoginRequest
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad_request", 400)
+		return
+	}
+
+	c, u, ok := h.pendingLogin(w, r, body.ChallengeToken)
+	if !ok {
+		return
+	}
+	if !canCompleteWith(u, users.MFAMethodWebAuthn) {
+		http.Error(w, ErrMFAMethodInvalid.Error(), 400)
+		return
+	}
+	attempt, ok := h.beginMFA(w, r, u.ID)
+	if !ok {
+		return
+	}
+
+	res, err := h.webauthn.FinishLogin(r.Context(), webauthn.PurposeMFA, body.FinishLoginRequest)
+	if err != nil {
+		h.mfaFailed(r, attempt, u.ID, users.MFAMethodWebAuthn, err)
+		respondWebAuthnError(w, err)
+		return
+	}
+	if res.UserID != u.ID {
+		h.mfaFailed(r, attempt, u.ID, users.MFAMethodWebAuthn, webauthn.ErrCredentialNotFound)
+		http.Error(w, "invalid_credential", 401)
+		return
+	}
+	attempt.Succeed()
+
+	if err := h.mfa.ConsumeChallenge(r.Context(), c); err != nil {
+		http.Error(w, "invalid_challenge", 401)
+		return
+	}
+
+	device := devices.RegisterDeviceRequest{
+		DeviceID: c.DeviceID,
+		Name:     c.DeviceName,
+		Platform: c.Platform,
+	}
+	h.issueTokens(w, r, u, device, []string{amrPassword, amrHardwareKey})
+}
+
+// BeginWebAuthnLogin starts a passwordless login. With an email the user's
+// passkeys are listed; without one the browser offers discoverable ones.
+// Emails without passkeys, registered or not, get decoy credentials so the
+// response doesn't reveal which addresses have accounts.
+func (h *Handler) BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	email := strings.ToLower(strings.TrimSpace(body.Email))
+
+	var opts *webauthn.LoginBegin
+	var err error
+	if email == "" {
+		opts, err = h.webauthn.BeginLogin(r.Context(), nil, webauthn.PurposePasswordless)
+	} else {
+		err = webauthn.ErrCredentialNotFound
+		if u, lookupErr := h.userSvc.GetByEmail(r.Context(), email); lookupErr == nil && u.HasMFAMethod(users.MFAMethodWebAuthn) {
+			opts, err = h.webauthn.BeginLogin(r.Context(), &u.ID, webauthn.PurposePasswordless)
+		}
+		if err == webauthn.ErrCredentialNotFound {
+			opts, err = h.webauthn.BeginDecoyLogin(r.Context(), email, webauthn.PurposePasswordless)
+		}
+	}
+	if err != nil {
+		respondWebAuthnError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(opts)
+}
+
+// FinishWebAuthnLogin verifies a passkey assertion and issues tokens. User
+// verification is required, so the passkey counts as both factors.
+func (h *Handler) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		webauthn.FinishLoginRequest
+
+		DeviceID   string           `json:"device_id"`
+		DeviceName string           `json:"device_name"`
+		Platform   devices.Platform `json:"platform"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad_request", 400)
+		return
+	}
+
+	res, err := h.webauthn.FinishLogin(r.Context(), webauthn.PurposePasswordless, body.FinishLoginRequest)
+	if err != nil {
+		respondWebAuthnError(w, err)
+		return
+	}
+	if !res.UserVerified {
+		respondWebAuthnError(w, webauthn.ErrUserNotVerified)
+		return
+	}
+
+	u, err := h.userSvc.GetByID(r.Context(), res.UserID)
+	if err != nil || u.Bot {
+		http.Error(w, "invalid_credentials", 401)
+		return
+	}
+	if u.Suspended {
+		http.Error(w, "account_suspended", 403)
+		return
+	}
+
+	device := devices.RegisterDeviceRequest{
+		DeviceID: body.DeviceID,
+		Name:     body.DeviceName,
+		Platform: body.Platform,
+	}
+	h.issueTokens(w, r, u, device, []string{amrHardwareKey, amrMulti})
+}
+
+func respondWebAuthnError(w http.ResponseWriter, err error) {
+	switch err {
+	case webauthn.ErrSessionNotFound, webauthn.ErrSessionExpired,
+		webauthn.ErrCredentialNotFound, webauthn.ErrSignCountRegression,
+		webauthn.ErrInvalidSignature, webauthn.ErrUserNotVerified:
+		http.Error(w, err.Error(), 401)
+	case webauthn.ErrCredentialExists, webauthn.ErrTooManyCredentials:
+		http.Error(w, err.Error(), 409)
+	case webauthn.ErrInvalidName, webauthn.ErrInvalidClientData, webauthn.ErrChallengeMismatch,
+		webauthn.ErrOriginMismatch, webauthn.ErrInvalidAuthenticatorData, webauthn.ErrRPIDMismatch,
+		webauthn.ErrUserNotPresent, webauthn.ErrInvalidAttestation, webauthn.ErrUnsupportedAttestation,
+		webauthn.ErrInvalidPublicKey, webauthn.ErrUnsupportedAlgorithm:
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, "webauthn_error", 500)
+	}
+}
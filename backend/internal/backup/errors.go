@@ -0,0 +1,14 @@
+package backup
+
+import "errors"
+
+var (
+	ErrBackupNotFound     = errors.New("no key backup found")
+	ErrVersionConflict    = errors.New("backup version must be the latest version + 1")
+	ErrInvalidBlob        = errors.New("backup blob is empty or too large")
+	ErrInvalidKDF         = errors.New("unsupported or too weak kdf parameters")
+	ErrVerifierRequired   = errors.New("recovery_verifier must be 32 bytes")
+	ErrWrongRecoveryKey   = errors.New("recovery key does not match")
+	ErrUploadRateLimited  = errors.New("too many backup uploads")
+	ErrRestoreRateLimited = errors.New("too many restore attempts")
+)
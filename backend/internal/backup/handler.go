@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"telegraph/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service BackupService
+}
+
+func NewHandler(service BackupService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.GetInfo)
+	r.Put("/", h.Upload)
+	r.Delete("/", h.Delete)
+	r.Post("/restore", h.Restore)
+
+	return r
+}
+
+func (h *Handler) Upload(w http.ResponseWriter, r *http.Request) {
+	var req UploadBackupRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*MaxBlobSize)).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	backup, err := h.service.Upload(r.Context(), user.ID, req)
+	if err != nil {
+		respondBackupError(w, err)
+		return
+	}
+
+	respondJSON(w, backup, http.StatusCreated)
+}
+
+func (h *Handler) GetInfo(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	backup, err := h.service.GetInfo(r.Context(), user.ID)
+	if err != nil {
+		respondBackupError(w, err)
+		return
+	}
+
+	respondJSON(w, backup, http.StatusOK)
+}
+
+func (h *Handler) Restore(w http.ResponseWriter, r *http.Request) {
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	backup, err := h.service.Restore(r.Context(), user.ID, req)
+	if err != nil {
+		respondBackupError(w, err)
+		return
+	}
+
+	respondJSON(w, backup, http.StatusOK)
+}
+
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), user.ID); err != nil {
+		respondBackupError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "deleted"}, http.StatusOK)
+}
+
+func respondBackupError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidBlob, ErrInvalidKDF, ErrVerifierRequired:
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case ErrWrongRecoveryKey:
+		respondError(w, err.Error(), http.StatusForbidden)
+	case ErrBackupNotFound:
+		respondError(w, err.Error(), http.StatusNotFound)
+	case ErrVersionConflict:
+		respondError(w, err.Error(), http.StatusConflict)
+	case ErrUploadRateLimited, ErrRestoreRateLimited:
+		respondError(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
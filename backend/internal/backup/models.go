@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Backup limits
+const (
+	MaxBlobSize      = 4 * 1024 * 1024 // 4MB
+	KeptVersions     = 3               // Older versions are pruned on upload
+	VerifierSize     = 32              // Client-derived recovery key verifier
+	UploadLimit      = 10
+	UploadWindow     = time.Hour
+	RestoreLimit     = 5
+	RestoreWindow    = time.Hour
+	KDFArgon2id      = "argon2id"
+	MinSaltSize      = 16
+	MinArgon2Memory  = 19 * 1024 // KiB, OWASP minimum
+	MinArgon2Time    = 2
+	MaxArgon2Threads = 16
+)
+
+// KDFParams describes how the client derived the backup key from the
+// recovery key. The server only stores them so a new device can repeat it.
+type KDFParams struct {
+	Algorithm   string `json:"algorithm" bson:"algorithm"`
+	Salt        []byte `json:"salt" bson:"salt"`
+	Memory      uint32 `json:"memory" bson:"memory"` // KiB
+	Iterations  uint32 `json:"iterations" bson:"iterations"`
+	Parallelism uint8  `json:"parallelism" bson:"parallelism"`
+}
+
+// KeyBackup is one version of a user's client-encrypted key material.
+// The server cannot decrypt Blob.
+type KeyBackup struct {
+	ID           uuid.UUID `json:"-" bson:"_id"`
+	UserID       uuid.UUID `json:"user_id" bson:"user_id"`
+	Version      int64     `json:"version" bson:"version"`
+	Blob         []byte    `json:"blob,omitempty" bson:"blob"`
+	KDF          KDFParams `json:"kdf" bson:"kdf"`
+	VerifierHash []byte    `json:"-" bson:"verifier_hash"` // SHA-256 of the recovery key verifier
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+}
+
+// UploadBackupRequest stores a new backup version. Byte fields are base64 in JSON.
+// RecoveryVerifier may be omitted to keep the current recovery key; changing
+// it requires CurrentVerifier, the verifier of the key being replaced.
+type UploadBackupRequest struct {
+	Version          int64     `json:"version"` // Must be the latest version + 1
+	Blob             []byte    `json:"blob"`
+	KDF              KDFParams `json:"kdf"`
+	RecoveryVerifier []byte    `json:"recovery_verifier,omitempty"`
+	CurrentVerifier  []byte    `json:"current_verifier,omitempty"`
+}
+
+// RestoreRequest proves knowledge of the recovery key to download a backup
+type RestoreRequest struct {
+	RecoveryVerifier []byte `json:"recovery_verifier"`
+	Version          *int64 `json:"version,omitempty"` // Defaults to the latest
+}
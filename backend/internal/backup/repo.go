@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BackupRepo interface {
+	Create(ctx context.Context, b *KeyBackup) error
+	GetLatest(ctx context.Context, userID uuid.UUID) (*KeyBackup, error)
+	GetVersion(ctx context.Context, userID uuid.UUID, version int64) (*KeyBackup, error)
+	PruneBefore(ctx context.Context, userID uuid.UUID, version int64) error
+	DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type mongoBackupRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoBackupRepo(db *mongo.Database) BackupRepo {
+	return &mongoBackupRepo{collection: db.Collection("key_backups")}
+}
+
+// Create inserts a new version. The _id is derived from user and version so
+// two concurrent uploads of the same version can't both succeed.
+func (r *mongoBackupRepo) Create(ctx context.Context, b *KeyBackup) error {
+	b.ID = uuid.NewSHA1(b.UserID, []byte(strconv.FormatInt(b.Version, 10)))
+	b.CreatedAt = time.Now()
+	_, err := r.collection.InsertOne(ctx, b)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrVersionConflict
+	}
+	return err
+}
+
+func (r *mongoBackupRepo) GetLatest(ctx context.Context, userID uuid.UUID) (*KeyBackup, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	return r.findOne(ctx, bson.M{"user_id": userID}, opts)
+}
+
+func (r *mongoBackupRepo) GetVersion(ctx context.Context, userID uuid.UUID, version int64) (*KeyBackup, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID, "version": version})
+}
+
+func (r *mongoBackupRepo) findOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) (*KeyBackup, error) {
+	var b KeyBackup
+	err := r.collection.FindOne(ctx, filter, opts...).Decode(&b)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrBackupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// PruneBefore deletes versions older than version, whichever recovery key
+// they were made under
+func (r *mongoBackupRepo) PruneBefore(ctx context.Context, userID uuid.UUID, version int64) error {
+	filter := bson.M{
+		"user_id": userID,
+		"version": bson.M{"$lt": version},
+	}
+	_, err := r.collection.DeleteMany(ctx, filter)
+	return err
+}
+
+func (r *mongoBackupRepo) DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	res, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
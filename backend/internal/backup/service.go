@@ -0,0 +1,244 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"time"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/ratelimit"
+	"telegraph/internal/users"
+
+	"github.com/google/uuid"
+)
+
+type BackupService interface {
+	Upload(ctx context.Context, userID uuid.UUID, req UploadBackupRequest) (*KeyBackup, error)
+	GetInfo(ctx context.Context, userID uuid.UUID) (*KeyBackup, error)
+	Restore(ctx context.Context, userID uuid.UUID, req RestoreRequest) (*KeyBackup, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+// EmailSender delivers security notifications
+type EmailSender interface {
+	Send(to, subject, body string) error
+}
+
+type backupService struct {
+	repo     BackupRepo
+	userRepo users.UserRepo
+	email    EmailSender
+	audit    *audit.Logger
+	limiter  *ratelimit.Limiter
+}
+
+func NewBackupService(repo BackupRepo, userRepo users.UserRepo, email EmailSender, audit *audit.Logger) BackupService {
+	return &backupService{
+		repo:     repo,
+		userRepo: userRepo,
+		email:    email,
+		audit:    audit,
+		limiter:  ratelimit.NewLimiter(),
+	}
+}
+
+// Upload stores a new backup version. The first upload must set a recovery
+// verifier; later uploads may omit it to keep the existing one. Replacing
+// the verifier takes proof of the current one, so a stolen session can't
+// take over the backup. The owner is emailed about every upload.
+func (s *backupService) Upload(ctx context.Context, userID uuid.UUID, req UploadBackupRequest) (*KeyBackup, error) {
+	if ok, _ := s.limiter.Allow("upload:"+userID.String(), UploadLimit, UploadWindow); !ok {
+		return nil, ErrUploadRateLimited
+	}
+	if len(req.Blob) == 0 || len(req.Blob) > MaxBlobSize {
+		return nil, ErrInvalidBlob
+	}
+	if err := validateKDF(req.KDF); err != nil {
+		return nil, err
+	}
+
+	latest, err := s.repo.GetLatest(ctx, userID)
+	if err != nil && err != ErrBackupNotFound {
+		return nil, err
+	}
+
+	var current int64
+	if latest != nil {
+		current = latest.Version
+	}
+	if req.Version != current+1 {
+		return nil, ErrVersionConflict
+	}
+
+	var verifierHash []byte
+	switch {
+	case req.RecoveryVerifier != nil:
+		if len(req.RecoveryVerifier) != VerifierSize {
+			return nil, ErrVerifierRequired
+		}
+		verifierHash = hashVerifier(req.RecoveryVerifier)
+	case latest != nil:
+		verifierHash = latest.VerifierHash
+	default:
+		return nil, ErrVerifierRequired
+	}
+
+	keyChanged := latest != nil && subtle.ConstantTimeCompare(verifierHash, latest.VerifierHash) != 1
+	if keyChanged && (len(req.CurrentVerifier) != VerifierSize ||
+		subtle.ConstantTimeCompare(hashVerifier(req.CurrentVerifier), latest.VerifierHash) != 1) {
+		s.audit.Log(ctx, audit.AuditLog{
+			UserID:   &userID,
+			Action:   audit.EventKeyBackupStored,
+			Resource: userID.String(),
+			Result:   "denied",
+			Details:  "Recovery key change without proof of the current key",
+		})
+		return nil, ErrWrongRecoveryKey
+	}
+
+	backup := &KeyBackup{
+		UserID:       userID,
+		Version:      req.Version,
+		Blob:         req.Blob,
+		KDF:          req.KDF,
+		VerifierHash: verifierHash,
+	}
+	if err := s.repo.Create(ctx, backup); err != nil {
+		return nil, err
+	}
+	// Versions made under an earlier recovery key count towards the limit too,
+	// so they last only until KeptVersions newer ones have been stored
+	if err := s.repo.PruneBefore(ctx, userID, backup.Version-KeptVersions+1); err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventKeyBackupStored,
+		Resource: backup.ID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Stored key backup version %d (%d bytes, recovery_key_changed=%t)", backup.Version, len(backup.Blob), keyChanged),
+	})
+
+	when := time.Now().UTC().Format(time.RFC1123)
+	if keyChanged {
+		s.notify(ctx, userID, "Your recovery key was changed",
+			fmt.Sprintf("The recovery key of your encrypted key backup was changed at %s. Backups made with your old recovery key can still be restored with it until %d newer backups have been stored, then they are deleted. If this wasn't you, change your password and create a new recovery key.", when, KeptVersions-1))
+	} else {
+		s.notify(ctx, userID, "Your key backup was updated",
+			fmt.Sprintf("A new version of your encrypted key backup was stored at %s. If this wasn't you, change your password.", when))
+	}
+
+	backup.Blob = nil
+	return backup, nil
+}
+
+// GetInfo returns the latest backup's version and KDF parameters without the
+// blob, so a new device knows how to derive the recovery key
+func (s *backupService) GetInfo(ctx context.Context, userID uuid.UUID) (*KeyBackup, error) {
+	backup, err := s.repo.GetLatest(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	backup.Blob = nil
+	return backup, nil
+}
+
+// Restore returns the backup blob once the caller proves they hold the
+// recovery key. Every attempt counts against the rate limit and every
+// outcome is audited; the account owner is emailed on success.
+func (s *backupService) Restore(ctx context.Context, userID uuid.UUID, req RestoreRequest) (*KeyBackup, error) {
+	if ok, _ := s.limiter.Allow("restore:"+userID.String(), RestoreLimit, RestoreWindow); !ok {
+		s.logRestore(ctx, userID, "denied", "Key backup restore rate limited")
+		return nil, ErrRestoreRateLimited
+	}
+
+	var backup *KeyBackup
+	var err error
+	if req.Version != nil {
+		backup, err = s.repo.GetVersion(ctx, userID, *req.Version)
+	} else {
+		backup, err = s.repo.GetLatest(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.RecoveryVerifier) != VerifierSize ||
+		subtle.ConstantTimeCompare(hashVerifier(req.RecoveryVerifier), backup.VerifierHash) != 1 {
+		s.logRestore(ctx, userID, "failure", fmt.Sprintf("Wrong recovery key for backup version %d", backup.Version))
+		return nil, ErrWrongRecoveryKey
+	}
+
+	s.logRestore(ctx, userID, "success", fmt.Sprintf("Restored key backup version %d", backup.Version))
+	s.notify(ctx, userID, "Your key backup was restored",
+		fmt.Sprintf("Your encrypted key backup was restored at %s. If this wasn't you, change your password and create a new recovery key.", time.Now().UTC().Format(time.RFC1123)))
+
+	return backup, nil
+}
+
+func (s *backupService) Delete(ctx context.Context, userID uuid.UUID) error {
+	deleted, err := s.repo.DeleteAll(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrBackupNotFound
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventKeyBackupDeleted,
+		Resource: userID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Deleted %d key backup versions", deleted),
+	})
+	s.notify(ctx, userID, "Your key backup was deleted",
+		"Your encrypted key backup was deleted. Message history can no longer be recovered on a new device until you create a new backup.")
+
+	return nil
+}
+
+func (s *backupService) logRestore(ctx context.Context, userID uuid.UUID, result, details string) {
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventKeyBackupRestored,
+		Resource: userID.String(),
+		Result:   result,
+		Details:  details,
+	})
+}
+
+// notify sends a security email. Failures are logged, not returned, since the
+// action itself already happened.
+func (s *backupService) notify(ctx context.Context, userID uuid.UUID, subject, body string) {
+	if s.email == nil {
+		return
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || user.Email == "" {
+		return
+	}
+	if err := s.email.Send(user.Email, subject, body); err != nil {
+		log.Printf("Failed to send security email to user %s: %v", userID, err)
+	}
+}
+
+func validateKDF(kdf KDFParams) error {
+	if kdf.Algorithm != KDFArgon2id ||
+		len(kdf.Salt) < MinSaltSize ||
+		kdf.Memory < MinArgon2Memory ||
+		kdf.Iterations < MinArgon2Time ||
+		kdf.Parallelism == 0 || kdf.Parallelism > MaxArgon2Threads {
+		return ErrInvalidKDF
+	}
+	return nil
+}
+
+func hashVerifier(verifier []byte) []byte {
+	sum := sha256.Sum256(verifier)
+	return sum[:]
+}
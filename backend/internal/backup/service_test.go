@@ -0,0 +1,175 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+)
+
+// memoryBackupRepo keeps backup versions in memory for tests
+type memoryBackupRepo struct {
+	mu       sync.Mutex
+	versions map[int64]*KeyBackup
+}
+
+func (r *memoryBackupRepo) Create(ctx context.Context, b *KeyBackup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.versions[b.Version]; ok {
+		return ErrVersionConflict
+	}
+	b.ID = uuid.New()
+	copied := *b
+	r.versions[b.Version] = &copied
+	return nil
+}
+
+func (r *memoryBackupRepo) GetLatest(ctx context.Context, userID uuid.UUID) (*KeyBackup, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *KeyBackup
+	for _, b := range r.versions {
+		if latest == nil || b.Version > latest.Version {
+			latest = b
+		}
+	}
+	if latest == nil {
+		return nil, ErrBackupNotFound
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (r *memoryBackupRepo) GetVersion(ctx context.Context, userID uuid.UUID, version int64) (*KeyBackup, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.versions[version]
+	if !ok {
+		return nil, ErrBackupNotFound
+	}
+	copied := *b
+	return &copied, nil
+}
+
+func (r *memoryBackupRepo) PruneBefore(ctx context.Context, userID uuid.UUID, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for v := range r.versions {
+		if v < version {
+			delete(r.versions, v)
+		}
+	}
+	return nil
+}
+
+func (r *memoryBackupRepo) DeleteAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := int64(len(r.versions))
+	r.versions = map[int64]*KeyBackup{}
+	return n, nil
+}
+
+func (r *memoryBackupRepo) stored() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var versions []int64
+	for v := range r.versions {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+func verifier(b byte) []byte {
+	return bytes.Repeat([]byte{b}, VerifierSize)
+}
+
+func upload(version int64, recovery, current []byte) UploadBackupRequest {
+	return UploadBackupRequest{
+		Version: version,
+		Blob:    []byte("ciphertext"),
+		KDF: KDFParams{
+			Algorithm:   KDFArgon2id,
+			Salt:        make([]byte, MinSaltSize),
+			Memory:      MinArgon2Memory,
+			Iterations:  MinArgon2Time,
+			Parallelism: 1,
+		},
+		RecoveryVerifier: recovery,
+		CurrentVerifier:  current,
+	}
+}
+
+func TestUpload_ChangingRecoveryKeyNeedsCurrentKey(t *testing.T) {
+	repo := &memoryBackupRepo{versions: map[int64]*KeyBackup{}}
+	svc := NewBackupService(repo, nil, nil, &audit.Logger{})
+	ctx := context.Background()
+	userID := uuid.New()
+
+	if _, err := svc.Upload(ctx, userID, upload(1, verifier(1), nil)); err != nil {
+		t.Fatalf("first upload: %v", err)
+	}
+	if _, err := svc.Upload(ctx, userID, upload(2, verifier(2), nil)); err != ErrWrongRecoveryKey {
+		t.Fatalf("change without proof: got %v, want ErrWrongRecoveryKey", err)
+	}
+	if _, err := svc.Upload(ctx, userID, upload(2, verifier(2), verifier(3))); err != ErrWrongRecoveryKey {
+		t.Fatalf("change with wrong proof: got %v, want ErrWrongRecoveryKey", err)
+	}
+	if _, err := svc.Upload(ctx, userID, upload(2, verifier(1), nil)); err != nil {
+		t.Fatalf("restating the current key: %v", err)
+	}
+	if _, err := svc.Upload(ctx, userID, upload(3, verifier(2), verifier(1))); err != nil {
+		t.Fatalf("change with proof: %v", err)
+	}
+}
+
+func TestUpload_KeepsKeptVersionsAcrossRecoveryKeys(t *testing.T) {
+	repo := &memoryBackupRepo{versions: map[int64]*KeyBackup{}}
+	svc := NewBackupService(repo, nil, nil, &audit.Logger{})
+	ctx := context.Background()
+	userID := uuid.New()
+
+	svc.Upload(ctx, userID, upload(1, verifier(1), nil))
+	svc.Upload(ctx, userID, upload(2, nil, nil))
+	if _, err := svc.Upload(ctx, userID, upload(3, verifier(2), verifier(1))); err != nil {
+		t.Fatalf("change recovery key: %v", err)
+	}
+
+	// Right after the change the old key's versions are still restorable
+	if got, want := repo.stored(), []int64{1, 2, 3}; !equalVersions(got, want) {
+		t.Fatalf("stored versions %v, want %v", got, want)
+	}
+	old := int64(2)
+	if _, err := svc.Restore(ctx, userID, RestoreRequest{RecoveryVerifier: verifier(1), Version: &old}); err != nil {
+		t.Fatalf("restore with the old key: %v", err)
+	}
+
+	// Newer versions push them out; never more than KeptVersions are stored
+	for v := int64(4); v <= 6; v++ {
+		if _, err := svc.Upload(ctx, userID, upload(v, nil, nil)); err != nil {
+			t.Fatalf("upload %d: %v", v, err)
+		}
+	}
+	if got, want := repo.stored(), []int64{4, 5, 6}; !equalVersions(got, want) {
+		t.Fatalf("stored versions %v, want %v", got, want)
+	}
+}
+
+func equalVersions(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,13 @@
+package bots
+
+import "errors"
+
+var (
+	ErrInvalidBotToken    = errors.New("invalid bot token")
+	ErrInvalidBotUsername = errors.New("bot username must be 3-32 letters, digits or underscores and end in 'bot'")
+	ErrBotUsernameTaken   = errors.New("bot username already taken")
+	ErrBotNotFound        = errors.New("bot not found")
+	ErrNotBotOwner        = errors.New("not the owner of this bot")
+	ErrBotsCannotManage   = errors.New("bots cannot manage bots")
+	ErrNotBot             = errors.New("only bot accounts can poll for updates")
+)
@@ -0,0 +1,187 @@
+package bots
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"telegraph/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service BotService
+	queue   *UpdateQueue
+}
+
+func NewHandler(service BotService, queue *UpdateQueue) *Handler {
+	return &Handler{service: service, queue: queue}
+}
+
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	// Bot management (human owners only)
+	r.Post("/", h.CreateBot)
+	r.Get("/", h.ListBots)
+	r.Post("/{id}/token", h.RegenerateToken)
+	r.Delete("/{id}", h.DeleteBot)
+
+	// Bot API
+	r.Get("/updates", h.GetUpdates)
+
+	return r
+}
+
+func (h *Handler) CreateBot(w http.ResponseWriter, r *http.Request) {
+	var req CreateBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.Bot {
+		respondError(w, ErrBotsCannotManage.Error(), http.StatusForbidden)
+		return
+	}
+
+	bot, token, err := h.service.CreateBot(r.Context(), user.ID, req)
+	if err != nil {
+		switch err {
+		case ErrInvalidBotUsername:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		case ErrBotUsernameTaken:
+			respondError(w, err.Error(), http.StatusConflict)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The token is only ever shown once
+	respondJSON(w, map[string]any{
+		"bot":   bot,
+		"token": token,
+	}, http.StatusCreated)
+}
+
+func (h *Handler) ListBots(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bots, err := h.service.ListBots(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, bots, http.StatusOK)
+}
+
+func (h *Handler) RegenerateToken(w http.ResponseWriter, r *http.Request) {
+	botID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_bot_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.Bot {
+		respondError(w, ErrBotsCannotManage.Error(), http.StatusForbidden)
+		return
+	}
+
+	token, err := h.service.RegenerateToken(r.Context(), user.ID, botID)
+	if err != nil {
+		respondBotError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"token": token}, http.StatusOK)
+}
+
+func (h *Handler) DeleteBot(w http.ResponseWriter, r *http.Request) {
+	botID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_bot_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if user.Bot {
+		respondError(w, ErrBotsCannotManage.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err := h.service.DeleteBot(r.Context(), user.ID, botID); err != nil {
+		respondBotError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "bot_deleted"}, http.StatusOK)
+}
+
+// GetUpdates long-polls for events addressed to the calling bot.
+// Passing offset confirms every update with a lower update_id.
+func (h *Handler) GetUpdates(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !user.Bot {
+		respondError(w, ErrNotBot.Error(), http.StatusForbidden)
+		return
+	}
+
+	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	timeout, _ := strconv.Atoi(r.URL.Query().Get("timeout"))
+
+	updates := h.queue.Poll(r.Context(), user.ID.String(), offset, limit, time.Duration(timeout)*time.Second)
+
+	respondJSON(w, updates, http.StatusOK)
+}
+
+func respondBotError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrBotNotFound:
+		respondError(w, "bot_not_found", http.StatusNotFound)
+	case ErrNotBotOwner:
+		respondError(w, err.Error(), http.StatusForbidden)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
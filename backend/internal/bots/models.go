@@ -0,0 +1,46 @@
+package bots
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BotToken is a long-lived credential for a bot account. Only the hash is stored.
+type BotToken struct {
+	ID         uuid.UUID  `bson:"_id"`
+	BotID      uuid.UUID  `bson:"bot_id"`
+	TokenHash  string     `bson:"token_hash"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty"`
+	Revoked    bool       `bson:"revoked"`
+}
+
+// CreateBotRequest is the payload for creating a bot
+type CreateBotRequest struct {
+	Username    string `json:"username"`
+	Description string `json:"description"`
+}
+
+// Update is a single event queued for a bot that long-polls instead of
+// holding a WebSocket open. Payload is the same event a WebSocket client gets.
+type Update struct {
+	UpdateID  int64       `json:"update_id"`
+	Payload   interface{} `json:"payload"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+var botUsernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,32}$`)
+
+// validUsername enforces the bot naming convention (must end in "bot")
+func validUsername(username string) bool {
+	return botUsernamePattern.MatchString(username) &&
+		strings.HasSuffix(strings.ToLower(username), "bot")
+}
+
+// botEmail builds the placeholder address bots are stored under
+func botEmail(username string) string {
+	return strings.ToLower(username) + "@bots.telegraph.invalid"
+}
@@ -0,0 +1,65 @@
+package bots
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TokenRepo interface {
+	Create(ctx context.Context, botID uuid.UUID, tokenHash string) error
+	GetByHash(ctx context.Context, tokenHash string) (*BotToken, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	RevokeAll(ctx context.Context, botID uuid.UUID) error
+}
+
+type mongoTokenRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoTokenRepo(db *mongo.Database) TokenRepo {
+	return &mongoTokenRepo{
+		collection: db.Collection("bot_tokens"),
+	}
+}
+
+func (r *mongoTokenRepo) Create(ctx context.Context, botID uuid.UUID, tokenHash string) error {
+	token := BotToken{
+		ID:        uuid.New(),
+		BotID:     botID,
+		TokenHash: tokenHash,
+		CreatedAt: time.Now(),
+		Revoked:   false,
+	}
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+func (r *mongoTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*BotToken, error) {
+	var token BotToken
+	err := r.collection.FindOne(ctx, bson.M{
+		"token_hash": tokenHash,
+		"revoked":    false,
+	}).Decode(&token)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidBotToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *mongoTokenRepo) Touch(ctx context.Context, id uuid.UUID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": time.Now()}})
+	return err
+}
+
+func (r *mongoTokenRepo) RevokeAll(ctx context.Context, botID uuid.UUID) error {
+	update := bson.M{"$set": bson.M{"revoked": true}}
+	_, err := r.collection.UpdateMany(ctx, bson.M{"bot_id": botID}, update)
+	return err
+}
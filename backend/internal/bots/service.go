@@ -0,0 +1,220 @@
+package bots
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/users"
+
+	"github.com/google/uuid"
+)
+
+type BotService interface {
+	CreateBot(ctx context.Context, ownerID uuid.UUID, req CreateBotRequest) (*users.User, string, error)
+	ListBots(ctx context.Context, ownerID uuid.UUID) ([]*users.User, error)
+	RegenerateToken(ctx context.Context, ownerID, botID uuid.UUID) (string, error)
+	DeleteBot(ctx context.Context, ownerID, botID uuid.UUID) error
+	AuthenticateBot(ctx context.Context, token string) (uuid.UUID, error)
+	TrackAll(ctx context.Context) error
+}
+
+type botService struct {
+	tokens   TokenRepo
+	userRepo users.UserRepo
+	userSvc  users.UserService
+	queue    *UpdateQueue
+	audit    *audit.Logger
+}
+
+func NewBotService(tokens TokenRepo, userRepo users.UserRepo, userSvc users.UserService, queue *UpdateQueue, audit *audit.Logger) BotService {
+	return &botService{tokens: tokens, userRepo: userRepo, userSvc: userSvc, queue: queue, audit: audit}
+}
+
+func (s *botService) CreateBot(ctx context.Context, ownerID uuid.UUID, req CreateBotRequest) (*users.User, string, error) {
+	if !validUsername(req.Username) {
+		return nil, "", ErrInvalidBotUsername
+	}
+
+	owner, err := s.userRepo.GetByID(ctx, ownerID)
+	if err != nil {
+		return nil, "", err
+	}
+	if owner.Bot {
+		return nil, "", ErrBotsCannotManage
+	}
+
+	email := botEmail(req.Username)
+	if _, err := s.userRepo.GetByEmail(ctx, email); err == nil {
+		return nil, "", ErrBotUsernameTaken
+	}
+
+	// The password hash is random and never handed out; bots use tokens
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bot := &users.User{
+		Username:      req.Username,
+		Email:         email,
+		Bio:           req.Description,
+		PasswordHash:  users.HashPassword(secret),
+		Role:          "member",
+		SecurityLabel: "public",
+		AccountType:   "basic",
+		Attributes:    map[string]any{},
+		Bot:           true,
+		BotOwnerID:    &ownerID,
+	}
+	if err := s.userRepo.Create(ctx, bot); err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.issueToken(ctx, bot.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	s.queue.Track(bot.ID.String())
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &ownerID,
+		Action:   audit.EventBotCreated,
+		Resource: bot.ID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Created bot @%s", bot.Username),
+	})
+
+	return bot, token, nil
+}
+
+func (s *botService) ListBots(ctx context.Context, ownerID uuid.UUID) ([]*users.User, error) {
+	return s.userRepo.ListBots(ctx, &ownerID)
+}
+
+func (s *botService) RegenerateToken(ctx context.Context, ownerID, botID uuid.UUID) (string, error) {
+	if _, err := s.getOwnedBot(ctx, ownerID, botID); err != nil {
+		return "", err
+	}
+
+	if err := s.tokens.RevokeAll(ctx, botID); err != nil {
+		return "", err
+	}
+	token, err := s.issueToken(ctx, botID)
+	if err != nil {
+		return "", err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &ownerID,
+		Action:   audit.EventBotTokenRotated,
+		Resource: botID.String(),
+		Result:   "success",
+	})
+
+	return token, nil
+}
+
+func (s *botService) DeleteBot(ctx context.Context, ownerID, botID uuid.UUID) error {
+	if _, err := s.getOwnedBot(ctx, ownerID, botID); err != nil {
+		return err
+	}
+
+	if err := s.tokens.RevokeAll(ctx, botID); err != nil {
+		return err
+	}
+	if err := s.userSvc.DeleteUser(ctx, botID); err != nil {
+		return err
+	}
+	s.queue.Untrack(botID.String())
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &ownerID,
+		Action:   audit.EventBotDeleted,
+		Resource: botID.String(),
+		Result:   "success",
+	})
+
+	return nil
+}
+
+// AuthenticateBot resolves a bot token ("<bot id>:<secret>") to the bot's user ID
+func (s *botService) AuthenticateBot(ctx context.Context, token string) (uuid.UUID, error) {
+	idPart, _, found := strings.Cut(token, ":")
+	if !found {
+		return uuid.Nil, ErrInvalidBotToken
+	}
+	botID, err := uuid.Parse(idPart)
+	if err != nil {
+		return uuid.Nil, ErrInvalidBotToken
+	}
+
+	stored, err := s.tokens.GetByHash(ctx, hashToken(token))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if stored.BotID != botID {
+		return uuid.Nil, ErrInvalidBotToken
+	}
+
+	_ = s.tokens.Touch(ctx, stored.ID)
+	s.queue.Track(botID.String())
+
+	return botID, nil
+}
+
+// TrackAll starts buffering updates for every existing bot, so events sent
+// before a bot's first poll after a restart are not lost
+func (s *botService) TrackAll(ctx context.Context) error {
+	bots, err := s.userRepo.ListBots(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, b := range bots {
+		s.queue.Track(b.ID.String())
+	}
+	return nil
+}
+
+func (s *botService) getOwnedBot(ctx context.Context, ownerID, botID uuid.UUID) (*users.User, error) {
+	bot, err := s.userRepo.GetByID(ctx, botID)
+	if err == users.ErrUserNotFound {
+		return nil, ErrBotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !bot.Bot {
+		return nil, ErrBotNotFound
+	}
+	if bot.BotOwnerID == nil || *bot.BotOwnerID != ownerID {
+		return nil, ErrNotBotOwner
+	}
+	return bot, nil
+}
+
+func (s *botService) issueToken(ctx context.Context, botID uuid.UUID) (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	token := botID.String() + ":" + secret
+	return token, s.tokens.Create(ctx, botID, hashToken(token))
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,193 @@
+package bots
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// maxPendingUpdates bounds memory for bots that stop polling
+	maxPendingUpdates = 1000
+
+	MaxPollTimeout = 50 * time.Second
+	MaxPollLimit   = 100
+)
+
+type botQueue struct {
+	nextID  int64
+	updates []Update
+	// wake is closed and replaced whenever a new update arrives
+	wake chan struct{}
+}
+
+// UpdateQueue buffers WebSocket events for bots so they can fetch them with
+// getUpdates-style long polling
+type UpdateQueue struct {
+	mu     sync.Mutex
+	queues map[string]*botQueue
+}
+
+func NewUpdateQueue() *UpdateQueue {
+	return &UpdateQueue{
+		queues: make(map[string]*botQueue),
+	}
+}
+
+// Track starts buffering updates for a bot
+func (q *UpdateQueue) Track(botID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.queues[botID]; !ok {
+		q.queues[botID] = &botQueue{nextID: 1, wake: make(chan struct{})}
+	}
+}
+
+// Untrack drops a bot and any updates it had not fetched
+func (q *UpdateQueue) Untrack(botID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if bq, ok := q.queues[botID]; ok {
+		close(bq.wake)
+		delete(q.queues, botID)
+	}
+}
+
+// Push queues payload for userID if that user is a tracked bot
+func (q *UpdateQueue) Push(userID string, payload interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	bq, ok := q.queues[userID]
+	if !ok {
+		return
+	}
+
+	bq.updates = append(bq.updates, Update{
+		UpdateID:  bq.nextID,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	})
+	bq.nextID++
+
+	if len(bq.updates) > maxPendingUpdates {
+		bq.updates = bq.updates[len(bq.updates)-maxPendingUpdates:]
+	}
+
+	close(bq.wake)
+	bq.wake = make(chan struct{})
+}
+
+// Poll confirms every update below offset and returns up to limit pending
+// updates, waiting up to timeout for new ones if none are queued
+func (q *UpdateQueue) Poll(ctx context.Context, botID string, offset int64, limit int, timeout time.Duration) []Update {
+	if limit <= 0 || limit > MaxPollLimit {
+		limit = MaxPollLimit
+	}
+	if timeout > MaxPollTimeout {
+		timeout = MaxPollTimeout
+	}
+
+	q.Track(botID)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		updates, wake := q.pending(botID, offset, limit)
+		if len(updates) > 0 || timeout <= 0 || wake == nil {
+			return updates
+		}
+
+		select {
+		case <-wake:
+		case <-deadline.C:
+			return []Update{}
+		case <-ctx.Done():
+			return []Update{}
+		}
+	}
+}
+
+func (q *UpdateQueue) pending(botID string, offset int64, limit int) ([]Update, chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	bq, ok := q.queues[botID]
+	if !ok {
+		return []Update{}, nil
+	}
+
+	// Everything below offset has been processed by the bot
+	i := 0
+	for i < len(bq.updates) && bq.updates[i].UpdateID < offset {
+		i++
+	}
+	bq.updates = bq.updates[i:]
+
+	n := len(bq.updates)
+	if n > limit {
+		n = limit
+	}
+	updates := make([]Update, n)
+	copy(updates, bq.updates[:n])
+	return updates, bq.wake
+}
+
+// Hub is the WebSocket hub the relay forwards to
+type Hub interface {
+	SendToUser(userID string, message interface{})
+	SendToUsers(userIDs []string, message interface{})
+	SendToDevice(userID, deviceID string, message interface{})
+	DisconnectDevice(userID, deviceID string)
+	DisconnectSession(userID, sessionID string)
+	DisconnectAllSessions(userID, keepSessionID string)
+	BroadcastTyping(userID, channelID string, typing bool)
+}
+
+// Relay forwards events to the WebSocket hub and queues a copy for bots,
+// so bots receive the same updates whether they poll or hold a socket
+type Relay struct {
+	hub   Hub
+	queue *UpdateQueue
+}
+
+func NewRelay(hub Hub, queue *UpdateQueue) *Relay {
+	return &Relay{hub: hub, queue: queue}
+}
+
+func (r *Relay) SendToUser(userID string, message interface{}) {
+	r.hub.SendToUser(userID, message)
+	r.queue.Push(userID, message)
+}
+
+func (r *Relay) SendToUsers(userIDs []string, message interface{}) {
+	r.hub.SendToUsers(userIDs, message)
+	for _, userID := range userIDs {
+		r.queue.Push(userID, message)
+	}
+}
+
+// SendToDevice is not queued; bots have no devices
+func (r *Relay) SendToDevice(userID, deviceID string, message interface{}) {
+	r.hub.SendToDevice(userID, deviceID, message)
+}
+
+func (r *Relay) DisconnectDevice(userID, deviceID string) {
+	r.hub.DisconnectDevice(userID, deviceID)
+}
+
+func (r *Relay) DisconnectSession(userID, sessionID string) {
+	r.hub.DisconnectSession(userID, sessionID)
+}
+
+func (r *Relay) DisconnectAllSessions(userID, keepSessionID string) {
+	r.hub.DisconnectAllSessions(userID, keepSessionID)
+}
+
+// BroadcastTyping is not queued; typing indicators are useless after the fact
+func (r *Relay) BroadcastTyping(userID, channelID string, typing bool) {
+	r.hub.BroadcastTyping(userID, channelID, typing)
+}
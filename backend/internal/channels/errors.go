@@ -3,10 +3,20 @@ package channels
 import "errors"
 
 var (
-	ErrChannelNotFound     = errors.New("channel not found")
-	ErrNotChannelMember    = errors.New("not a channel member")
-	ErrNotChannelOwner     = errors.New("not the channel owner")
-	ErrInvalidChannelType  = errors.New("invalid channel type")
-	ErrBroadcastRestricted = errors.New("only admins can create broadcast channels")
-	ErrAlreadyMember       = errors.New("user is already a member")
+	ErrChannelNotFound       = errors.New("channel not found")
+	ErrNotChannelMember      = errors.New("not a channel member")
+	ErrNotChannelOwner       = errors.New("not the channel owner")
+	ErrInvalidChannelType    = errors.New("invalid channel type")
+	ErrBroadcastRestricted   = errors.New("only admins can create broadcast channels")
+	ErrAlreadyMember         = errors.New("user is already a member")
+	ErrInvalidSettings       = errors.New("invalid channel settings")
+	ErrNotBroadcast          = errors.New("only broadcast channels support subscriptions")
+	ErrNotPublic             = errors.New("channel is not public")
+	ErrChannelFull           = errors.New("channel has reached its subscriber limit")
+	ErrOwnerCannotLeave      = errors.New("the owner cannot unsubscribe from their channel")
+	ErrCannotMuteAdmin       = errors.New("the owner and admins cannot be muted")
+	ErrKeyEpochConflict      = errors.New("channel key epoch has changed")
+	ErrKeyRotationStale      = errors.New("channel membership changed during key rotation")
+	ErrInvalidEncryptionMode = errors.New("encryption_mode must be e2ee or server")
+	ErrEmailNotVerified      = errors.New("verify your email address before creating channels")
 )
@@ -3,6 +3,7 @@ package channels
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"telegraph/internal/middleware"
 	"telegraph/internal/users"
@@ -25,11 +26,15 @@ func (h *Handler) Routes() chi.Router {
 
 	r.Post("/", h.CreateChannel)
 	r.Get("/", h.ListMyChannels)
+	r.Get("/public", h.ListPublicChannels)
 	r.Get("/{id}", h.GetChannel)
 	r.Post("/{id}/members", h.AddMember)
 	r.Delete("/{id}/members/{userId}", h.RemoveMember)
 	r.Post("/{id}/members/{userId}/promote", h.PromoteMember)
 	r.Post("/{id}/members/{userId}/demote", h.DemoteMember)
+	r.Put("/{id}/settings", h.UpdateSettings)
+	r.Post("/{id}/subscribe", h.Subscribe)
+	r.Delete("/{id}/subscribe", h.Unsubscribe)
 	r.Delete("/{id}", h.DeleteChannel)
 
 	return r
@@ -48,6 +53,11 @@ func (h *Handler) CreateChannel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !user.EmailVerified() {
+		respondError(w, ErrEmailNotVerified.Error(), http.StatusForbidden)
+		return
+	}
+
 	channel, err := h.service.CreateChannel(r.Context(), req, user.ID, user.Role)
 	if err != nil {
 		if err == ErrBroadcastRestricted {
@@ -92,8 +102,12 @@ func (h *Handler) GetChannel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !isMember {
-		respondError(w, "not_a_member", http.StatusForbidden)
-		return
+		// Public broadcast channels can be previewed before subscribing
+		if channel.Type != ChannelTypeBroadcast || !channel.Public {
+			respondError(w, "not_a_member", http.StatusForbidden)
+			return
+		}
+		channel.Members = nil
 	}
 
 	respondJSON(w, channel, http.StatusOK)
@@ -300,4 +314,111 @@ func (h *Handler) DemoteMember(w http.ResponseWriter, r *http.Request) {
 	}
 
 	respondJSON(w, map[string]string{"message": "member_demoted"}, http.StatusOK)
-}
\ No newline at end of file
+}
+
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	var req ChannelSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channel, err := h.service.UpdateSettings(r.Context(), channelID, user.ID, req)
+	if err != nil {
+		switch err {
+		case ErrNotChannelOwner:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrChannelNotFound:
+			respondError(w, "channel_not_found", http.StatusNotFound)
+		case ErrInvalidSettings:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, channel, http.StatusOK)
+}
+
+func (h *Handler) ListPublicChannels(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	channels, err := h.service.ListPublicChannels(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, channels, http.StatusOK)
+}
+
+func (h *Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Subscribe(r.Context(), channelID, user.ID); err != nil {
+		switch err {
+		case ErrChannelNotFound:
+			respondError(w, "channel_not_found", http.StatusNotFound)
+		case ErrNotPublic:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrAlreadyMember, ErrChannelFull:
+			respondError(w, err.Error(), http.StatusConflict)
+		default:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "subscribed"}, http.StatusOK)
+}
+
+func (h *Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Unsubscribe(r.Context(), channelID, user.ID); err != nil {
+		switch err {
+		case ErrChannelNotFound:
+			respondError(w, "channel_not_found", http.StatusNotFound)
+		case ErrNotChannelMember:
+			respondError(w, err.Error(), http.StatusNotFound)
+		default:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "unsubscribed"}, http.StatusOK)
+}
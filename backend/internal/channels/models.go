@@ -15,6 +15,14 @@ const (
 	ChannelTypeBroadcast ChannelType = "channel"  // Broadcast channel (one-to-many)
 )
 
+// EncryptionMode decides who holds the keys for a channel's messages
+type EncryptionMode string
+
+const (
+	EncryptionModeE2EE   EncryptionMode = "e2ee"   // Clients encrypt; the server only sees ciphertext (default)
+	EncryptionModeServer EncryptionMode = "server" // Clients send plaintext over TLS; the server encrypts at rest
+)
+
 const (
 	ChannelRoleOwner  = "owner"
 	ChannelRoleAdmin  = "admin"
@@ -26,8 +34,34 @@ type ChannelMember struct {
 	Role              string     `json:"role" bson:"role"`
 	JoinedAt          time.Time  `json:"joined_at" bson:"joined_at"`
 	LastReadMessageID *uuid.UUID `json:"last_read_message_id,omitempty" bson:"last_read_message_id,omitempty"`
+	LastReadAt        *time.Time `json:"-" bson:"last_read_at,omitempty"` // Timestamp of LastReadMessageID; the pointer only moves forward
+	MutedUntil        *time.Time `json:"muted_until,omitempty" bson:"muted_until,omitempty"` // Member cannot post until then
+}
+
+// ChannelSettings holds per-channel posting limits.
+// Zero values disable the corresponding limit. Owners and admins are exempt.
+type ChannelSettings struct {
+	SlowModeSeconds    int `json:"slow_mode_seconds" bson:"slow_mode_seconds"`       // Minimum gap between messages per member
+	BurstLimit         int `json:"burst_limit" bson:"burst_limit"`                   // Maximum messages per member per burst window
+	BurstWindowSeconds int `json:"burst_window_seconds" bson:"burst_window_seconds"` // Defaults to 60 when a burst limit is set
+
+	// AllowIncomingWebhooks opts the channel in to plaintext messages from
+	// incoming webhooks, which are not end-to-end encrypted
+	AllowIncomingWebhooks bool `json:"allow_incoming_webhooks" bson:"allow_incoming_webhooks"`
 }
 
+const (
+	MaxSlowModeSeconds    = 6 * 60 * 60
+	MaxBurstWindowSeconds = 60 * 60
+	DefaultBurstWindow    = 60
+)
+
+// MaxBroadcastSubscribers caps a broadcast channel's members. Subscribers live
+// in the channel document like every other member, and read pointers and
+// mutes are updated in place there; at this size the document stays well
+// under MongoDB's 16MB limit, so they don't need a collection of their own.
+const MaxBroadcastSubscribers = 50000
+
 // Channel represents a messaging channel/group/private chat
 type Channel struct {
 	ID            uuid.UUID              `json:"id" bson:"id"`
@@ -38,18 +72,89 @@ type Channel struct {
 	Members       []ChannelMember        `json:"members" bson:"members"` // Stored as array of objects
 	Permissions   map[string]interface{} `json:"permissions,omitempty" bson:"permissions,omitempty"` // ABAC policies
 	SecurityLabel string                 `json:"security_label" bson:"security_label"` // MAC classification
+	Public        bool                   `json:"public" bson:"public"` // Public broadcast channels allow self-subscription
+	Settings      ChannelSettings        `json:"settings" bson:"settings"`
+	EncryptionMode EncryptionMode        `json:"encryption_mode" bson:"encryption_mode,omitempty"` // Fixed at creation; empty means e2ee
+	KeyEpoch      int64                  `json:"key_epoch" bson:"key_epoch"` // Current group key generation
+	RekeyRequired bool                   `json:"rekey_required" bson:"rekey_required"` // Set when a member is removed; blocks sends until rotation
+	KeyRotationEpoch int64               `json:"-" bson:"key_rotation_epoch,omitempty"` // Epoch a rotation in progress is storing keys for
+	KeyRotationUntil *time.Time          `json:"-" bson:"key_rotation_until,omitempty"` // When an abandoned rotation's claim lapses
+	Deleted       bool                   `json:"-" bson:"deleted,omitempty"`    // Set instead of removal while under legal hold
+	DeletedAt     *time.Time             `json:"-" bson:"deleted_at,omitempty"`
 	CreatedAt     time.Time              `json:"created_at" bson:"created_at"`
 	UpdatedAt     time.Time              `json:"updated_at" bson:"updated_at"`
 }
 
+// MemberRole returns the channel role of userID, or "" if they are not a member
+func (c *Channel) MemberRole(userID uuid.UUID) string {
+	for _, m := range c.Members {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// MemberIDs returns the IDs of all members as strings for WebSocket fan-out
+func (c *Channel) MemberIDs() []string {
+	ids := make([]string, 0, len(c.Members))
+	for _, m := range c.Members {
+		ids = append(ids, m.UserID.String())
+	}
+	return ids
+}
+
+// UsesGroupKeys reports whether messages are encrypted with a shared channel
+// key (group and broadcast channels) rather than pairwise
+func (c *Channel) UsesGroupKeys() bool {
+	return !c.ServerManaged() && (c.Type == ChannelTypeGroup || c.Type == ChannelTypeBroadcast)
+}
+
+// ServerManaged reports whether the server encrypts the channel's messages at rest
+func (c *Channel) ServerManaged() bool {
+	return c.EncryptionMode == EncryptionModeServer
+}
+
+// AdminIDs returns the owner and admins as strings for WebSocket fan-out
+func (c *Channel) AdminIDs() []string {
+	ids := []string{c.OwnerID.String()}
+	for _, m := range c.Members {
+		if m.Role == ChannelRoleAdmin {
+			ids = append(ids, m.UserID.String())
+		}
+	}
+	return ids
+}
+
+// IsMuted reports whether userID is muted in the channel at the given time
+func (c *Channel) IsMuted(userID uuid.UUID, now time.Time) bool {
+	for _, m := range c.Members {
+		if m.UserID == userID {
+			return m.MutedUntil != nil && now.Before(*m.MutedUntil)
+		}
+	}
+	return false
+}
+
+// IsOwnerOrAdmin reports whether userID manages the channel
+func (c *Channel) IsOwnerOrAdmin(userID uuid.UUID) bool {
+	if c.OwnerID == userID {
+		return true
+	}
+	role := c.MemberRole(userID)
+	return role == ChannelRoleOwner || role == ChannelRoleAdmin
+}
+
 // CreateChannelRequest is the payload for creating a new channel
 type CreateChannelRequest struct {
 	Type          ChannelType            `json:"type"`
 	Name          string                 `json:"name"`
 	Description   string                 `json:"description"`
 	Members       []uuid.UUID            `json:"members"`       // Initial members (default to 'member' role)
+	Public        bool                   `json:"public"`        // Only valid for broadcast channels
 	Permissions   map[string]interface{} `json:"permissions"`   // Optional ABAC policies
 	SecurityLabel string                 `json:"security_label"` // Optional, defaults to owner's label
+	EncryptionMode EncryptionMode        `json:"encryption_mode"` // Optional, defaults to e2ee
 }
 
 // AddMemberRequest is the payload for adding a member
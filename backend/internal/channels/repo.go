@@ -2,26 +2,39 @@ package channels
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ChannelRepo interface {
 	Create(ctx context.Context, c *Channel) error
 	GetByID(ctx context.Context, id uuid.UUID) (*Channel, error)
 	GetUserChannels(ctx context.Context, userID uuid.UUID) ([]*Channel, error)
+	ListPublic(ctx context.Context, query string, limit int) ([]*Channel, error)
 	AddMember(ctx context.Context, channelID, userID uuid.UUID) error
 	RemoveMember(ctx context.Context, channelID, userID uuid.UUID) error
 	UpdateMemberRole(ctx context.Context, channelID, userID uuid.UUID, role string) error
-	UpdateLastRead(ctx context.Context, channelID, userID, messageID uuid.UUID) error
+	SetMemberMute(ctx context.Context, channelID, userID uuid.UUID, until *time.Time) error
+	AdvanceLastRead(ctx context.Context, channelID, userID, messageID uuid.UUID, at time.Time) (*ChannelMember, error)
 	IsMember(ctx context.Context, channelID, userID uuid.UUID) (bool, error)
 	Update(ctx context.Context, c *Channel) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	PurgeDeleted(ctx context.Context, id uuid.UUID) error
+	SetRekeyRequired(ctx context.Context, id uuid.UUID) error
+	ClaimKeyRotation(ctx context.Context, channel *Channel, lease time.Duration) error
+	AdvanceKeyEpoch(ctx context.Context, channel *Channel) error
+	ReleaseKeyRotation(ctx context.Context, id uuid.UUID, epoch int64) error
 }
 
+// notDeleted hides channels that are only kept around because of a legal hold
+var notDeleted = bson.M{"$ne": true}
+
 type mongoChannelRepo struct {
 	collection *mongo.Collection
 }
@@ -45,7 +58,7 @@ func (r *mongoChannelRepo) Create(ctx context.Context, c *Channel) error {
 
 func (r *mongoChannelRepo) GetByID(ctx context.Context, id uuid.UUID) (*Channel, error) {
 	var channel Channel
-	err := r.collection.FindOne(ctx, bson.M{"id": id}).Decode(&channel)
+	err := r.collection.FindOne(ctx, bson.M{"id": id, "deleted": notDeleted}).Decode(&channel)
 	if err == mongo.ErrNoDocuments {
 		return nil, ErrChannelNotFound
 	}
@@ -53,7 +66,36 @@ func (r *mongoChannelRepo) GetByID(ctx context.Context, id uuid.UUID) (*Channel,
 }
 
 func (r *mongoChannelRepo) GetUserChannels(ctx context.Context, userID uuid.UUID) ([]*Channel, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{"members.user_id": userID})
+	cursor, err := r.collection.Find(ctx, bson.M{"members.user_id": userID, "deleted": notDeleted})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var channels []*Channel
+	if err := cursor.All(ctx, &channels); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+func (r *mongoChannelRepo) ListPublic(ctx context.Context, query string, limit int) ([]*Channel, error) {
+	filter := bson.M{
+		"type":    ChannelTypeBroadcast,
+		"public":  true,
+		"deleted": notDeleted,
+	}
+	if query != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(query), "$options": "i"}
+	}
+
+	// Member lists of large broadcast channels are not needed for discovery
+	opts := options.Find().
+		SetProjection(bson.M{"members": 0}).
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +126,7 @@ func (r *mongoChannelRepo) RemoveMember(ctx context.Context, channelID, userID u
 }
 
 func (r *mongoChannelRepo) IsMember(ctx context.Context, channelID, userID uuid.UUID) (bool, error) {
-	count, err := r.collection.CountDocuments(ctx, bson.M{"id": channelID, "members.user_id": userID})
+	count, err := r.collection.CountDocuments(ctx, bson.M{"id": channelID, "members.user_id": userID, "deleted": notDeleted})
 	if err != nil {
 		return false, err
 	}
@@ -99,6 +141,7 @@ func (r *mongoChannelRepo) Update(ctx context.Context, c *Channel) error {
 		"description":    c.Description,
 		"permissions":    c.Permissions,
 		"security_label": c.SecurityLabel,
+		"settings":       c.Settings,
 		"updated_at":     c.UpdatedAt,
 	}}
 	
@@ -111,6 +154,107 @@ func (r *mongoChannelRepo) Delete(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+func (r *mongoChannelRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"deleted": true, "deleted_at": now, "updated_at": now}}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// PurgeDeleted removes the channel if it was only soft-deleted
+func (r *mongoChannelRepo) PurgeDeleted(ctx context.Context, id uuid.UUID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"id": id, "deleted": true})
+	return err
+}
+
+
+// SetRekeyRequired flags the channel key as compromised until the next rotation
+func (r *mongoChannelRepo) SetRekeyRequired(ctx context.Context, id uuid.UUID) error {
+	update := bson.M{"$set": bson.M{"rekey_required": true, "updated_at": time.Now()}}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// keyStateFilter matches the channel only while its key epoch, members and
+// rekey flag are what the caller read. A member added or removed since then
+// makes a rotation built from the old member list fail.
+func keyStateFilter(channel *Channel) bson.M {
+	filter := bson.M{"id": channel.ID, "deleted": notDeleted}
+	if channel.KeyEpoch == 0 {
+		// Channels created before key epochs existed have no key_epoch field
+		filter["key_epoch"] = bson.M{"$in": []interface{}{0, nil}}
+	} else {
+		filter["key_epoch"] = channel.KeyEpoch
+	}
+	if channel.RekeyRequired {
+		filter["rekey_required"] = true
+	} else {
+		filter["rekey_required"] = bson.M{"$ne": true}
+	}
+
+	memberIDs := make([]uuid.UUID, 0, len(channel.Members))
+	for _, m := range channel.Members {
+		memberIDs = append(memberIDs, m.UserID)
+	}
+	filter["members"] = bson.M{"$size": len(memberIDs)}
+	if len(memberIDs) > 0 {
+		filter["members.user_id"] = bson.M{"$all": memberIDs}
+	}
+	return filter
+}
+
+// ClaimKeyRotation reserves epoch KeyEpoch+1 for one rotation so its wrapped
+// keys can be stored before the epoch goes live. A claim that is not
+// released or completed lapses after lease. It fails with ErrKeyEpochConflict
+// if another rotation holds the claim or the channel changed.
+func (r *mongoChannelRepo) ClaimKeyRotation(ctx context.Context, channel *Channel, lease time.Duration) error {
+	now := time.Now()
+	filter := keyStateFilter(channel)
+	filter["$or"] = []bson.M{
+		{"key_rotation_until": bson.M{"$exists": false}},
+		{"key_rotation_until": bson.M{"$lt": now}},
+	}
+	update := bson.M{"$set": bson.M{
+		"key_rotation_epoch": channel.KeyEpoch + 1,
+		"key_rotation_until": now.Add(lease),
+	}}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrKeyEpochConflict
+	}
+	return nil
+}
+
+// AdvanceKeyEpoch completes a claimed rotation: it moves the channel to
+// KeyEpoch+1 and clears the rekey flag. It fails with ErrKeyRotationStale if
+// the epoch, members or rekey flag changed since the channel was read.
+func (r *mongoChannelRepo) AdvanceKeyEpoch(ctx context.Context, channel *Channel) error {
+	filter := keyStateFilter(channel)
+	filter["key_rotation_epoch"] = channel.KeyEpoch + 1
+	update := bson.M{
+		"$set":   bson.M{"key_epoch": channel.KeyEpoch + 1, "rekey_required": false, "updated_at": time.Now()},
+		"$unset": bson.M{"key_rotation_epoch": "", "key_rotation_until": ""},
+	}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrKeyRotationStale
+	}
+	return nil
+}
+
+// ReleaseKeyRotation drops a rotation claim that won't be completed
+func (r *mongoChannelRepo) ReleaseKeyRotation(ctx context.Context, id uuid.UUID, epoch int64) error {
+	filter := bson.M{"id": id, "key_rotation_epoch": epoch}
+	update := bson.M{"$unset": bson.M{"key_rotation_epoch": "", "key_rotation_until": ""}}
+	_, err := r.collection.UpdateOne(ctx, filter, update)
+	return err
+}
 
 func (r *mongoChannelRepo) UpdateMemberRole(ctx context.Context, channelID, userID uuid.UUID, role string) error {
 	filter := bson.M{"id": channelID, "members.user_id": userID}
@@ -119,9 +263,47 @@ func (r *mongoChannelRepo) UpdateMemberRole(ctx context.Context, channelID, user
 	return err
 }
 
-func (r *mongoChannelRepo) UpdateLastRead(ctx context.Context, channelID, userID, messageID uuid.UUID) error {
+// SetMemberMute mutes a member until the given time, or unmutes them when until is nil
+func (r *mongoChannelRepo) SetMemberMute(ctx context.Context, channelID, userID uuid.UUID, until *time.Time) error {
 	filter := bson.M{"id": channelID, "members.user_id": userID}
-	update := bson.M{"$set": bson.M{"members.$.last_read_message_id": messageID, "updated_at": time.Now()}}
+	update := bson.M{"$set": bson.M{"members.$.muted_until": until, "updated_at": time.Now()}}
 	_, err := r.collection.UpdateOne(ctx, filter, update)
 	return err
 }
+
+// AdvanceLastRead moves a member's read pointer to messageID, sent at at, if
+// that is newer than what they last read. It returns the member as they were
+// before the move, or nil if the pointer was already there or further along,
+// so concurrent reads of the same messages only go through once.
+func (r *mongoChannelRepo) AdvanceLastRead(ctx context.Context, channelID, userID, messageID uuid.UUID, at time.Time) (*ChannelMember, error) {
+	filter := bson.M{
+		"id": channelID,
+		"members": bson.M{"$elemMatch": bson.M{
+			"user_id": userID,
+			"$or": bson.A{
+				bson.M{"last_read_at": bson.M{"$exists": false}},
+				bson.M{"last_read_at": bson.M{"$lt": at}},
+			},
+		}},
+	}
+	update := bson.M{"$set": bson.M{
+		"members.$.last_read_message_id": messageID,
+		"members.$.last_read_at":         at,
+		"updated_at":                     time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().
+		SetProjection(bson.M{"members": bson.M{"$elemMatch": bson.M{"user_id": userID}}})
+
+	var before Channel
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(before.Members) == 0 {
+		return nil, nil
+	}
+	return &before.Members[0], nil
+}
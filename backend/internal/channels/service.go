@@ -22,16 +22,40 @@ type ChannelService interface {
 	IsMember(ctx context.Context, channelID, userID uuid.UUID) (bool, error)
 	PromoteToAdmin(ctx context.Context, channelID, requestorID, memberID uuid.UUID) error
 	DemoteAdmin(ctx context.Context, channelID, requestorID, memberID uuid.UUID) error
+	MuteMember(ctx context.Context, channelID, requestorID, memberID uuid.UUID, until *time.Time) error
+	UpdateSettings(ctx context.Context, channelID, requestorID uuid.UUID, settings ChannelSettings) (*Channel, error)
+	ListPublicChannels(ctx context.Context, query string, limit int) ([]*Channel, error)
+	Subscribe(ctx context.Context, channelID, userID uuid.UUID) error
+	Unsubscribe(ctx context.Context, channelID, userID uuid.UUID) error
+}
+
+// HoldChecker reports whether a legal hold requires a channel to be preserved
+type HoldChecker interface {
+	IsChannelHeld(ctx context.Context, channelID uuid.UUID) (bool, error)
+}
+
+// Hub interface for WebSocket broadcasting
+type Hub interface {
+	SendToUser(userID string, message interface{})
+	SendToUsers(userIDs []string, message interface{})
+}
+
+// EventPublisher notifies external integrations such as webhooks about channel events
+type EventPublisher interface {
+	Publish(ctx context.Context, eventType string, channelID *uuid.UUID, data interface{})
 }
 
 type channelService struct {
 	repo     ChannelRepo
 	userRepo users.UserRepo
 	audit    *audit.Logger
+	holds    HoldChecker
+	hub      Hub
+	events   EventPublisher
 }
 
-func NewChannelService(repo ChannelRepo, userRepo users.UserRepo, audit *audit.Logger) ChannelService {
-	return &channelService{repo: repo, userRepo: userRepo, audit: audit}
+func NewChannelService(repo ChannelRepo, userRepo users.UserRepo, audit *audit.Logger, holds HoldChecker, hub Hub, events EventPublisher) ChannelService {
+	return &channelService{repo: repo, userRepo: userRepo, audit: audit, holds: holds, hub: hub, events: events}
 }
 
 func (s *channelService) CreateChannel(ctx context.Context, req CreateChannelRequest, creatorID uuid.UUID, creatorRole string) (*Channel, error) {
@@ -40,6 +64,17 @@ func (s *channelService) CreateChannel(ctx context.Context, req CreateChannelReq
 		return nil, ErrInvalidChannelType
 	}
 
+	if req.Public && req.Type != ChannelTypeBroadcast {
+		return nil, ErrNotBroadcast
+	}
+
+	if req.EncryptionMode == "" {
+		req.EncryptionMode = EncryptionModeE2EE
+	}
+	if req.EncryptionMode != EncryptionModeE2EE && req.EncryptionMode != EncryptionModeServer {
+		return nil, ErrInvalidEncryptionMode
+	}
+
 	// Validate Name for Group/Broadcast
 	if (req.Type == ChannelTypeGroup || req.Type == ChannelTypeBroadcast) && req.Name == "" {
 		return nil, fmt.Errorf("channel name is required for groups and broadcasts")
@@ -119,6 +154,8 @@ func (s *channelService) CreateChannel(ctx context.Context, req CreateChannelReq
 		Members:       members,
 		Permissions:   req.Permissions,
 		SecurityLabel: securityLabel,
+		Public:        req.Public,
+		EncryptionMode: req.EncryptionMode,
 	}
 
 	if err := s.repo.Create(ctx, channel); err != nil {
@@ -131,7 +168,7 @@ func (s *channelService) CreateChannel(ctx context.Context, req CreateChannelReq
 		Action:   audit.EventChannelCreated,
 		Resource: channel.ID.String(),
 		Result:   "success",
-		Details:  fmt.Sprintf("Created channel '%s' (%s)", channel.Name, channel.Type),
+		Details:  fmt.Sprintf("Created channel '%s' (%s, encryption=%s)", channel.Name, channel.Type, channel.EncryptionMode),
 	})
 
 	return channel, nil
@@ -186,7 +223,16 @@ func (s *channelService) AddMember(ctx context.Context, channelID, requestorID,
 		return fmt.Errorf("user lacks clearance for this channel")
 	}
 
-	return s.repo.AddMember(ctx, channelID, newMemberID)
+	if err := s.repo.AddMember(ctx, channelID, newMemberID); err != nil {
+		return err
+	}
+	s.requestKeyShare(channel, newMemberID)
+
+	s.publish(ctx, "MEMBER_JOINED", channelID, map[string]interface{}{
+		"user_id":  newMemberID,
+		"added_by": requestorID,
+	})
+	return nil
 }
 
 func (s *channelService) RemoveMember(ctx context.Context, channelID, requestorID, memberID uuid.UUID) error {
@@ -244,7 +290,18 @@ func (s *channelService) RemoveMember(ctx context.Context, channelID, requestorI
 		return fmt.Errorf("admins cannot remove other admins or the owner")
 	}
 
-	return s.repo.RemoveMember(ctx, channelID, memberID)
+	if err := s.repo.RemoveMember(ctx, channelID, memberID); err != nil {
+		return err
+	}
+	if err := s.requireRekey(ctx, channel, memberID); err != nil {
+		return err
+	}
+
+	s.publish(ctx, "MEMBER_LEFT", channelID, map[string]interface{}{
+		"user_id":    memberID,
+		"removed_by": requestorID,
+	})
+	return nil
 }
 
 func (s *channelService) DeleteChannel(ctx context.Context, channelID, requestorID uuid.UUID) error {
@@ -264,7 +321,24 @@ func (s *channelService) DeleteChannel(ctx context.Context, channelID, requestor
 		}
 	}
 
-	return s.repo.Delete(ctx, channelID)
+	// Held channels disappear for members but stay in storage
+	held, err := s.holds.IsChannelHeld(ctx, channelID)
+	if err != nil {
+		return err
+	}
+	if held {
+		err = s.repo.SoftDelete(ctx, channelID)
+	} else {
+		err = s.repo.Delete(ctx, channelID)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.publish(ctx, "CHANNEL_DELETED", channelID, map[string]interface{}{
+		"deleted_by": requestorID,
+	})
+	return nil
 }
 
 func (s *channelService) IsMember(ctx context.Context, channelID, userID uuid.UUID) (bool, error) {
@@ -324,3 +398,237 @@ func (s *channelService) DemoteAdmin(ctx context.Context, channelID, requestorID
 
 	return s.repo.UpdateMemberRole(ctx, channelID, memberID, ChannelRoleMember)
 }
+
+// MuteMember stops a member from posting until the given time. A nil until unmutes.
+// Owners and admins (or users with the manage-members permission) can mute regular members.
+func (s *channelService) MuteMember(ctx context.Context, channelID, requestorID, memberID uuid.UUID, until *time.Time) error {
+	channel, err := s.repo.GetByID(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	if !channel.IsOwnerOrAdmin(requestorID) {
+		requestor, err := s.userRepo.GetByID(ctx, requestorID)
+		if err != nil {
+			return err
+		}
+		if !acl.HasPermission(requestor.Role, acl.PermissionManageMembers) {
+			return ErrNotChannelOwner
+		}
+	}
+
+	if channel.MemberRole(memberID) == "" {
+		return ErrNotChannelMember
+	}
+	if channel.IsOwnerOrAdmin(memberID) {
+		return ErrCannotMuteAdmin
+	}
+
+	if err := s.repo.SetMemberMute(ctx, channelID, memberID, until); err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("Unmuted %s", memberID)
+	if until != nil {
+		details = fmt.Sprintf("Muted %s until %s", memberID, until.Format(time.RFC3339))
+	}
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &requestorID,
+		Action:   audit.EventChannelUpdated,
+		Resource: channelID.String(),
+		Result:   "success",
+		Details:  details,
+	})
+
+	s.broadcast(channel, map[string]interface{}{
+		"type":        "MEMBER_MUTED",
+		"channel_id":  channelID.String(),
+		"user_id":     memberID.String(),
+		"muted_until": until,
+	})
+
+	return nil
+}
+
+func (s *channelService) UpdateSettings(ctx context.Context, channelID, requestorID uuid.UUID, settings ChannelSettings) (*Channel, error) {
+	if settings.SlowModeSeconds < 0 || settings.SlowModeSeconds > MaxSlowModeSeconds ||
+		settings.BurstLimit < 0 ||
+		settings.BurstWindowSeconds < 0 || settings.BurstWindowSeconds > MaxBurstWindowSeconds {
+		return nil, ErrInvalidSettings
+	}
+	if settings.BurstLimit > 0 && settings.BurstWindowSeconds == 0 {
+		settings.BurstWindowSeconds = DefaultBurstWindow
+	}
+
+	channel, err := s.repo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the owner or channel admins can change settings
+	if !channel.IsOwnerOrAdmin(requestorID) {
+		return nil, ErrNotChannelOwner
+	}
+
+	channel.Settings = settings
+	if err := s.repo.Update(ctx, channel); err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &requestorID,
+		Action:   audit.EventChannelUpdated,
+		Resource: channel.ID.String(),
+		Result:   "success",
+		Details: fmt.Sprintf("Updated settings: slow_mode=%ds burst=%d/%ds incoming_webhooks=%t",
+			settings.SlowModeSeconds, settings.BurstLimit, settings.BurstWindowSeconds, settings.AllowIncomingWebhooks),
+	})
+
+	s.broadcast(channel, map[string]interface{}{
+		"type":       "CHANNEL_SETTINGS_UPDATED",
+		"channel_id": channel.ID.String(),
+		"settings":   channel.Settings,
+	})
+	s.publish(ctx, "CHANNEL_UPDATED", channel.ID, map[string]interface{}{
+		"updated_by": requestorID,
+		"settings":   channel.Settings,
+	})
+
+	return channel, nil
+}
+
+// requireRekey blocks sends with the current group key after a member is
+// removed and asks the remaining owner and admins to rotate it
+func (s *channelService) requireRekey(ctx context.Context, channel *Channel, removedID uuid.UUID) error {
+	if !channel.UsesGroupKeys() {
+		return nil
+	}
+	if err := s.repo.SetRekeyRequired(ctx, channel.ID); err != nil {
+		return err
+	}
+
+	// The removed member may have been an admin; they don't get to hear about it
+	var recipients []string
+	for _, id := range channel.AdminIDs() {
+		if id != removedID.String() {
+			recipients = append(recipients, id)
+		}
+	}
+	if s.hub != nil && len(recipients) > 0 {
+		s.hub.SendToUsers(recipients, map[string]interface{}{
+			"type":            "KEY_ROTATION_REQUIRED",
+			"channel_id":      channel.ID.String(),
+			"removed_user_id": removedID.String(),
+			"key_epoch":       channel.KeyEpoch,
+		})
+	}
+	return nil
+}
+
+// requestKeyShare asks the owner and admins to wrap the current group key for a new member
+func (s *channelService) requestKeyShare(channel *Channel, userID uuid.UUID) {
+	if s.hub == nil || !channel.UsesGroupKeys() {
+		return
+	}
+	s.hub.SendToUsers(channel.AdminIDs(), map[string]interface{}{
+		"type":       "KEY_SHARE_REQUIRED",
+		"channel_id": channel.ID.String(),
+		"user_id":    userID.String(),
+		"key_epoch":  channel.KeyEpoch,
+	})
+}
+
+// publish forwards a channel event to external integrations
+func (s *channelService) publish(ctx context.Context, eventType string, channelID uuid.UUID, data interface{}) {
+	if s.events == nil {
+		return
+	}
+	s.events.Publish(ctx, eventType, &channelID, data)
+}
+
+// broadcast sends a WebSocket event to every member of the channel
+func (s *channelService) broadcast(channel *Channel, message interface{}) {
+	if s.hub == nil {
+		return
+	}
+	s.hub.SendToUsers(channel.MemberIDs(), message)
+}
+
+func (s *channelService) ListPublicChannels(ctx context.Context, query string, limit int) ([]*Channel, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	return s.repo.ListPublic(ctx, query, limit)
+}
+
+// Subscribe lets a user join a public broadcast channel as a read-only subscriber
+func (s *channelService) Subscribe(ctx context.Context, channelID, userID uuid.UUID) error {
+	channel, err := s.repo.GetByID(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	if channel.Type != ChannelTypeBroadcast {
+		return ErrNotBroadcast
+	}
+	if !channel.Public {
+		return ErrNotPublic
+	}
+	if channel.MemberRole(userID) != "" {
+		return ErrAlreadyMember
+	}
+	if len(channel.Members) >= MaxBroadcastSubscribers {
+		return ErrChannelFull
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !acl.CanAccessResource(user.SecurityLabel, channel.SecurityLabel) {
+		return fmt.Errorf("user lacks clearance for this channel")
+	}
+
+	if err := s.repo.AddMember(ctx, channelID, userID); err != nil {
+		return err
+	}
+	s.requestKeyShare(channel, userID)
+
+	s.publish(ctx, "MEMBER_JOINED", channelID, map[string]interface{}{
+		"user_id": userID,
+	})
+	return nil
+}
+
+func (s *channelService) Unsubscribe(ctx context.Context, channelID, userID uuid.UUID) error {
+	channel, err := s.repo.GetByID(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	if channel.Type != ChannelTypeBroadcast {
+		return ErrNotBroadcast
+	}
+	if channel.OwnerID == userID {
+		return ErrOwnerCannotLeave
+	}
+	if channel.MemberRole(userID) == "" {
+		return ErrNotChannelMember
+	}
+
+	if err := s.repo.RemoveMember(ctx, channelID, userID); err != nil {
+		return err
+	}
+	// Anyone can subscribe to a public channel and get its key, so only
+	// leaving a private one calls for a rekey
+	if !channel.Public {
+		if err := s.requireRekey(ctx, channel, userID); err != nil {
+			return err
+		}
+	}
+
+	s.publish(ctx, "MEMBER_LEFT", channelID, map[string]interface{}{
+		"user_id": userID,
+	})
+	return nil
+}
@@ -0,0 +1,303 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegraph/internal/acl"
+	"telegraph/internal/users"
+
+	"github.com/google/uuid"
+)
+
+func (s *commandService) registerBuiltins() {
+	s.registry.Register(&Command{
+		Name:        "help",
+		Description: "List the commands you can run here",
+		Usage:       "/help",
+		Run:         s.help,
+	})
+	s.registry.Register(&Command{
+		Name:        "invite",
+		Description: "Add a user to this channel",
+		Usage:       "/invite <user id | email | phone>",
+		AdminOnly:   true,
+		Permission:  acl.PermissionManageMembers,
+		Run:         s.invite,
+	})
+	s.registry.Register(&Command{
+		Name:        "mute",
+		Description: "Stop a member from posting for a while",
+		Usage:       "/mute <user id | email | phone> <duration, e.g. 10m>",
+		AdminOnly:   true,
+		Permission:  acl.PermissionManageMembers,
+		Run:         s.mute,
+	})
+	s.registry.Register(&Command{
+		Name:        "unmute",
+		Description: "Let a muted member post again",
+		Usage:       "/unmute <user id | email | phone>",
+		AdminOnly:   true,
+		Permission:  acl.PermissionManageMembers,
+		Run:         s.unmute,
+	})
+	s.registry.Register(&Command{
+		Name:        "poll",
+		Description: "Start a poll",
+		Usage:       `/poll "<question>" <option> <option> [...]`,
+		Run:         s.poll,
+	})
+	s.registry.Register(&Command{
+		Name:        "vote",
+		Description: "Vote in a poll",
+		Usage:       "/vote <poll id> <option number>",
+		Run:         s.vote,
+	})
+	s.registry.Register(&Command{
+		Name:        "remind",
+		Description: "Remind yourself about something later (at most 24h)",
+		Usage:       "/remind <duration, e.g. 30m> <text>",
+		Run:         s.remind,
+	})
+}
+
+func usage(name string, registry *Registry) error {
+	if cmd, ok := registry.Lookup(name); ok {
+		return fmt.Errorf("%w: usage: %s", ErrUsage, cmd.Usage)
+	}
+	return ErrUsage
+}
+
+func (s *commandService) help(ctx context.Context, inv *Invocation) (*Response, error) {
+	infos, err := s.ListCommands(ctx, inv.ChannelID, inv.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(infos))
+	for _, info := range infos {
+		lines = append(lines, fmt.Sprintf("%s - %s", info.Usage, info.Description))
+	}
+	return &Response{Type: ResponseEphemeral, Text: strings.Join(lines, "\n"), Data: infos}, nil
+}
+
+func (s *commandService) invite(ctx context.Context, inv *Invocation) (*Response, error) {
+	if len(inv.Args) != 1 {
+		return nil, usage(inv.Name, s.registry)
+	}
+
+	user, err := s.resolveUser(ctx, inv.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.channelSvc.AddMember(ctx, inv.ChannelID, inv.UserID, user.ID); err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Type: ResponseInChannel,
+		Text: fmt.Sprintf("%s was added to the channel", user.Username),
+		Data: map[string]string{"user_id": user.ID.String()},
+	}, nil
+}
+
+func (s *commandService) mute(ctx context.Context, inv *Invocation) (*Response, error) {
+	if len(inv.Args) != 2 {
+		return nil, usage(inv.Name, s.registry)
+	}
+	duration, err := time.ParseDuration(inv.Args[1])
+	if err != nil || duration <= 0 || duration > MaxMuteDuration {
+		return nil, usage(inv.Name, s.registry)
+	}
+
+	user, err := s.resolveUser(ctx, inv.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	until := time.Now().Add(duration)
+	if err := s.channelSvc.MuteMember(ctx, inv.ChannelID, inv.UserID, user.ID, &until); err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Type: ResponseInChannel,
+		Text: fmt.Sprintf("%s is muted for %s", user.Username, duration),
+		Data: map[string]interface{}{"user_id": user.ID, "muted_until": until},
+	}, nil
+}
+
+func (s *commandService) unmute(ctx context.Context, inv *Invocation) (*Response, error) {
+	if len(inv.Args) != 1 {
+		return nil, usage(inv.Name, s.registry)
+	}
+
+	user, err := s.resolveUser(ctx, inv.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := s.channelSvc.MuteMember(ctx, inv.ChannelID, inv.UserID, user.ID, nil); err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Type: ResponseInChannel,
+		Text: fmt.Sprintf("%s can post again", user.Username),
+		Data: map[string]string{"user_id": user.ID.String()},
+	}, nil
+}
+
+func (s *commandService) poll(ctx context.Context, inv *Invocation) (*Response, error) {
+	if len(inv.Args) < 3 || len(inv.Args) > MaxPollOptions+1 {
+		return nil, usage(inv.Name, s.registry)
+	}
+
+	// The server stores and shows the question and options in plaintext, so
+	// end-to-end encrypted channels can't have polls
+	channel, err := s.channelRepo.GetByID(ctx, inv.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.ServerManaged() {
+		return nil, ErrPollsUnavailable
+	}
+	release, err := s.posts.ReservePost(channel, inv.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	poll := &Poll{
+		ChannelID: inv.ChannelID,
+		CreatedBy: inv.UserID,
+		Question:  inv.Args[0],
+	}
+	for _, text := range inv.Args[1:] {
+		poll.Options = append(poll.Options, PollOption{Text: text, Votes: []uuid.UUID{}})
+	}
+	if err := s.polls.Create(ctx, poll); err != nil {
+		release()
+		return nil, err
+	}
+
+	return &Response{Type: ResponseInChannel, Text: poll.Question, Data: poll}, nil
+}
+
+func (s *commandService) vote(ctx context.Context, inv *Invocation) (*Response, error) {
+	if len(inv.Args) != 2 {
+		return nil, usage(inv.Name, s.registry)
+	}
+	pollID, err := uuid.Parse(inv.Args[0])
+	if err != nil {
+		return nil, usage(inv.Name, s.registry)
+	}
+	option, err := strconv.Atoi(inv.Args[1])
+	if err != nil {
+		return nil, usage(inv.Name, s.registry)
+	}
+
+	poll, err := s.polls.GetByID(ctx, pollID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.ChannelID != inv.ChannelID {
+		return nil, ErrPollNotFound
+	}
+	// Options are numbered from 1 for people
+	if option < 1 || option > len(poll.Options) {
+		return nil, ErrInvalidPollOption
+	}
+
+	poll, err = s.polls.Vote(ctx, pollID, option-1, inv.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.hub != nil {
+		channel, err := s.channelRepo.GetByID(ctx, inv.ChannelID)
+		if err == nil {
+			s.hub.SendToUsers(channel.MemberIDs(), map[string]interface{}{
+				"type":       "POLL_UPDATED",
+				"channel_id": inv.ChannelID.String(),
+				"poll":       poll,
+			})
+		}
+	}
+
+	return &Response{
+		Type: ResponseEphemeral,
+		Text: fmt.Sprintf("Voted for %q", poll.Options[option-1].Text),
+		Data: poll,
+	}, nil
+}
+
+// remind schedules an in-memory reminder. Reminders do not survive a restart,
+// which is why they are capped at MaxReminderWait, and each user can only
+// have MaxPendingReminders of them at a time.
+func (s *commandService) remind(ctx context.Context, inv *Invocation) (*Response, error) {
+	if len(inv.Args) < 2 {
+		return nil, usage(inv.Name, s.registry)
+	}
+	wait, err := time.ParseDuration(inv.Args[0])
+	if err != nil || wait <= 0 || wait > MaxReminderWait {
+		return nil, usage(inv.Name, s.registry)
+	}
+	text := strings.Join(inv.Args[1:], " ")
+
+	if !s.addReminder(inv.UserID) {
+		return nil, ErrTooManyReminders
+	}
+
+	userID := inv.UserID.String()
+	channelID := inv.ChannelID.String()
+	time.AfterFunc(wait, func() {
+		s.doneReminder(inv.UserID)
+		if s.hub == nil {
+			return
+		}
+		s.hub.SendToUser(userID, map[string]interface{}{
+			"type":       "REMINDER",
+			"channel_id": channelID,
+			"text":       text,
+		})
+	})
+
+	return &Response{
+		Type: ResponseEphemeral,
+		Text: fmt.Sprintf("I'll remind you in %s", wait),
+	}, nil
+}
+
+// addReminder counts a new reminder against the user's rate limit and
+// pending cap, and reports whether it may be scheduled
+func (s *commandService) addReminder(userID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reminders[userID] >= MaxPendingReminders {
+		return false
+	}
+	if ok, _ := s.limiter.Allow("remind:"+userID.String(), ReminderLimit, ReminderWindow); !ok {
+		return false
+	}
+	s.reminders[userID]++
+	return true
+}
+
+func (s *commandService) doneReminder(userID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reminders[userID] <= 1 {
+		delete(s.reminders, userID)
+		return
+	}
+	s.reminders[userID]--
+}
+
+// resolveUser finds a user by ID, email or phone
+func (s *commandService) resolveUser(ctx context.Context, ref string) (*users.User, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		return s.userRepo.GetByID(ctx, id)
+	}
+	return s.userRepo.GetByEmailOrPhone(ctx, strings.ToLower(ref))
+}
@@ -0,0 +1,18 @@
+package commands
+
+import "errors"
+
+var (
+	ErrNotACommand       = errors.New("commands must start with /")
+	ErrUnknownCommand    = errors.New("unknown command")
+	ErrForbidden         = errors.New("you are not allowed to run this command here")
+	ErrUsage             = errors.New("invalid command arguments")
+	ErrInvalidName       = errors.New("command names must be 1-32 lowercase letters, digits or underscores")
+	ErrReservedName      = errors.New("command name is reserved by a built-in command")
+	ErrNotBot            = errors.New("only bots can register commands")
+	ErrCommandNotFound   = errors.New("bot command not found")
+	ErrPollNotFound      = errors.New("poll not found")
+	ErrInvalidPollOption = errors.New("invalid poll option")
+	ErrPollsUnavailable  = errors.New("polls are only available in channels with server-managed encryption")
+	ErrTooManyReminders  = errors.New("too many reminders, try again later")
+)
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"telegraph/internal/channels"
+	"telegraph/internal/messages"
+	"telegraph/internal/middleware"
+	"telegraph/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service CommandService
+}
+
+func NewHandler(service CommandService) *Handler {
+	return &Handler{service: service}
+}
+
+// Routes for bots managing their own commands
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/bot", h.ListBotCommands)
+	r.Put("/bot", h.RegisterBotCommand)
+	r.Delete("/bot/{name}", h.DeleteBotCommand)
+
+	return r
+}
+
+// Invoke runs a command line in a channel
+func (h *Handler) Invoke(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelId"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	var req InvokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := h.service.Execute(r.Context(), channelID, user.ID, user.Role, req.Text)
+	if err != nil {
+		respondCommandError(w, err)
+		return
+	}
+
+	respondJSON(w, resp, http.StatusOK)
+}
+
+// ListCommands lists the commands available in a channel
+func (h *Handler) ListCommands(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelId"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	infos, err := h.service.ListCommands(r.Context(), channelID, user.ID)
+	if err != nil {
+		respondCommandError(w, err)
+		return
+	}
+
+	respondJSON(w, infos, http.StatusOK)
+}
+
+func (h *Handler) RegisterBotCommand(w http.ResponseWriter, r *http.Request) {
+	var req RegisterBotCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cmd, err := h.service.RegisterBotCommand(r.Context(), user.ID, req)
+	if err != nil {
+		respondCommandError(w, err)
+		return
+	}
+
+	respondJSON(w, cmd, http.StatusOK)
+}
+
+func (h *Handler) ListBotCommands(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	cmds, err := h.service.ListBotCommands(r.Context(), user.ID)
+	if err != nil {
+		respondCommandError(w, err)
+		return
+	}
+
+	respondJSON(w, cmds, http.StatusOK)
+}
+
+func (h *Handler) DeleteBotCommand(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteBotCommand(r.Context(), user.ID, chi.URLParam(r, "name")); err != nil {
+		respondCommandError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "command_deleted"}, http.StatusOK)
+}
+
+func respondCommandError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUsage) {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rateErr *messages.RateLimitError
+	if errors.As(err, &rateErr) {
+		w.Header().Set("Retry-After", strconv.Itoa(rateErr.RetryAfterSeconds()))
+		respondError(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	switch err {
+	case ErrNotACommand, ErrInvalidName, ErrReservedName, ErrInvalidPollOption, ErrPollsUnavailable:
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case ErrForbidden, ErrNotBot, channels.ErrNotChannelOwner, channels.ErrCannotMuteAdmin,
+		messages.ErrMuted, messages.ErrBroadcastReadOnly:
+		respondError(w, err.Error(), http.StatusForbidden)
+	case ErrTooManyReminders:
+		respondError(w, err.Error(), http.StatusTooManyRequests)
+	case ErrUnknownCommand, ErrCommandNotFound, ErrPollNotFound, channels.ErrChannelNotFound,
+		channels.ErrNotChannelMember, users.ErrUserNotFound:
+		respondError(w, err.Error(), http.StatusNotFound)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
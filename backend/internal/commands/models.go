@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResponseType controls who sees a command's response
+type ResponseType string
+
+const (
+	ResponseEphemeral ResponseType = "ephemeral"  // Only the caller
+	ResponseInChannel ResponseType = "in_channel" // Every channel member
+)
+
+// Limits for built-in commands
+const (
+	MaxPollOptions  = 10
+	MaxReminderWait = 24 * time.Hour
+	MaxMuteDuration = 30 * 24 * time.Hour
+
+	MaxPendingReminders = 10 // Per user, since reminders are held in memory
+	ReminderLimit       = 30 // New reminders per user per ReminderWindow
+	ReminderWindow      = time.Hour
+)
+
+// Invocation is a single command call
+type Invocation struct {
+	ChannelID uuid.UUID
+	UserID    uuid.UUID
+	UserRole  string // System role, for acl checks
+	Name      string // Without the leading slash
+	Args      []string
+}
+
+// Response is returned to the caller and, for in-channel responses,
+// delivered to every channel member
+type Response struct {
+	Type ResponseType `json:"response_type"`
+	Text string       `json:"text"`
+	Data interface{}  `json:"data,omitempty"`
+}
+
+// Info describes a command available in a channel
+type Info struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Usage       string     `json:"usage"`
+	BotID       *uuid.UUID `json:"bot_id,omitempty"` // Set for bot commands
+}
+
+// BotCommand is a command a bot has registered. It can be invoked in any
+// channel the bot is a member of; invocations are delivered to the bot.
+type BotCommand struct {
+	ID          uuid.UUID `json:"id" bson:"_id"`
+	BotID       uuid.UUID `json:"bot_id" bson:"bot_id"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description" bson:"description"`
+	Usage       string    `json:"usage" bson:"usage"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+}
+
+// PollOption is one choice in a poll
+type PollOption struct {
+	Text  string      `json:"text" bson:"text"`
+	Votes []uuid.UUID `json:"votes" bson:"votes"`
+}
+
+// Poll is created by the /poll command
+type Poll struct {
+	ID        uuid.UUID    `json:"id" bson:"_id"`
+	ChannelID uuid.UUID    `json:"channel_id" bson:"channel_id"`
+	CreatedBy uuid.UUID    `json:"created_by" bson:"created_by"`
+	Question  string       `json:"question" bson:"question"`
+	Options   []PollOption `json:"options" bson:"options"`
+	CreatedAt time.Time    `json:"created_at" bson:"created_at"`
+}
+
+// InvokeRequest is the payload for running a command, e.g. {"text": "/mute bob@example.com 10m"}
+type InvokeRequest struct {
+	Text string `json:"text"`
+}
+
+// RegisterBotCommandRequest is the payload a bot uses to register a command
+type RegisterBotCommandRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Usage       string `json:"usage"`
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"telegraph/internal/acl"
+)
+
+// RunFunc executes a command
+type RunFunc func(ctx context.Context, inv *Invocation) (*Response, error)
+
+// Command is a built-in command
+type Command struct {
+	Name        string
+	Description string
+	Usage       string
+
+	// AdminOnly commands need a channel owner or admin, or a caller whose
+	// system role grants Permission
+	AdminOnly  bool
+	Permission acl.Permission
+
+	Run RunFunc
+}
+
+// Registry holds the built-in commands by name
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]*Command
+}
+
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]*Command)}
+}
+
+// Register adds a command, replacing any existing one with the same name
+func (r *Registry) Register(cmd *Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[cmd.Name] = cmd
+}
+
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// List returns all commands sorted by name
+func (r *Registry) List() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		list = append(list, cmd)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Parse splits a command line such as `/poll "Lunch?" pizza "dim sum"` into
+// the lowercased command name and its arguments. Double quotes group words.
+func Parse(text string) (string, []string, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", nil, ErrNotACommand
+	}
+
+	var (
+		fields  []string
+		current strings.Builder
+		quoted  bool
+		started bool
+	)
+	for _, r := range text[1:] {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if started {
+				fields = append(fields, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return "", nil, ErrUsage
+	}
+	if started {
+		fields = append(fields, current.String())
+	}
+
+	if len(fields) == 0 || fields[0] == "" {
+		return "", nil, ErrNotACommand
+	}
+	return strings.ToLower(fields[0]), fields[1:], nil
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+		args []string
+	}{
+		{"/help", "help", []string{}},
+		{"  /Mute bob@example.com 10m ", "mute", []string{"bob@example.com", "10m"}},
+		{`/poll "Lunch today?" pizza "dim sum"`, "poll", []string{"Lunch today?", "pizza", "dim sum"}},
+		{`/remind 5m  stand-up ""`, "remind", []string{"5m", "stand-up", ""}},
+	}
+
+	for _, tt := range tests {
+		name, args, err := Parse(tt.text)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", tt.text, err)
+			continue
+		}
+		if name != tt.name || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("Parse(%q) = %q %q, want %q %q", tt.text, name, args, tt.name, tt.args)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	if _, _, err := Parse("hello"); err != ErrNotACommand {
+		t.Errorf("plain text: got %v, want ErrNotACommand", err)
+	}
+	if _, _, err := Parse("/"); err != ErrNotACommand {
+		t.Errorf("bare slash: got %v, want ErrNotACommand", err)
+	}
+	if _, _, err := Parse(`/poll "unterminated`); err != ErrUsage {
+		t.Errorf("unterminated quote: got %v, want ErrUsage", err)
+	}
+}
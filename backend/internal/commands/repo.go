@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BotCommandRepo interface {
+	Upsert(ctx context.Context, cmd *BotCommand) error
+	ListByBot(ctx context.Context, botID uuid.UUID) ([]*BotCommand, error)
+	ListByBots(ctx context.Context, botIDs []uuid.UUID) ([]*BotCommand, error)
+	FindByName(ctx context.Context, name string) ([]*BotCommand, error)
+	Delete(ctx context.Context, botID uuid.UUID, name string) error
+}
+
+type PollRepo interface {
+	Create(ctx context.Context, poll *Poll) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Poll, error)
+	Vote(ctx context.Context, id uuid.UUID, option int, userID uuid.UUID) (*Poll, error)
+}
+
+type mongoBotCommandRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoBotCommandRepo(db *mongo.Database) BotCommandRepo {
+	return &mongoBotCommandRepo{
+		collection: db.Collection("bot_commands"),
+	}
+}
+
+// Upsert registers a command for a bot, replacing its previous definition
+func (r *mongoBotCommandRepo) Upsert(ctx context.Context, cmd *BotCommand) error {
+	filter := bson.M{"bot_id": cmd.BotID, "name": cmd.Name}
+	update := bson.M{
+		"$set": bson.M{
+			"description": cmd.Description,
+			"usage":       cmd.Usage,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        uuid.New(),
+			"created_at": time.Now(),
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(cmd)
+}
+
+func (r *mongoBotCommandRepo) ListByBot(ctx context.Context, botID uuid.UUID) ([]*BotCommand, error) {
+	return r.find(ctx, bson.M{"bot_id": botID})
+}
+
+func (r *mongoBotCommandRepo) ListByBots(ctx context.Context, botIDs []uuid.UUID) ([]*BotCommand, error) {
+	return r.find(ctx, bson.M{"bot_id": bson.M{"$in": botIDs}})
+}
+
+func (r *mongoBotCommandRepo) FindByName(ctx context.Context, name string) ([]*BotCommand, error) {
+	return r.find(ctx, bson.M{"name": name})
+}
+
+func (r *mongoBotCommandRepo) find(ctx context.Context, filter bson.M) ([]*BotCommand, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var cmds []*BotCommand
+	if err := cursor.All(ctx, &cmds); err != nil {
+		return nil, err
+	}
+	return cmds, nil
+}
+
+func (r *mongoBotCommandRepo) Delete(ctx context.Context, botID uuid.UUID, name string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"bot_id": botID, "name": name})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrCommandNotFound
+	}
+	return nil
+}
+
+type mongoPollRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoPollRepo(db *mongo.Database) PollRepo {
+	return &mongoPollRepo{
+		collection: db.Collection("polls"),
+	}
+}
+
+func (r *mongoPollRepo) Create(ctx context.Context, poll *Poll) error {
+	poll.ID = uuid.New()
+	poll.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, poll)
+	return err
+}
+
+func (r *mongoPollRepo) GetByID(ctx context.Context, id uuid.UUID) (*Poll, error) {
+	var poll Poll
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&poll)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+// Vote moves the user's vote to the given option (0-based) and returns the updated poll
+func (r *mongoPollRepo) Vote(ctx context.Context, id uuid.UUID, option int, userID uuid.UUID) (*Poll, error) {
+	if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$pull": bson.M{"options.$[].votes": userID},
+	}); err != nil {
+		return nil, err
+	}
+
+	field := fmt.Sprintf("options.%d.votes", option)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var poll Poll
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{
+		"$addToSet": bson.M{field: userID},
+	}, opts).Decode(&poll)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPollNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"telegraph/internal/acl"
+	"telegraph/internal/audit"
+	"telegraph/internal/channels"
+	"telegraph/internal/ratelimit"
+	"telegraph/internal/users"
+
+	"github.com/google/uuid"
+)
+
+type CommandService interface {
+	Execute(ctx context.Context, channelID, userID uuid.UUID, userRole, text string) (*Response, error)
+	ListCommands(ctx context.Context, channelID, userID uuid.UUID) ([]Info, error)
+	RegisterBotCommand(ctx context.Context, botID uuid.UUID, req RegisterBotCommandRequest) (*BotCommand, error)
+	ListBotCommands(ctx context.Context, botID uuid.UUID) ([]*BotCommand, error)
+	DeleteBotCommand(ctx context.Context, botID uuid.UUID, name string) error
+}
+
+// Hub interface for WebSocket delivery
+type Hub interface {
+	SendToUser(userID string, message interface{})
+	SendToUsers(userIDs []string, message interface{})
+}
+
+// PostGate applies the message service's posting rules (mute, broadcast
+// read-only, slow mode) to commands that post into the channel
+type PostGate interface {
+	ReservePost(channel *channels.Channel, senderID uuid.UUID) (func(), error)
+}
+
+type commandService struct {
+	registry    *Registry
+	botCommands BotCommandRepo
+	polls       PollRepo
+	channelRepo channels.ChannelRepo
+	channelSvc  channels.ChannelService
+	userRepo    users.UserRepo
+	posts       PostGate
+	hub         Hub
+	audit       *audit.Logger
+	limiter     *ratelimit.Limiter
+
+	mu        sync.Mutex
+	reminders map[uuid.UUID]int // Pending reminders per user
+}
+
+func NewCommandService(botCommands BotCommandRepo, polls PollRepo, channelRepo channels.ChannelRepo, channelSvc channels.ChannelService, userRepo users.UserRepo, posts PostGate, hub Hub, audit *audit.Logger) CommandService {
+	s := &commandService{
+		registry:    NewRegistry(),
+		botCommands: botCommands,
+		polls:       polls,
+		channelRepo: channelRepo,
+		channelSvc:  channelSvc,
+		userRepo:    userRepo,
+		posts:       posts,
+		hub:         hub,
+		audit:       audit,
+		limiter:     ratelimit.NewLimiter(),
+		reminders:   make(map[uuid.UUID]int),
+	}
+	s.registerBuiltins()
+	return s
+}
+
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// Execute parses and runs a command line in a channel. Built-in commands
+// take precedence; otherwise the call is forwarded to a bot in the channel
+// that registered the command.
+func (s *commandService) Execute(ctx context.Context, channelID, userID uuid.UUID, userRole, text string) (*Response, error) {
+	name, args, err := Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &Invocation{
+		ChannelID: channelID,
+		UserID:    userID,
+		UserRole:  userRole,
+		Name:      name,
+		Args:      args,
+	}
+
+	var resp *Response
+	if cmd, ok := s.registry.Lookup(name); ok {
+		if !allowed(cmd, channel, inv) {
+			return nil, ErrForbidden
+		}
+		resp, err = cmd.Run(ctx, inv)
+	} else {
+		if channel.MemberRole(userID) == "" {
+			return nil, ErrForbidden
+		}
+		resp, err = s.forwardToBot(ctx, channel, inv)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.respond(channel, inv, resp)
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventCommandExecuted,
+		Resource: channelID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Ran /%s in channel %s", name, channelID),
+	})
+
+	return resp, nil
+}
+
+// allowed checks a built-in command against the caller's channel role and system permissions
+func allowed(cmd *Command, channel *channels.Channel, inv *Invocation) bool {
+	if cmd.Permission != "" && acl.HasPermission(inv.UserRole, cmd.Permission) {
+		return true
+	}
+	if channel.MemberRole(inv.UserID) == "" {
+		return false
+	}
+	if cmd.AdminOnly {
+		return channel.IsOwnerOrAdmin(inv.UserID)
+	}
+	return true
+}
+
+// forwardToBot delivers the invocation to the first bot in the channel that
+// registered the command. The bot answers by posting to the channel.
+func (s *commandService) forwardToBot(ctx context.Context, channel *channels.Channel, inv *Invocation) (*Response, error) {
+	cmds, err := s.botCommands.FindByName(ctx, inv.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cmd := range cmds {
+		if channel.MemberRole(cmd.BotID) == "" {
+			continue
+		}
+
+		if s.hub != nil {
+			s.hub.SendToUser(cmd.BotID.String(), map[string]interface{}{
+				"type":          "COMMAND_INVOKED",
+				"invocation_id": uuid.New().String(),
+				"channel_id":    inv.ChannelID.String(),
+				"user_id":       inv.UserID.String(),
+				"command":       inv.Name,
+				"args":          inv.Args,
+			})
+		}
+		return &Response{
+			Type: ResponseEphemeral,
+			Text: fmt.Sprintf("Sent /%s to the bot", inv.Name),
+		}, nil
+	}
+
+	return nil, ErrUnknownCommand
+}
+
+// respond delivers the response over WebSocket: to the caller only, or to
+// every member for in-channel responses
+func (s *commandService) respond(channel *channels.Channel, inv *Invocation, resp *Response) {
+	if s.hub == nil {
+		return
+	}
+
+	event := map[string]interface{}{
+		"type":       "COMMAND_RESPONSE",
+		"channel_id": inv.ChannelID.String(),
+		"user_id":    inv.UserID.String(),
+		"command":    inv.Name,
+		"response":   resp,
+	}
+	if resp.Type == ResponseInChannel {
+		s.hub.SendToUsers(channel.MemberIDs(), event)
+		return
+	}
+	s.hub.SendToUser(inv.UserID.String(), event)
+}
+
+// ListCommands returns the built-in commands the caller may run plus the
+// commands of bots in the channel
+func (s *commandService) ListCommands(ctx context.Context, channelID, userID uuid.UUID) ([]Info, error) {
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if channel.MemberRole(userID) == "" {
+		return nil, ErrForbidden
+	}
+
+	var infos []Info
+	for _, cmd := range s.registry.List() {
+		if cmd.AdminOnly && !channel.IsOwnerOrAdmin(userID) {
+			continue
+		}
+		infos = append(infos, Info{Name: cmd.Name, Description: cmd.Description, Usage: cmd.Usage})
+	}
+
+	memberIDs := make([]uuid.UUID, 0, len(channel.Members))
+	for _, m := range channel.Members {
+		memberIDs = append(memberIDs, m.UserID)
+	}
+	botCmds, err := s.botCommands.ListByBots(ctx, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range botCmds {
+		botID := cmd.BotID
+		infos = append(infos, Info{Name: cmd.Name, Description: cmd.Description, Usage: cmd.Usage, BotID: &botID})
+	}
+
+	return infos, nil
+}
+
+func (s *commandService) RegisterBotCommand(ctx context.Context, botID uuid.UUID, req RegisterBotCommandRequest) (*BotCommand, error) {
+	name := strings.ToLower(strings.TrimPrefix(req.Name, "/"))
+	if !commandNamePattern.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+	if _, ok := s.registry.Lookup(name); ok {
+		return nil, ErrReservedName
+	}
+
+	bot, err := s.userRepo.GetByID(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	if !bot.Bot {
+		return nil, ErrNotBot
+	}
+
+	cmd := &BotCommand{
+		BotID:       botID,
+		Name:        name,
+		Description: req.Description,
+		Usage:       req.Usage,
+	}
+	if err := s.botCommands.Upsert(ctx, cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func (s *commandService) ListBotCommands(ctx context.Context, botID uuid.UUID) ([]*BotCommand, error) {
+	return s.botCommands.ListByBot(ctx, botID)
+}
+
+func (s *commandService) DeleteBotCommand(ctx context.Context, botID uuid.UUID, name string) error {
+	return s.botCommands.Delete(ctx, botID, strings.ToLower(name))
+}
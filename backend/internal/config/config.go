@@ -1,19 +1,49 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
 	"os"
+	"strings"
 )
 
+// placeholderJWTSecret is the example secret from older configs; a server
+// signing with it would accept tokens anyone can forge
+const placeholderJWTSecret = "your-secret-key-change-in-production"
+
 type Config struct {
 	MongoURI     string
 	DatabaseName string
-	JWTSecret    string
+
+	// JWTSecret signs access tokens with HS256 when JWTKeysDir is unset.
+	// With a key ring it only verifies tokens issued before the switch;
+	// unset it once those have expired.
+	JWTSecret string
+
+	// JWTKeysDir holds PEM signing keys named <kid>.pem. When set, access
+	// tokens are signed with the active key instead of JWTSecret.
+	JWTKeysDir      string
+	JWTSigningKeyID string
 	
 	SMTPHost     string
 	SMTPPort     string
 	SMTPEmail    string
 	SMTPPassword string
+
+	// MasterEncryptionKey wraps the per-channel data keys of server-managed
+	// channels. Nil disables server-managed encryption.
+	MasterEncryptionKey []byte
+
+	// WebAuthn relying party: the domain passkeys are bound to and the
+	// origins the web client is served from
+	WebAuthnRPID    string
+	WebAuthnOrigins []string
+
+	// TrustedProxies are the reverse proxies allowed to report the client
+	// address in ClientIPHeader. Empty means clients connect directly.
+	TrustedProxies []*net.IPNet
+	ClientIPHeader string
 }
 
 func Load() (*Config, error) {
@@ -22,17 +52,79 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("MONGO_URI is required")
 	}
 
+	var masterKey []byte
+	if encoded := os.Getenv("MASTER_ENCRYPTION_KEY"); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("MASTER_ENCRYPTION_KEY must be 32 base64-encoded bytes")
+		}
+		masterKey = key
+	}
+
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == placeholderJWTSecret {
+		return nil, fmt.Errorf("JWT_SECRET must not be the example value")
+	}
+	if jwtSecret == "" && os.Getenv("JWT_KEYS_DIR") == "" {
+		return nil, fmt.Errorf("JWT_SECRET or JWT_KEYS_DIR is required")
+	}
+
+	trustedProxies, err := parseCIDRs(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+
 	return &Config{
 		MongoURI:     mongoURI,
 		DatabaseName: getEnv("DATABASE_NAME", "telegraph"),
-		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		JWTSecret:    jwtSecret,
+
+		JWTKeysDir:      getEnv("JWT_KEYS_DIR", ""),
+		JWTSigningKeyID: getEnv("JWT_SIGNING_KEY_ID", ""),
+
 		SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
 		SMTPPort:     getEnv("SMTP_PORT", "587"),
 		SMTPEmail:    getEnv("SMTP_EMAIL", ""),
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+
+		MasterEncryptionKey: masterKey,
+
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnOrigins: strings.Split(getEnv("WEBAUTHN_ORIGINS", "http://localhost:3000"), ","),
+
+		TrustedProxies: trustedProxies,
+		ClientIPHeader: getEnv("CLIENT_IP_HEADER", "X-Forwarded-For"),
 	}, nil
 }
 
+// parseCIDRs reads a comma-separated list of CIDRs or single IPs
+func parseCIDRs(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			item = fmt.Sprintf("%s/%d", item, bits)
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
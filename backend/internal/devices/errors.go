@@ -0,0 +1,11 @@
+package devices
+
+import "errors"
+
+var (
+	ErrDeviceNotFound  = errors.New("device not found")
+	ErrInvalidDeviceID = errors.New("invalid device id")
+	ErrInvalidName     = errors.New("device name is too long")
+	ErrInvalidPlatform = errors.New("invalid platform")
+	ErrTooManyDevices  = errors.New("too many devices registered")
+)
@@ -0,0 +1,107 @@
+package devices
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"telegraph/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	service DeviceService
+}
+
+func NewHandler(service DeviceService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", h.Register)
+	r.Get("/", h.List)
+	r.Delete("/{deviceId}", h.Remove)
+
+	return r
+}
+
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	device, err := h.service.Register(r.Context(), user.ID, req)
+	if err != nil {
+		respondDeviceError(w, err)
+		return
+	}
+
+	respondJSON(w, device, http.StatusOK)
+}
+
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	devices, err := h.service.List(r.Context(), user.ID)
+	if err != nil {
+		respondDeviceError(w, err)
+		return
+	}
+
+	respondJSON(w, devices, http.StatusOK)
+}
+
+func (h *Handler) Remove(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.Remove(r.Context(), user.ID, chi.URLParam(r, "deviceId")); err != nil {
+		respondDeviceError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "removed"}, http.StatusOK)
+}
+
+func respondDeviceError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidDeviceID, ErrInvalidName, ErrInvalidPlatform:
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case ErrDeviceNotFound:
+		respondError(w, err.Error(), http.StatusNotFound)
+	case ErrTooManyDevices:
+		respondError(w, err.Error(), http.StatusConflict)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,45 @@
+package devices
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Registry limits
+const (
+	MaxDeviceIDLength = 64 // Same limit as the key directory
+	MaxNameLength     = 100
+	MaxDevicesPerUser = 20
+)
+
+// Platform is the client a device runs
+type Platform string
+
+const (
+	PlatformWeb     Platform = "web"
+	PlatformIOS     Platform = "ios"
+	PlatformAndroid Platform = "android"
+	PlatformDesktop Platform = "desktop"
+	PlatformUnknown Platform = "unknown"
+)
+
+// Device is one of a user's logged-in clients. The device ID is chosen by the
+// client (or assigned at login) and is also used in the key directory.
+type Device struct {
+	ID           uuid.UUID `json:"-" bson:"_id"`
+	UserID       uuid.UUID `json:"user_id" bson:"user_id"`
+	DeviceID     string    `json:"device_id" bson:"device_id"`
+	Name         string    `json:"name" bson:"name"`
+	Platform     Platform  `json:"platform" bson:"platform"`
+	LastActiveAt time.Time `json:"last_active_at" bson:"last_active_at"`
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+}
+
+// RegisterDeviceRequest registers or renames a device. Identity keys are
+// published to the key directory, not here.
+type RegisterDeviceRequest struct {
+	DeviceID string   `json:"device_id"`
+	Name     string   `json:"name"`
+	Platform Platform `json:"platform"`
+}
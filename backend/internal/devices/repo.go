@@ -0,0 +1,103 @@
+package devices
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DeviceRepo interface {
+	Upsert(ctx context.Context, d *Device) error
+	Get(ctx context.Context, userID uuid.UUID, deviceID string) (*Device, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*Device, error)
+	Count(ctx context.Context, userID uuid.UUID) (int64, error)
+	Touch(ctx context.Context, userID uuid.UUID, deviceID string) error
+	Delete(ctx context.Context, userID uuid.UUID, deviceID string) error
+}
+
+type mongoDeviceRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDeviceRepo(db *mongo.Database) DeviceRepo {
+	return &mongoDeviceRepo{collection: db.Collection("devices")}
+}
+
+// Upsert registers a device or updates its name and platform, keeping
+// the original ID and creation time
+func (r *mongoDeviceRepo) Upsert(ctx context.Context, d *Device) error {
+	now := time.Now()
+	set := bson.M{
+		"name":           d.Name,
+		"platform":       d.Platform,
+		"last_active_at": now,
+	}
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"_id":        uuid.New(),
+			"created_at": now,
+		},
+	}
+	filter := bson.M{"user_id": d.UserID, "device_id": d.DeviceID}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(d)
+}
+
+func (r *mongoDeviceRepo) Get(ctx context.Context, userID uuid.UUID, deviceID string) (*Device, error) {
+	var d Device
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "device_id": deviceID}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *mongoDeviceRepo) List(ctx context.Context, userID uuid.UUID) ([]*Device, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_active_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var devices []*Device
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (r *mongoDeviceRepo) Count(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+func (r *mongoDeviceRepo) Touch(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	filter := bson.M{"user_id": userID, "device_id": deviceID}
+	res, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_active_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (r *mongoDeviceRepo) Delete(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "device_id": deviceID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package devices
+
+import (
+	"context"
+	"fmt"
+
+	"telegraph/internal/audit"
+
+	"github.com/google/uuid"
+)
+
+type DeviceService interface {
+	Register(ctx context.Context, userID uuid.UUID, req RegisterDeviceRequest) (*Device, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*Device, error)
+	Touch(ctx context.Context, userID uuid.UUID, deviceID string) error
+	Remove(ctx context.Context, userID uuid.UUID, deviceID string) error
+}
+
+// TokenRevoker revokes the refresh tokens issued to a device
+type TokenRevoker interface {
+	RevokeDevice(ctx context.Context, userID uuid.UUID, deviceID string) error
+}
+
+// KeyDirectory drops a removed device's published keys
+type KeyDirectory interface {
+	RemoveDevice(ctx context.Context, userID uuid.UUID, deviceID string) error
+}
+
+// Hub interface for per-device WebSocket delivery
+type Hub interface {
+	SendToDevice(userID, deviceID string, message interface{})
+	DisconnectDevice(userID, deviceID string)
+}
+
+type deviceService struct {
+	repo   DeviceRepo
+	tokens TokenRevoker
+	keys   KeyDirectory
+	hub    Hub
+	audit  *audit.Logger
+}
+
+func NewDeviceService(repo DeviceRepo, tokens TokenRevoker, keys KeyDirectory, hub Hub, audit *audit.Logger) DeviceService {
+	return &deviceService{repo: repo, tokens: tokens, keys: keys, hub: hub, audit: audit}
+}
+
+// Register adds a device or updates an existing one's details. An empty
+// device ID gets a fresh one.
+func (s *deviceService) Register(ctx context.Context, userID uuid.UUID, req RegisterDeviceRequest) (*Device, error) {
+	if req.DeviceID == "" {
+		req.DeviceID = uuid.NewString()
+	}
+	if len(req.DeviceID) > MaxDeviceIDLength {
+		return nil, ErrInvalidDeviceID
+	}
+	if len(req.Name) > MaxNameLength {
+		return nil, ErrInvalidName
+	}
+	if req.Platform == "" {
+		req.Platform = PlatformUnknown
+	}
+	switch req.Platform {
+	case PlatformWeb, PlatformIOS, PlatformAndroid, PlatformDesktop, PlatformUnknown:
+	default:
+		return nil, ErrInvalidPlatform
+	}
+
+	_, err := s.repo.Get(ctx, userID, req.DeviceID)
+	isNew := err == ErrDeviceNotFound
+	if err != nil && !isNew {
+		return nil, err
+	}
+	if isNew {
+		count, err := s.repo.Count(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if count >= MaxDevicesPerUser {
+			return nil, ErrTooManyDevices
+		}
+	}
+
+	device := &Device{
+		UserID:   userID,
+		DeviceID: req.DeviceID,
+		Name:     req.Name,
+		Platform: req.Platform,
+	}
+	if err := s.repo.Upsert(ctx, device); err != nil {
+		return nil, err
+	}
+
+	if isNew {
+		s.audit.Log(ctx, audit.AuditLog{
+			UserID:   &userID,
+			Action:   audit.EventDeviceRegistered,
+			Resource: device.DeviceID,
+			Result:   "success",
+			Details:  fmt.Sprintf("Registered device '%s' (%s)", device.Name, device.Platform),
+		})
+	}
+
+	return device, nil
+}
+
+func (s *deviceService) List(ctx context.Context, userID uuid.UUID) ([]*Device, error) {
+	return s.repo.List(ctx, userID)
+}
+
+// Touch records activity on a device
+func (s *deviceService) Touch(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	return s.repo.Touch(ctx, userID, deviceID)
+}
+
+// Remove unregisters a device, revokes its refresh tokens, drops its keys
+// from the key directory and closes its sockets
+func (s *deviceService) Remove(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	if err := s.repo.Delete(ctx, userID, deviceID); err != nil {
+		return err
+	}
+	if err := s.tokens.RevokeDevice(ctx, userID, deviceID); err != nil {
+		return err
+	}
+	if err := s.keys.RemoveDevice(ctx, userID, deviceID); err != nil {
+		return err
+	}
+
+	if s.hub != nil {
+		// The hub flushes queued messages before closing, so the device sees why
+		s.hub.SendToDevice(userID.String(), deviceID, map[string]interface{}{
+			"type":      "DEVICE_REMOVED",
+			"device_id": deviceID,
+		})
+		s.hub.DisconnectDevice(userID.String(), deviceID)
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventDeviceRemoved,
+		Resource: deviceID,
+		Result:   "success",
+		Details:  fmt.Sprintf("Removed device %s, its keys and its tokens", deviceID),
+	})
+
+	return nil
+}
@@ -0,0 +1,15 @@
+package groupkeys
+
+import "errors"
+
+var (
+	ErrNoGroupKeys      = errors.New("only group and broadcast channels use channel keys")
+	ErrNotAllowed       = errors.New("only the channel owner and admins can distribute keys")
+	ErrNotMember        = errors.New("not a member of this channel")
+	ErrWrongEpoch       = errors.New("epoch does not match the channel's key epoch")
+	ErrMissingMembers   = errors.New("a rotation must include a key for every member")
+	ErrMembersChanged   = errors.New("channel members changed during the rotation; rotate again")
+	ErrUnknownRecipient = errors.New("keys can only be wrapped for channel members")
+	ErrInvalidKey       = errors.New("invalid wrapped key")
+	ErrKeyNotFound      = errors.New("no channel key has been shared with you for this epoch")
+)
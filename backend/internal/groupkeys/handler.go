@@ -0,0 +1,136 @@
+package groupkeys
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"telegraph/internal/channels"
+	"telegraph/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service GroupKeyService
+}
+
+func NewHandler(service GroupKeyService) *Handler {
+	return &Handler{service: service}
+}
+
+// GetMyKeys returns the caller's wrapped channel key (?epoch= for older epochs)
+func (h *Handler) GetMyKeys(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelId"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	var epoch *int64
+	if raw := r.URL.Query().Get("epoch"); raw != "" {
+		e, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			respondError(w, "invalid_epoch", http.StatusBadRequest)
+			return
+		}
+		epoch = &e
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.service.GetMyKeys(r.Context(), channelID, user.ID, epoch)
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, keys, http.StatusOK)
+}
+
+func (h *Handler) RotateKey(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelId"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	var req RotateKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RotateKey(r.Context(), channelID, user.ID, req); err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]int64{"key_epoch": req.Epoch}, http.StatusOK)
+}
+
+func (h *Handler) ShareKeys(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelId"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	var req ShareKeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.ShareKeys(r.Context(), channelID, user.ID, req); err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "keys_shared"}, http.StatusOK)
+}
+
+func respondKeyError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrNoGroupKeys, ErrMissingMembers, ErrUnknownRecipient, ErrInvalidKey:
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case ErrNotAllowed, ErrNotMember:
+		respondError(w, err.Error(), http.StatusForbidden)
+	case ErrKeyNotFound, channels.ErrChannelNotFound:
+		respondError(w, err.Error(), http.StatusNotFound)
+	case ErrWrongEpoch, ErrMembersChanged:
+		respondError(w, err.Error(), http.StatusConflict)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,48 @@
+package groupkeys
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxWrappedKeySize bounds a single wrapped channel key
+const MaxWrappedKeySize = 1024
+
+// RotationLease is how long a rotation may take to store its keys before
+// another rotation can claim the epoch
+const RotationLease = time.Minute
+
+// WrappedKey is one member device's copy of a channel key, encrypted by a
+// client to that device's public key. The server never sees the channel key.
+type WrappedKey struct {
+	ID         uuid.UUID `json:"-" bson:"_id"`
+	ChannelID  uuid.UUID `json:"channel_id" bson:"channel_id"`
+	Epoch      int64     `json:"epoch" bson:"epoch"`
+	UserID     uuid.UUID `json:"user_id" bson:"user_id"`
+	DeviceID   string    `json:"device_id" bson:"device_id"`
+	WrappedKey []byte    `json:"wrapped_key" bson:"wrapped_key"`
+	WrappedBy  uuid.UUID `json:"wrapped_by" bson:"wrapped_by"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+}
+
+// WrappedKeyUpload is one wrapped copy in a rotate or share request
+type WrappedKeyUpload struct {
+	UserID     uuid.UUID `json:"user_id"`
+	DeviceID   string    `json:"device_id"`
+	WrappedKey []byte    `json:"wrapped_key"` // base64 in JSON
+}
+
+// RotateKeyRequest starts a new key epoch. It must include a wrapped copy
+// for every remaining member.
+type RotateKeyRequest struct {
+	Epoch int64              `json:"epoch"` // Must be the current epoch + 1
+	Keys  []WrappedKeyUpload `json:"keys"`
+}
+
+// ShareKeysRequest adds wrapped copies of the current key, e.g. for new
+// members or devices
+type ShareKeysRequest struct {
+	Epoch int64              `json:"epoch"` // Must be the current epoch
+	Keys  []WrappedKeyUpload `json:"keys"`
+}
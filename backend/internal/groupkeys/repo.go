@@ -0,0 +1,79 @@
+package groupkeys
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type KeyRepo interface {
+	Store(ctx context.Context, keys []*WrappedKey) error
+	ListForUser(ctx context.Context, channelID uuid.UUID, epoch int64, userID uuid.UUID) ([]*WrappedKey, error)
+	DeleteEpoch(ctx context.Context, channelID uuid.UUID, epoch int64) error
+}
+
+type mongoKeyRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoKeyRepo(db *mongo.Database) KeyRepo {
+	return &mongoKeyRepo{
+		collection: db.Collection("channel_keys"),
+	}
+}
+
+// Store saves wrapped keys, replacing any earlier copy for the same device and epoch
+func (r *mongoKeyRepo) Store(ctx context.Context, keys []*WrappedKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, 0, len(keys))
+	for _, k := range keys {
+		k.CreatedAt = now
+		filter := bson.M{
+			"channel_id": k.ChannelID,
+			"epoch":      k.Epoch,
+			"user_id":    k.UserID,
+			"device_id":  k.DeviceID,
+		}
+		update := bson.M{
+			"$set": bson.M{
+				"wrapped_key": k.WrappedKey,
+				"wrapped_by":  k.WrappedBy,
+				"created_at":  k.CreatedAt,
+			},
+			"$setOnInsert": bson.M{"_id": uuid.New()},
+		}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(true))
+	}
+
+	_, err := r.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (r *mongoKeyRepo) ListForUser(ctx context.Context, channelID uuid.UUID, epoch int64, userID uuid.UUID) ([]*WrappedKey, error) {
+	filter := bson.M{"channel_id": channelID, "epoch": epoch, "user_id": userID}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var keys []*WrappedKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeleteEpoch removes every wrapped key of an epoch that never went live
+func (r *mongoKeyRepo) DeleteEpoch(ctx context.Context, channelID uuid.UUID, epoch int64) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"channel_id": channelID, "epoch": epoch})
+	return err
+}
@@ -0,0 +1,224 @@
+package groupkeys
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/channels"
+
+	"github.com/google/uuid"
+)
+
+// DefaultDeviceID matches the key directory's default device
+const DefaultDeviceID = "default"
+
+type GroupKeyService interface {
+	RotateKey(ctx context.Context, channelID, requestorID uuid.UUID, req RotateKeyRequest) error
+	ShareKeys(ctx context.Context, channelID, requestorID uuid.UUID, req ShareKeysRequest) error
+	GetMyKeys(ctx context.Context, channelID, userID uuid.UUID, epoch *int64) ([]*WrappedKey, error)
+}
+
+// Hub interface for WebSocket broadcasting
+type Hub interface {
+	SendToUsers(userIDs []string, message interface{})
+}
+
+type groupKeyService struct {
+	repo        KeyRepo
+	channelRepo channels.ChannelRepo
+	hub         Hub
+	audit       *audit.Logger
+}
+
+func NewGroupKeyService(repo KeyRepo, channelRepo channels.ChannelRepo, hub Hub, audit *audit.Logger) GroupKeyService {
+	return &groupKeyService{repo: repo, channelRepo: channelRepo, hub: hub, audit: audit}
+}
+
+// RotateKey moves the channel to a new key epoch. Every current member must
+// get a wrapped copy, so members removed before the rotation cannot read
+// anything sent afterwards.
+func (s *groupKeyService) RotateKey(ctx context.Context, channelID, requestorID uuid.UUID, req RotateKeyRequest) error {
+	channel, err := s.managedChannel(ctx, channelID, requestorID)
+	if err != nil {
+		return err
+	}
+	if req.Epoch != channel.KeyEpoch+1 {
+		return ErrWrongEpoch
+	}
+
+	keys, err := wrap(channel, requestorID, req.Epoch, req.Keys)
+	if err != nil {
+		return err
+	}
+
+	covered := make(map[uuid.UUID]bool, len(keys))
+	for _, k := range keys {
+		covered[k.UserID] = true
+	}
+	for _, m := range channel.Members {
+		if !covered[m.UserID] {
+			return ErrMissingMembers
+		}
+	}
+
+	// Claim the new epoch so two concurrent rotations cannot both store keys
+	// for it, then store the keys before the epoch goes live
+	if err := s.channelRepo.ClaimKeyRotation(ctx, channel, RotationLease); err != nil {
+		if err == channels.ErrKeyEpochConflict {
+			return ErrWrongEpoch
+		}
+		return err
+	}
+	if err := s.commitRotation(ctx, channel, keys); err != nil {
+		if relErr := s.channelRepo.ReleaseKeyRotation(ctx, channelID, req.Epoch); relErr != nil {
+			log.Printf("Releasing key rotation of channel %s: %v", channelID, relErr)
+		}
+		return err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &requestorID,
+		Action:   audit.EventChannelKeyRotated,
+		Resource: channelID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Rotated channel key to epoch %d for %d members", req.Epoch, len(channel.Members)),
+	})
+
+	if s.hub != nil {
+		s.hub.SendToUsers(channel.MemberIDs(), map[string]interface{}{
+			"type":       "CHANNEL_KEY_ROTATED",
+			"channel_id": channelID.String(),
+			"key_epoch":  req.Epoch,
+		})
+	}
+
+	return nil
+}
+
+// commitRotation stores the new epoch's keys and then advances the epoch.
+// Keys left over from an abandoned rotation of the same epoch are dropped
+// first, and the stored keys are dropped again if the advance fails.
+func (s *groupKeyService) commitRotation(ctx context.Context, channel *channels.Channel, keys []*WrappedKey) error {
+	epoch := channel.KeyEpoch + 1
+	if err := s.repo.DeleteEpoch(ctx, channel.ID, epoch); err != nil {
+		return err
+	}
+	if err := s.repo.Store(ctx, keys); err != nil {
+		return err
+	}
+
+	err := s.channelRepo.AdvanceKeyEpoch(ctx, channel)
+	if err == nil {
+		return nil
+	}
+	if delErr := s.repo.DeleteEpoch(ctx, channel.ID, epoch); delErr != nil {
+		log.Printf("Dropping keys of abandoned epoch %d in channel %s: %v", epoch, channel.ID, delErr)
+	}
+	if err == channels.ErrKeyRotationStale {
+		return ErrMembersChanged
+	}
+	return err
+}
+
+// ShareKeys hands the current key to members or devices that don't have it yet
+func (s *groupKeyService) ShareKeys(ctx context.Context, channelID, requestorID uuid.UUID, req ShareKeysRequest) error {
+	channel, err := s.managedChannel(ctx, channelID, requestorID)
+	if err != nil {
+		return err
+	}
+	if req.Epoch != channel.KeyEpoch {
+		return ErrWrongEpoch
+	}
+
+	keys, err := wrap(channel, requestorID, req.Epoch, req.Keys)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Store(ctx, keys); err != nil {
+		return err
+	}
+
+	if s.hub != nil {
+		recipients := make([]string, 0, len(keys))
+		for _, k := range keys {
+			recipients = append(recipients, k.UserID.String())
+		}
+		s.hub.SendToUsers(recipients, map[string]interface{}{
+			"type":       "CHANNEL_KEY_SHARED",
+			"channel_id": channelID.String(),
+			"key_epoch":  req.Epoch,
+		})
+	}
+
+	return nil
+}
+
+// GetMyKeys returns the caller's wrapped copies for an epoch (default: current).
+// Former members get nothing, including for epochs they were part of.
+func (s *groupKeyService) GetMyKeys(ctx context.Context, channelID, userID uuid.UUID, epoch *int64) ([]*WrappedKey, error) {
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.UsesGroupKeys() {
+		return nil, ErrNoGroupKeys
+	}
+	if channel.MemberRole(userID) == "" {
+		return nil, ErrNotMember
+	}
+
+	e := channel.KeyEpoch
+	if epoch != nil {
+		e = *epoch
+	}
+	keys, err := s.repo.ListForUser(ctx, channelID, e, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	return keys, nil
+}
+
+func (s *groupKeyService) managedChannel(ctx context.Context, channelID, requestorID uuid.UUID) (*channels.Channel, error) {
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.UsesGroupKeys() {
+		return nil, ErrNoGroupKeys
+	}
+	if !channel.IsOwnerOrAdmin(requestorID) {
+		return nil, ErrNotAllowed
+	}
+	return channel, nil
+}
+
+// wrap validates uploads and turns them into stored keys
+func wrap(channel *channels.Channel, requestorID uuid.UUID, epoch int64, uploads []WrappedKeyUpload) ([]*WrappedKey, error) {
+	keys := make([]*WrappedKey, 0, len(uploads))
+	for _, u := range uploads {
+		if channel.MemberRole(u.UserID) == "" {
+			return nil, ErrUnknownRecipient
+		}
+		if len(u.WrappedKey) == 0 || len(u.WrappedKey) > MaxWrappedKeySize {
+			return nil, ErrInvalidKey
+		}
+		deviceID := u.DeviceID
+		if deviceID == "" {
+			deviceID = DefaultDeviceID
+		}
+		keys = append(keys, &WrappedKey{
+			ChannelID:  channel.ID,
+			Epoch:      epoch,
+			UserID:     u.UserID,
+			DeviceID:   deviceID,
+			WrappedKey: u.WrappedKey,
+			WrappedBy:  requestorID,
+		})
+	}
+	return keys, nil
+}
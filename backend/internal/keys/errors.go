@@ -0,0 +1,17 @@
+package keys
+
+import "errors"
+
+var (
+	ErrKeysNotFound         = errors.New("no keys published for this device")
+	ErrInvalidKey           = errors.New("public keys must be 32 bytes")
+	ErrInvalidSignature     = errors.New("signed prekey signature does not verify against the identity key")
+	ErrInvalidDeviceID      = errors.New("invalid device id")
+	ErrTooManyPrekeys       = errors.New("too many one-time prekeys")
+	ErrDuplicatePrekey      = errors.New("duplicate one-time prekey id")
+	ErrFetchRateLimited     = errors.New("too many key bundle requests")
+	ErrSafetyNumberMismatch = errors.New("safety number does not match the current keys")
+	ErrVerifySelf           = errors.New("cannot verify your own keys")
+	ErrIdentityChangeDenied = errors.New("identity key change must be signed by the current key or another device")
+	ErrNotSessionDevice     = errors.New("keys can only be managed for the device the session belongs to")
+)
@@ -0,0 +1,57 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// fingerprintIterations slows down searching for colliding identity keys
+const fingerprintIterations = 5200
+
+// SafetyNumber combines both users' fingerprints into the 60-digit number
+// two contacts compare out of band. It is the same from either side.
+func SafetyNumber(a uuid.UUID, aKeys [][]byte, b uuid.UUID, bKeys [][]byte) string {
+	fa, fb := Fingerprint(a, aKeys), Fingerprint(b, bKeys)
+	if a.String() > b.String() {
+		fa, fb = fb, fa
+	}
+	return fa + fb
+}
+
+// Fingerprint derives a 30-digit number from a user's current identity keys,
+// one per device, in a stable order
+func Fingerprint(userID uuid.UUID, identityKeys [][]byte) string {
+	sorted := make([][]byte, len(identityKeys))
+	copy(sorted, identityKeys)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+	keys := bytes.Join(sorted, nil)
+
+	digest := sha512.Sum512(append(append([]byte{0, 0}, keys...), userID[:]...))
+	for i := 0; i < fingerprintIterations; i++ {
+		digest = sha512.Sum512(append(digest[:], keys...))
+	}
+
+	var sb strings.Builder
+	for i := 0; i < 30; i += 5 {
+		chunk := uint64(digest[i])<<32 | uint64(digest[i+1])<<24 | uint64(digest[i+2])<<16 |
+			uint64(digest[i+3])<<8 | uint64(digest[i+4])
+		fmt.Fprintf(&sb, "%05d", chunk%100000)
+	}
+	return sb.String()
+}
+
+// keyFingerprint is a short hex identifier for a single key in the change log
+func keyFingerprint(key []byte) string {
+	if key == nil {
+		return ""
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,38 @@
+package keys
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSafetyNumber_Symmetric(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	aliceKeys := [][]byte{bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)}
+	bobKeys := [][]byte{bytes.Repeat([]byte{3}, 32)}
+
+	ab := SafetyNumber(alice, aliceKeys, bob, bobKeys)
+	ba := SafetyNumber(bob, bobKeys, alice, aliceKeys)
+	if ab != ba {
+		t.Errorf("expected the same number from both sides, got %s and %s", ab, ba)
+	}
+	if len(ab) != 60 {
+		t.Errorf("expected 60 digits, got %d", len(ab))
+	}
+}
+
+func TestFingerprint_KeyOrderAndChanges(t *testing.T) {
+	user := uuid.New()
+	k1, k2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+
+	if Fingerprint(user, [][]byte{k1, k2}) != Fingerprint(user, [][]byte{k2, k1}) {
+		t.Error("fingerprint must not depend on device order")
+	}
+	if Fingerprint(user, [][]byte{k1}) == Fingerprint(user, [][]byte{k2}) {
+		t.Error("fingerprint must change when a key changes")
+	}
+	if Fingerprint(user, [][]byte{k1}) == Fingerprint(uuid.New(), [][]byte{k1}) {
+		t.Error("fingerprint must be bound to the user")
+	}
+}
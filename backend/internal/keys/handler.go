@@ -0,0 +1,303 @@
+package keys
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"telegraph/internal/middleware"
+	"telegraph/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service KeyService
+}
+
+func NewHandler(service KeyService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	// Own keys
+	r.Put("/", h.PublishKeys)
+	r.Put("/signed-prekey", h.RotateSignedPrekey)
+	r.Post("/prekeys", h.UploadPrekeys)
+	r.Get("/prekeys/count", h.CountPrekeys)
+
+	// Other users' bundles
+	r.Get("/history/{userId}", h.GetKeyHistory)
+	r.Get("/changes/{userId}", h.GetKeyChanges)
+
+	// Safety numbers
+	r.Get("/safety-number/{userId}", h.GetSafetyNumber)
+	r.Post("/verify/{userId}", h.VerifyContact)
+	r.Delete("/verify/{userId}", h.UnverifyContact)
+
+	r.Get("/{userId}", h.GetBundles)
+	r.Get("/{userId}/{deviceId}", h.GetBundle)
+
+	return r
+}
+
+func (h *Handler) PublishKeys(w http.ResponseWriter, r *http.Request) {
+	var req PublishKeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.service.PublishKeys(r.Context(), user.ID, req)
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, keys, http.StatusOK)
+}
+
+func (h *Handler) RotateSignedPrekey(w http.ResponseWriter, r *http.Request) {
+	var req RotateSignedPrekeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RotateSignedPrekey(r.Context(), user.ID, req); err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "signed_prekey_rotated"}, http.StatusOK)
+}
+
+func (h *Handler) UploadPrekeys(w http.ResponseWriter, r *http.Request) {
+	var req UploadPrekeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.service.UploadPrekeys(r.Context(), user.ID, req)
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]int64{"count": count}, http.StatusOK)
+}
+
+func (h *Handler) CountPrekeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := h.service.CountPrekeys(r.Context(), user.ID, r.URL.Query().Get("device_id"))
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]int64{"count": count}, http.StatusOK)
+}
+
+func (h *Handler) GetBundles(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, "invalid_user_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bundles, err := h.service.GetBundles(r.Context(), user.ID, userID)
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, bundles, http.StatusOK)
+}
+
+func (h *Handler) GetBundle(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, "invalid_user_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bundle, err := h.service.GetBundle(r.Context(), user.ID, userID, chi.URLParam(r, "deviceId"))
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, bundle, http.StatusOK)
+}
+
+// GetKeyHistory returns a user's identity key history for verifying message signatures
+func (h *Handler) GetKeyHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, "invalid_user_id", http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.service.GetKeyHistory(r.Context(), userID)
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, history, http.StatusOK)
+}
+
+// GetKeyChanges returns a user's append-only key-change log
+func (h *Handler) GetKeyChanges(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, "invalid_user_id", http.StatusBadRequest)
+		return
+	}
+
+	changes, err := h.service.GetKeyChanges(r.Context(), userID)
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, changes, http.StatusOK)
+}
+
+func (h *Handler) GetSafetyNumber(w http.ResponseWriter, r *http.Request) {
+	contactID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, "invalid_user_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	number, err := h.service.GetSafetyNumber(r.Context(), user.ID, contactID)
+	if err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, number, http.StatusOK)
+}
+
+func (h *Handler) VerifyContact(w http.ResponseWriter, r *http.Request) {
+	contactID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, "invalid_user_id", http.StatusBadRequest)
+		return
+	}
+
+	var req VerifyContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.VerifyContact(r.Context(), user.ID, contactID, req.SafetyNumber); err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "verified"}, http.StatusOK)
+}
+
+func (h *Handler) UnverifyContact(w http.ResponseWriter, r *http.Request) {
+	contactID, err := uuid.Parse(chi.URLParam(r, "userId"))
+	if err != nil {
+		respondError(w, "invalid_user_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.UnverifyContact(r.Context(), user.ID, contactID); err != nil {
+		respondKeyError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "unverified"}, http.StatusOK)
+}
+
+func respondKeyError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidKey, ErrInvalidSignature, ErrInvalidDeviceID, ErrTooManyPrekeys, ErrDuplicatePrekey, ErrVerifySelf:
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case ErrKeysNotFound, users.ErrUserNotFound:
+		respondError(w, err.Error(), http.StatusNotFound)
+	case ErrIdentityChangeDenied, ErrNotSessionDevice:
+		respondError(w, err.Error(), http.StatusForbidden)
+	case ErrSafetyNumberMismatch:
+		respondError(w, err.Error(), http.StatusConflict)
+	case ErrFetchRateLimited:
+		respondError(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,153 @@
+package keys
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Key sizes. Identity keys are Ed25519 so they can sign prekeys; prekeys
+// are X25519. Both are 32 bytes.
+const (
+	PublicKeySize = 32
+	SignatureSize = 64
+)
+
+// Directory limits
+const (
+	DefaultDeviceID     = "default"
+	MaxDeviceIDLength   = 64
+	MaxPrekeysPerUpload = 100
+	MaxStoredPrekeys    = 200
+	PrekeyLowThreshold  = 10 // PREKEYS_LOW is sent below this many
+	FetchLimit          = 60 // Bundle fetches per requester per FetchWindow
+	FetchWindow         = time.Minute
+)
+
+// SignedPrekey is a medium-term prekey signed by the device's identity key
+type SignedPrekey struct {
+	KeyID     uint32    `json:"key_id" bson:"key_id"`
+	PublicKey []byte    `json:"public_key" bson:"public_key"`
+	Signature []byte    `json:"signature" bson:"signature"` // Ed25519 over PublicKey
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// DeviceKeys is the long-lived part of a device's bundle
+type DeviceKeys struct {
+	ID           uuid.UUID    `json:"-" bson:"_id"`
+	UserID       uuid.UUID    `json:"user_id" bson:"user_id"`
+	DeviceID     string       `json:"device_id" bson:"device_id"`
+	IdentityKey  []byte       `json:"identity_key" bson:"identity_key"`
+	SignedPrekey SignedPrekey `json:"signed_prekey" bson:"signed_prekey"`
+	CreatedAt    time.Time    `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at" bson:"updated_at"`
+}
+
+// IdentityKeyRecord is one entry in a device's identity key history. Messages
+// are signed with the identity key, so recipients need old keys to verify old
+// messages. ValidUntil is nil for the device's current key.
+type IdentityKeyRecord struct {
+	ID          uuid.UUID  `json:"-" bson:"_id"`
+	UserID      uuid.UUID  `json:"user_id" bson:"user_id"`
+	DeviceID    string     `json:"device_id" bson:"device_id"`
+	IdentityKey []byte     `json:"identity_key" bson:"identity_key"`
+	ValidFrom   time.Time  `json:"valid_from" bson:"valid_from"`
+	ValidUntil  *time.Time `json:"valid_until,omitempty" bson:"valid_until,omitempty"`
+}
+
+// KeyChangeType says what happened to a device's identity key
+type KeyChangeType string
+
+const (
+	KeyChangeAdded   KeyChangeType = "added"   // New device
+	KeyChangeChanged KeyChangeType = "changed" // Reinstall or reset on an existing device
+	KeyChangeRemoved KeyChangeType = "removed" // Device unregistered
+)
+
+// KeyChange is an entry in a user's append-only key-change log
+type KeyChange struct {
+	ID             uuid.UUID     `json:"id" bson:"_id"`
+	UserID         uuid.UUID     `json:"user_id" bson:"user_id"`
+	DeviceID       string        `json:"device_id" bson:"device_id"`
+	Type           KeyChangeType `json:"type" bson:"type"`
+	OldFingerprint string        `json:"old_fingerprint,omitempty" bson:"old_fingerprint,omitempty"`
+	NewFingerprint string        `json:"new_fingerprint,omitempty" bson:"new_fingerprint,omitempty"`
+	CreatedAt      time.Time     `json:"created_at" bson:"created_at"`
+}
+
+// Verification records that a user compared safety numbers with a contact.
+// It only counts while the contact's fingerprint is unchanged.
+type Verification struct {
+	ID          uuid.UUID `json:"-" bson:"_id"`
+	UserID      uuid.UUID `json:"user_id" bson:"user_id"`
+	ContactID   uuid.UUID `json:"contact_id" bson:"contact_id"`
+	Fingerprint string    `json:"fingerprint" bson:"fingerprint"` // Contact's fingerprint when verified
+	VerifiedAt  time.Time `json:"verified_at" bson:"verified_at"`
+}
+
+// SafetyNumberResponse is returned to clients comparing safety numbers
+type SafetyNumberResponse struct {
+	UserID       uuid.UUID `json:"user_id"`
+	ContactID    uuid.UUID `json:"contact_id"`
+	SafetyNumber string    `json:"safety_number"`
+	Verified     bool      `json:"verified"`
+}
+
+// VerifyContactRequest confirms the safety number the user compared
+type VerifyContactRequest struct {
+	SafetyNumber string `json:"safety_number"`
+}
+
+// OneTimePrekey is handed out to exactly one requester and then deleted
+type OneTimePrekey struct {
+	ID        uuid.UUID `json:"-" bson:"_id"`
+	UserID    uuid.UUID `json:"-" bson:"user_id"`
+	DeviceID  string    `json:"-" bson:"device_id"`
+	KeyID     uint32    `json:"key_id" bson:"key_id"`
+	PublicKey []byte    `json:"public_key" bson:"public_key"`
+	CreatedAt time.Time `json:"-" bson:"created_at"`
+}
+
+// PrekeyBundle is what another user fetches to start a session (X3DH).
+// OneTimePrekey is nil once the device has run out.
+type PrekeyBundle struct {
+	UserID        uuid.UUID      `json:"user_id"`
+	DeviceID      string         `json:"device_id"`
+	IdentityKey   []byte         `json:"identity_key"`
+	SignedPrekey  SignedPrekey   `json:"signed_prekey"`
+	OneTimePrekey *OneTimePrekey `json:"one_time_prekey,omitempty"`
+}
+
+// PrekeyUpload is a one-time prekey in an upload request
+type PrekeyUpload struct {
+	KeyID     uint32 `json:"key_id"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// PublishKeysRequest publishes (or replaces) a device's keys. Byte fields are base64 in JSON.
+// Replacing an existing device's identity key needs ChangeSignature: an
+// Ed25519 signature over IdentityChangePayload by the device's current
+// identity key, or by another of the user's devices named in
+// ChangeSignerDeviceID. Adding a device to a user who already has keys needs
+// the same signature (with an empty old key) from one of their other devices.
+// A device that lost its key is removed and registered again.
+type PublishKeysRequest struct {
+	DeviceID             string         `json:"device_id"`
+	IdentityKey          []byte         `json:"identity_key"`
+	SignedPrekey         SignedPrekey   `json:"signed_prekey"`
+	OneTimePrekeys       []PrekeyUpload `json:"one_time_prekeys"`
+	ChangeSignerDeviceID string         `json:"change_signer_device_id,omitempty"`
+	ChangeSignature      []byte         `json:"change_signature,omitempty"`
+}
+
+// RotateSignedPrekeyRequest replaces a device's signed prekey
+type RotateSignedPrekeyRequest struct {
+	DeviceID     string       `json:"device_id"`
+	SignedPrekey SignedPrekey `json:"signed_prekey"`
+}
+
+// UploadPrekeysRequest adds one-time prekeys to a device
+type UploadPrekeysRequest struct {
+	DeviceID       string         `json:"device_id"`
+	OneTimePrekeys []PrekeyUpload `json:"one_time_prekeys"`
+}
@@ -0,0 +1,246 @@
+package keys
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type KeyRepo interface {
+	UpsertDevice(ctx context.Context, keys *DeviceKeys) error
+	GetDevice(ctx context.Context, userID uuid.UUID, deviceID string) (*DeviceKeys, error)
+	ListDevices(ctx context.Context, userID uuid.UUID) ([]*DeviceKeys, error)
+	UpdateSignedPrekey(ctx context.Context, userID uuid.UUID, deviceID string, prekey SignedPrekey) error
+	AddPrekeys(ctx context.Context, prekeys []*OneTimePrekey) error
+	TakePrekey(ctx context.Context, userID uuid.UUID, deviceID string) (*OneTimePrekey, error)
+	CountPrekeys(ctx context.Context, userID uuid.UUID, deviceID string) (int64, error)
+	DeletePrekeys(ctx context.Context, userID uuid.UUID, deviceID string) error
+	DeleteDevice(ctx context.Context, userID uuid.UUID, deviceID string) error
+	AppendIdentityKey(ctx context.Context, record *IdentityKeyRecord) error
+	CloseIdentityKey(ctx context.Context, userID uuid.UUID, deviceID string, at time.Time) error
+	ListIdentityKeys(ctx context.Context, userID uuid.UUID) ([]*IdentityKeyRecord, error)
+	AppendKeyChange(ctx context.Context, change *KeyChange) error
+	ListKeyChanges(ctx context.Context, userID uuid.UUID) ([]*KeyChange, error)
+	SaveVerification(ctx context.Context, v *Verification) error
+	GetVerification(ctx context.Context, userID, contactID uuid.UUID) (*Verification, error)
+	DeleteVerification(ctx context.Context, userID, contactID uuid.UUID) error
+}
+
+type mongoKeyRepo struct {
+	devices       *mongo.Collection
+	prekeys       *mongo.Collection
+	history       *mongo.Collection
+	changes       *mongo.Collection
+	verifications *mongo.Collection
+}
+
+func NewMongoKeyRepo(db *mongo.Database) KeyRepo {
+	return &mongoKeyRepo{
+		devices:       db.Collection("device_keys"),
+		prekeys:       db.Collection("one_time_prekeys"),
+		history:       db.Collection("identity_key_history"),
+		changes:       db.Collection("key_change_log"),
+		verifications: db.Collection("key_verifications"),
+	}
+}
+
+// UpsertDevice stores a device's identity key and signed prekey, keeping the original ID and creation time
+func (r *mongoKeyRepo) UpsertDevice(ctx context.Context, keys *DeviceKeys) error {
+	now := time.Now()
+	filter := bson.M{"user_id": keys.UserID, "device_id": keys.DeviceID}
+	update := bson.M{
+		"$set": bson.M{
+			"identity_key":  keys.IdentityKey,
+			"signed_prekey": keys.SignedPrekey,
+			"updated_at":    now,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        uuid.New(),
+			"created_at": now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return r.devices.FindOneAndUpdate(ctx, filter, update, opts).Decode(keys)
+}
+
+func (r *mongoKeyRepo) GetDevice(ctx context.Context, userID uuid.UUID, deviceID string) (*DeviceKeys, error) {
+	var keys DeviceKeys
+	err := r.devices.FindOne(ctx, bson.M{"user_id": userID, "device_id": deviceID}).Decode(&keys)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrKeysNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
+
+func (r *mongoKeyRepo) ListDevices(ctx context.Context, userID uuid.UUID) ([]*DeviceKeys, error) {
+	cursor, err := r.devices.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var devices []*DeviceKeys
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (r *mongoKeyRepo) UpdateSignedPrekey(ctx context.Context, userID uuid.UUID, deviceID string, prekey SignedPrekey) error {
+	filter := bson.M{"user_id": userID, "device_id": deviceID}
+	update := bson.M{"$set": bson.M{"signed_prekey": prekey, "updated_at": time.Now()}}
+	res, err := r.devices.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrKeysNotFound
+	}
+	return nil
+}
+
+func (r *mongoKeyRepo) AddPrekeys(ctx context.Context, prekeys []*OneTimePrekey) error {
+	if len(prekeys) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(prekeys))
+	now := time.Now()
+	for _, p := range prekeys {
+		p.ID = uuid.New()
+		p.CreatedAt = now
+		docs = append(docs, p)
+	}
+	_, err := r.prekeys.InsertMany(ctx, docs)
+	return err
+}
+
+// TakePrekey atomically removes and returns the oldest one-time prekey, so
+// no two requesters ever get the same one. Returns nil when none are left.
+func (r *mongoKeyRepo) TakePrekey(ctx context.Context, userID uuid.UUID, deviceID string) (*OneTimePrekey, error) {
+	opts := options.FindOneAndDelete().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "key_id", Value: 1}})
+
+	var prekey OneTimePrekey
+	err := r.prekeys.FindOneAndDelete(ctx, bson.M{"user_id": userID, "device_id": deviceID}, opts).Decode(&prekey)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prekey, nil
+}
+
+func (r *mongoKeyRepo) CountPrekeys(ctx context.Context, userID uuid.UUID, deviceID string) (int64, error) {
+	return r.prekeys.CountDocuments(ctx, bson.M{"user_id": userID, "device_id": deviceID})
+}
+
+func (r *mongoKeyRepo) DeletePrekeys(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	_, err := r.prekeys.DeleteMany(ctx, bson.M{"user_id": userID, "device_id": deviceID})
+	return err
+}
+
+// DeleteDevice removes a device's identity key and signed prekey
+func (r *mongoKeyRepo) DeleteDevice(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	_, err := r.devices.DeleteOne(ctx, bson.M{"user_id": userID, "device_id": deviceID})
+	return err
+}
+
+// AppendIdentityKey closes the device's current history entry and starts a new one
+func (r *mongoKeyRepo) AppendIdentityKey(ctx context.Context, record *IdentityKeyRecord) error {
+	if err := r.CloseIdentityKey(ctx, record.UserID, record.DeviceID, record.ValidFrom); err != nil {
+		return err
+	}
+
+	record.ID = uuid.New()
+	_, err := r.history.InsertOne(ctx, record)
+	return err
+}
+
+// CloseIdentityKey ends the device's current history entry. The entry is kept
+// so signatures made while it was valid can still be checked.
+func (r *mongoKeyRepo) CloseIdentityKey(ctx context.Context, userID uuid.UUID, deviceID string, at time.Time) error {
+	filter := bson.M{
+		"user_id":     userID,
+		"device_id":   deviceID,
+		"valid_until": bson.M{"$exists": false},
+	}
+	_, err := r.history.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"valid_until": at}})
+	return err
+}
+
+func (r *mongoKeyRepo) ListIdentityKeys(ctx context.Context, userID uuid.UUID) ([]*IdentityKeyRecord, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "valid_from", Value: 1}})
+	cursor, err := r.history.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []*IdentityKeyRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// AppendKeyChange adds to the key-change log. Entries are never updated or deleted.
+func (r *mongoKeyRepo) AppendKeyChange(ctx context.Context, change *KeyChange) error {
+	change.ID = uuid.New()
+	change.CreatedAt = time.Now()
+	_, err := r.changes.InsertOne(ctx, change)
+	return err
+}
+
+func (r *mongoKeyRepo) ListKeyChanges(ctx context.Context, userID uuid.UUID) ([]*KeyChange, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.changes.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var changes []*KeyChange
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (r *mongoKeyRepo) SaveVerification(ctx context.Context, v *Verification) error {
+	filter := bson.M{"user_id": v.UserID, "contact_id": v.ContactID}
+	update := bson.M{
+		"$set": bson.M{
+			"fingerprint": v.Fingerprint,
+			"verified_at": v.VerifiedAt,
+		},
+		"$setOnInsert": bson.M{"_id": uuid.New()},
+	}
+	_, err := r.verifications.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetVerification returns nil if the user never verified the contact
+func (r *mongoKeyRepo) GetVerification(ctx context.Context, userID, contactID uuid.UUID) (*Verification, error) {
+	var v Verification
+	err := r.verifications.FindOne(ctx, bson.M{"user_id": userID, "contact_id": contactID}).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *mongoKeyRepo) DeleteVerification(ctx context.Context, userID, contactID uuid.UUID) error {
+	_, err := r.verifications.DeleteOne(ctx, bson.M{"user_id": userID, "contact_id": contactID})
+	return err
+}
@@ -0,0 +1,626 @@
+package keys
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"time"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/channels"
+	"telegraph/internal/ratelimit"
+	"telegraph/internal/users"
+
+	"github.com/google/uuid"
+)
+
+type KeyService interface {
+	PublishKeys(ctx context.Context, userID uuid.UUID, req PublishKeysRequest) (*DeviceKeys, error)
+	RotateSignedPrekey(ctx context.Context, userID uuid.UUID, req RotateSignedPrekeyRequest) error
+	UploadPrekeys(ctx context.Context, userID uuid.UUID, req UploadPrekeysRequest) (int64, error)
+	RemoveDevice(ctx context.Context, userID uuid.UUID, deviceID string) error
+	CountPrekeys(ctx context.Context, userID uuid.UUID, deviceID string) (int64, error)
+	GetBundles(ctx context.Context, requesterID, userID uuid.UUID) ([]*PrekeyBundle, error)
+	GetBundle(ctx context.Context, requesterID, userID uuid.UUID, deviceID string) (*PrekeyBundle, error)
+	GetKeyHistory(ctx context.Context, userID uuid.UUID) ([]*IdentityKeyRecord, error)
+	IdentityKey(ctx context.Context, userID uuid.UUID, deviceID string) ([]byte, error)
+	HasIdentityKeys(ctx context.Context, userID uuid.UUID) (bool, error)
+	GetKeyChanges(ctx context.Context, userID uuid.UUID) ([]*KeyChange, error)
+	GetSafetyNumber(ctx context.Context, userID, contactID uuid.UUID) (*SafetyNumberResponse, error)
+	VerifyContact(ctx context.Context, userID, contactID uuid.UUID, safetyNumber string) error
+	UnverifyContact(ctx context.Context, userID, contactID uuid.UUID) error
+}
+
+// Hub interface for WebSocket notifications
+type Hub interface {
+	SendToUser(userID string, message interface{})
+	SendToUsers(userIDs []string, message interface{})
+	SendToDevice(userID, deviceID string, message interface{})
+}
+
+type keyService struct {
+	repo        KeyRepo
+	userRepo    users.UserRepo
+	channelRepo channels.ChannelRepo
+	hub         Hub
+	audit       *audit.Logger
+	limiter     *ratelimit.Limiter
+}
+
+func NewKeyService(repo KeyRepo, userRepo users.UserRepo, channelRepo channels.ChannelRepo, hub Hub, audit *audit.Logger) KeyService {
+	return &keyService{
+		repo:        repo,
+		userRepo:    userRepo,
+		channelRepo: channelRepo,
+		hub:         hub,
+		audit:       audit,
+		limiter:     ratelimit.NewLimiter(),
+	}
+}
+
+// PublishKeys stores a device's identity key, signed prekey and one-time
+// prekeys. Publishing a different identity key, or adding a device to a user
+// who already has keys, must be endorsed by a key the user already has (see
+// PublishKeysRequest). A changed identity key discards the device's old
+// one-time prekeys.
+func (s *keyService) PublishKeys(ctx context.Context, userID uuid.UUID, req PublishKeysRequest) (*DeviceKeys, error) {
+	deviceID, err := sessionDeviceID(ctx, req.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.IdentityKey) != PublicKeySize {
+		return nil, ErrInvalidKey
+	}
+	if err := verifySignedPrekey(req.IdentityKey, req.SignedPrekey); err != nil {
+		return nil, err
+	}
+	if err := validatePrekeys(req.OneTimePrekeys); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetDevice(ctx, userID, deviceID)
+	if err != nil && err != ErrKeysNotFound {
+		return nil, err
+	}
+	identityChanged := existing != nil && !bytes.Equal(existing.IdentityKey, req.IdentityKey)
+
+	// A new device only changes the safety number if the user already had keys,
+	// and then one of those keys has to vouch for it
+	var hadKeys bool
+	if existing == nil {
+		if hadKeys, err = s.HasIdentityKeys(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	if identityChanged || hadKeys {
+		if err := s.authorizeIdentityChange(ctx, userID, deviceID, existing, req); err != nil {
+			return nil, err
+		}
+	}
+	if identityChanged {
+		if err := s.repo.DeletePrekeys(ctx, userID, deviceID); err != nil {
+			return nil, err
+		}
+	}
+
+	req.SignedPrekey.CreatedAt = time.Now()
+	keys := &DeviceKeys{
+		UserID:       userID,
+		DeviceID:     deviceID,
+		IdentityKey:  req.IdentityKey,
+		SignedPrekey: req.SignedPrekey,
+	}
+	if err := s.repo.UpsertDevice(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	if existing == nil || identityChanged {
+		record := &IdentityKeyRecord{
+			UserID:      userID,
+			DeviceID:    deviceID,
+			IdentityKey: req.IdentityKey,
+			ValidFrom:   keys.UpdatedAt,
+		}
+		if err := s.repo.AppendIdentityKey(ctx, record); err != nil {
+			return nil, err
+		}
+
+		change := &KeyChange{
+			UserID:         userID,
+			DeviceID:       deviceID,
+			Type:           KeyChangeAdded,
+			NewFingerprint: keyFingerprint(req.IdentityKey),
+		}
+		if identityChanged {
+			change.Type = KeyChangeChanged
+			change.OldFingerprint = keyFingerprint(existing.IdentityKey)
+		}
+		if err := s.repo.AppendKeyChange(ctx, change); err != nil {
+			return nil, err
+		}
+		if identityChanged || hadKeys {
+			s.notifyContacts(ctx, change)
+		}
+	}
+
+	if _, err := s.addPrekeys(ctx, userID, deviceID, req.OneTimePrekeys); err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventKeysPublished,
+		Resource: deviceID,
+		Result:   "success",
+		Details:  fmt.Sprintf("Published keys for device %s (identity_changed=%t, prekeys=%d)", deviceID, identityChanged, len(req.OneTimePrekeys)),
+	})
+
+	return keys, nil
+}
+
+func (s *keyService) RotateSignedPrekey(ctx context.Context, userID uuid.UUID, req RotateSignedPrekeyRequest) error {
+	deviceID, err := sessionDeviceID(ctx, req.DeviceID)
+	if err != nil {
+		return err
+	}
+
+	device, err := s.repo.GetDevice(ctx, userID, deviceID)
+	if err != nil {
+		return err
+	}
+	if err := verifySignedPrekey(device.IdentityKey, req.SignedPrekey); err != nil {
+		return err
+	}
+
+	req.SignedPrekey.CreatedAt = time.Now()
+	return s.repo.UpdateSignedPrekey(ctx, userID, deviceID, req.SignedPrekey)
+}
+
+// UploadPrekeys tops up a device's one-time prekeys and returns how many it now has
+func (s *keyService) UploadPrekeys(ctx context.Context, userID uuid.UUID, req UploadPrekeysRequest) (int64, error) {
+	deviceID, err := sessionDeviceID(ctx, req.DeviceID)
+	if err != nil {
+		return 0, err
+	}
+	if err := validatePrekeys(req.OneTimePrekeys); err != nil {
+		return 0, err
+	}
+	if _, err := s.repo.GetDevice(ctx, userID, deviceID); err != nil {
+		return 0, err
+	}
+
+	return s.addPrekeys(ctx, userID, deviceID, req.OneTimePrekeys)
+}
+
+func (s *keyService) addPrekeys(ctx context.Context, userID uuid.UUID, deviceID string, uploads []PrekeyUpload) (int64, error) {
+	count, err := s.repo.CountPrekeys(ctx, userID, deviceID)
+	if err != nil {
+		return 0, err
+	}
+	if count+int64(len(uploads)) > MaxStoredPrekeys {
+		return count, ErrTooManyPrekeys
+	}
+
+	prekeys := make([]*OneTimePrekey, 0, len(uploads))
+	for _, u := range uploads {
+		prekeys = append(prekeys, &OneTimePrekey{
+			UserID:    userID,
+			DeviceID:  deviceID,
+			KeyID:     u.KeyID,
+			PublicKey: u.PublicKey,
+		})
+	}
+	if err := s.repo.AddPrekeys(ctx, prekeys); err != nil {
+		return count, err
+	}
+	return count + int64(len(prekeys)), nil
+}
+
+// RemoveDevice drops a removed device from the directory so senders stop
+// encrypting to it and its signatures are no longer accepted. Its identity
+// key history is closed rather than deleted.
+func (s *keyService) RemoveDevice(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	deviceID, err := normalizeDeviceID(deviceID)
+	if err != nil {
+		return err
+	}
+	existing, err := s.repo.GetDevice(ctx, userID, deviceID)
+	if err == ErrKeysNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeletePrekeys(ctx, userID, deviceID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteDevice(ctx, userID, deviceID); err != nil {
+		return err
+	}
+	if err := s.repo.CloseIdentityKey(ctx, userID, deviceID, time.Now()); err != nil {
+		return err
+	}
+
+	change := &KeyChange{
+		UserID:         userID,
+		DeviceID:       deviceID,
+		Type:           KeyChangeRemoved,
+		OldFingerprint: keyFingerprint(existing.IdentityKey),
+	}
+	if err := s.repo.AppendKeyChange(ctx, change); err != nil {
+		return err
+	}
+	s.notifyContacts(ctx, change)
+	return nil
+}
+
+func (s *keyService) CountPrekeys(ctx context.Context, userID uuid.UUID, deviceID string) (int64, error) {
+	deviceID, err := normalizeDeviceID(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return s.repo.CountPrekeys(ctx, userID, deviceID)
+}
+
+// GetBundles returns a bundle for every device of a user, consuming one
+// one-time prekey per device
+func (s *keyService) GetBundles(ctx context.Context, requesterID, userID uuid.UUID) ([]*PrekeyBundle, error) {
+	if err := s.checkFetch(ctx, requesterID, userID); err != nil {
+		return nil, err
+	}
+
+	devices, err := s.repo.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, ErrKeysNotFound
+	}
+
+	bundles := make([]*PrekeyBundle, 0, len(devices))
+	for _, device := range devices {
+		bundle, err := s.bundle(ctx, device)
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+func (s *keyService) GetBundle(ctx context.Context, requesterID, userID uuid.UUID, deviceID string) (*PrekeyBundle, error) {
+	if err := s.checkFetch(ctx, requesterID, userID); err != nil {
+		return nil, err
+	}
+
+	deviceID, err := normalizeDeviceID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	device, err := s.repo.GetDevice(ctx, userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.bundle(ctx, device)
+}
+
+// GetKeyHistory lists every identity key a user's devices have had, so
+// recipients can verify message signatures made with since-replaced keys
+func (s *keyService) GetKeyHistory(ctx context.Context, userID uuid.UUID) ([]*IdentityKeyRecord, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListIdentityKeys(ctx, userID)
+}
+
+// IdentityKey returns a device's current identity key, or nil if the device
+// has not published keys
+func (s *keyService) IdentityKey(ctx context.Context, userID uuid.UUID, deviceID string) ([]byte, error) {
+	device, err := s.repo.GetDevice(ctx, userID, deviceID)
+	if err == ErrKeysNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return device.IdentityKey, nil
+}
+
+// HasIdentityKeys reports whether any of the user's devices published keys
+func (s *keyService) HasIdentityKeys(ctx context.Context, userID uuid.UUID) (bool, error) {
+	devices, err := s.repo.ListDevices(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(devices) > 0, nil
+}
+
+// GetKeyChanges returns a user's key-change log, oldest first
+func (s *keyService) GetKeyChanges(ctx context.Context, userID uuid.UUID) ([]*KeyChange, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListKeyChanges(ctx, userID)
+}
+
+// GetSafetyNumber computes the safety number between the caller and a contact
+// and whether the caller verified the contact's current keys
+func (s *keyService) GetSafetyNumber(ctx context.Context, userID, contactID uuid.UUID) (*SafetyNumberResponse, error) {
+	if userID == contactID {
+		return nil, ErrVerifySelf
+	}
+	number, contactFingerprint, err := s.safetyNumber(ctx, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
+
+	verification, err := s.repo.GetVerification(ctx, userID, contactID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SafetyNumberResponse{
+		UserID:       userID,
+		ContactID:    contactID,
+		SafetyNumber: number,
+		Verified:     verification != nil && verification.Fingerprint == contactFingerprint,
+	}, nil
+}
+
+// VerifyContact marks the contact's current keys as verified once the caller
+// confirms the safety number they compared
+func (s *keyService) VerifyContact(ctx context.Context, userID, contactID uuid.UUID, safetyNumber string) error {
+	if userID == contactID {
+		return ErrVerifySelf
+	}
+	number, contactFingerprint, err := s.safetyNumber(ctx, userID, contactID)
+	if err != nil {
+		return err
+	}
+	if safetyNumber != number {
+		return ErrSafetyNumberMismatch
+	}
+
+	verification := &Verification{
+		UserID:      userID,
+		ContactID:   contactID,
+		Fingerprint: contactFingerprint,
+		VerifiedAt:  time.Now(),
+	}
+	if err := s.repo.SaveVerification(ctx, verification); err != nil {
+		return err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventContactVerified,
+		Resource: contactID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Verified safety number with user %s", contactID),
+	})
+	return nil
+}
+
+func (s *keyService) UnverifyContact(ctx context.Context, userID, contactID uuid.UUID) error {
+	return s.repo.DeleteVerification(ctx, userID, contactID)
+}
+
+// safetyNumber returns the pair's safety number and the contact's fingerprint
+func (s *keyService) safetyNumber(ctx context.Context, userID, contactID uuid.UUID) (string, string, error) {
+	userKeys, err := s.identityKeys(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	contactKeys, err := s.identityKeys(ctx, contactID)
+	if err != nil {
+		return "", "", err
+	}
+	return SafetyNumber(userID, userKeys, contactID, contactKeys), Fingerprint(contactID, contactKeys), nil
+}
+
+func (s *keyService) identityKeys(ctx context.Context, userID uuid.UUID) ([][]byte, error) {
+	devices, err := s.repo.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, ErrKeysNotFound
+	}
+	keys := make([][]byte, 0, len(devices))
+	for _, d := range devices {
+		keys = append(keys, d.IdentityKey)
+	}
+	return keys, nil
+}
+
+// notifyContacts tells everyone who shares a channel with the user that their
+// safety number changed
+func (s *keyService) notifyContacts(ctx context.Context, change *KeyChange) {
+	if s.hub == nil {
+		return
+	}
+	userChannels, err := s.channelRepo.GetUserChannels(ctx, change.UserID)
+	if err != nil {
+		return
+	}
+
+	seen := map[string]bool{change.UserID.String(): true}
+	var contacts []string
+	for _, c := range userChannels {
+		for _, id := range c.MemberIDs() {
+			if !seen[id] {
+				seen[id] = true
+				contacts = append(contacts, id)
+			}
+		}
+	}
+	if len(contacts) == 0 {
+		return
+	}
+
+	s.hub.SendToUsers(contacts, map[string]interface{}{
+		"type":            "IDENTITY_KEY_CHANGED",
+		"user_id":         change.UserID.String(),
+		"device_id":       change.DeviceID,
+		"change":          change.Type,
+		"new_fingerprint": change.NewFingerprint,
+	})
+}
+
+// checkFetch rate-limits bundle requests so nobody can drain another user's
+// one-time prekeys, and makes sure the target account exists
+func (s *keyService) checkFetch(ctx context.Context, requesterID, userID uuid.UUID) error {
+	if ok, _ := s.limiter.Allow("fetch:"+requesterID.String(), FetchLimit, FetchWindow); !ok {
+		return ErrFetchRateLimited
+	}
+	_, err := s.userRepo.GetByID(ctx, userID)
+	return err
+}
+
+func (s *keyService) bundle(ctx context.Context, device *DeviceKeys) (*PrekeyBundle, error) {
+	prekey, err := s.repo.TakePrekey(ctx, device.UserID, device.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Also when none were left: the device has to upload more either way
+	s.notifyIfLow(ctx, device)
+
+	return &PrekeyBundle{
+		UserID:        device.UserID,
+		DeviceID:      device.DeviceID,
+		IdentityKey:   device.IdentityKey,
+		SignedPrekey:  device.SignedPrekey,
+		OneTimePrekey: prekey,
+	}, nil
+}
+
+// notifyIfLow tells the device to upload more prekeys once it runs low
+func (s *keyService) notifyIfLow(ctx context.Context, device *DeviceKeys) {
+	if s.hub == nil {
+		return
+	}
+	remaining, err := s.repo.CountPrekeys(ctx, device.UserID, device.DeviceID)
+	if err != nil || remaining >= PrekeyLowThreshold {
+		return
+	}
+	notice := map[string]interface{}{
+		"type":      "PREKEYS_LOW",
+		"device_id": device.DeviceID,
+		"remaining": remaining,
+	}
+	// Keys published without a device ID aren't tied to a registered device
+	if device.DeviceID == DefaultDeviceID {
+		s.hub.SendToUser(device.UserID.String(), notice)
+		return
+	}
+	s.hub.SendToDevice(device.UserID.String(), device.DeviceID, notice)
+}
+
+// authorizeIdentityChange makes sure a new identity key for an existing device
+// was signed by its current key or by another of the user's devices, so a
+// session token alone can't swap in a key that signs messages
+func (s *keyService) authorizeIdentityChange(ctx context.Context, userID uuid.UUID, deviceID string, existing *DeviceKeys, req PublishKeysRequest) error {
+	// A replaced key can sign its own successor; a new device needs another one
+	var oldKey, signerKey []byte
+	if existing != nil {
+		oldKey = existing.IdentityKey
+		signerKey = existing.IdentityKey
+	}
+	signerID, err := normalizeDeviceID(req.ChangeSignerDeviceID)
+	if err != nil {
+		return err
+	}
+	if req.ChangeSignerDeviceID != "" && signerID != deviceID {
+		signer, err := s.repo.GetDevice(ctx, userID, signerID)
+		if err != nil && err != ErrKeysNotFound {
+			return err
+		}
+		signerKey = nil
+		if signer != nil {
+			signerKey = signer.IdentityKey
+		}
+	}
+
+	payload := IdentityChangePayload(userID, deviceID, oldKey, req.IdentityKey)
+	if err := verifyIdentityChange(signerKey, payload, req.ChangeSignature); err != nil {
+		s.audit.Log(ctx, audit.AuditLog{
+			UserID:   &userID,
+			Action:   audit.EventKeysPublished,
+			Resource: deviceID,
+			Result:   "failure",
+			Details:  fmt.Sprintf("Refused unsigned identity key for device %s", deviceID),
+		})
+		return err
+	}
+	return nil
+}
+
+// IdentityChangePayload is the message a device signs to approve replacing
+// oldKey with newKey on the given device
+func IdentityChangePayload(userID uuid.UUID, deviceID string, oldKey, newKey []byte) []byte {
+	return []byte(fmt.Sprintf("telegraph-identity-change:%s:%s:%x:%x", userID, deviceID, oldKey, newKey))
+}
+
+func verifyIdentityChange(signerKey, payload, signature []byte) error {
+	if len(signerKey) != PublicKeySize || len(signature) != SignatureSize ||
+		!ed25519.Verify(ed25519.PublicKey(signerKey), payload, signature) {
+		return ErrIdentityChangeDenied
+	}
+	return nil
+}
+
+// verifySignedPrekey checks the prekey is well formed and signed by the identity key
+func verifySignedPrekey(identityKey []byte, prekey SignedPrekey) error {
+	if len(identityKey) != PublicKeySize || len(prekey.PublicKey) != PublicKeySize {
+		return ErrInvalidKey
+	}
+	if len(prekey.Signature) != SignatureSize ||
+		!ed25519.Verify(ed25519.PublicKey(identityKey), prekey.PublicKey, prekey.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func validatePrekeys(prekeys []PrekeyUpload) error {
+	if len(prekeys) > MaxPrekeysPerUpload {
+		return ErrTooManyPrekeys
+	}
+	seen := make(map[uint32]bool, len(prekeys))
+	for _, p := range prekeys {
+		if len(p.PublicKey) != PublicKeySize {
+			return ErrInvalidKey
+		}
+		if seen[p.KeyID] {
+			return ErrDuplicatePrekey
+		}
+		seen[p.KeyID] = true
+	}
+	return nil
+}
+
+// sessionDeviceID normalizes the device a client is managing keys for, which
+// has to be the device its session was issued to
+func sessionDeviceID(ctx context.Context, deviceID string) (string, error) {
+	deviceID, err := normalizeDeviceID(deviceID)
+	if err != nil {
+		return "", err
+	}
+	session, _ := normalizeDeviceID(users.DeviceIDFromContext(ctx))
+	if deviceID != session {
+		return "", ErrNotSessionDevice
+	}
+	return deviceID, nil
+}
+
+func normalizeDeviceID(deviceID string) (string, error) {
+	if deviceID == "" {
+		return DefaultDeviceID, nil
+	}
+	if len(deviceID) > MaxDeviceIDLength {
+		return "", ErrInvalidDeviceID
+	}
+	return deviceID, nil
+}
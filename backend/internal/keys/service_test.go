@@ -0,0 +1,98 @@
+package keys
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/users"
+)
+
+func TestVerifyIdentityChange(t *testing.T) {
+	oldPub, oldPriv, _ := ed25519.GenerateKey(rand.Reader)
+	newPub, newPriv, _ := ed25519.GenerateKey(rand.Reader)
+	userID := uuid.New()
+
+	payload := IdentityChangePayload(userID, "phone", oldPub, newPub)
+
+	if err := verifyIdentityChange(oldPub, payload, ed25519.Sign(oldPriv, payload)); err != nil {
+		t.Errorf("change signed by the current key: %v", err)
+	}
+	// A stolen session can only sign with the key it is trying to install
+	if err := verifyIdentityChange(oldPub, payload, ed25519.Sign(newPriv, payload)); err != ErrIdentityChangeDenied {
+		t.Errorf("self-signed change: err = %v, want %v", err, ErrIdentityChangeDenied)
+	}
+	if err := verifyIdentityChange(oldPub, payload, nil); err != ErrIdentityChangeDenied {
+		t.Errorf("unsigned change: err = %v, want %v", err, ErrIdentityChangeDenied)
+	}
+
+	other := IdentityChangePayload(userID, "laptop", oldPub, newPub)
+	if err := verifyIdentityChange(oldPub, other, ed25519.Sign(oldPriv, payload)); err != ErrIdentityChangeDenied {
+		t.Errorf("signature replayed for another device: err = %v, want %v", err, ErrIdentityChangeDenied)
+	}
+}
+
+// exhaustedKeyRepo serves one device that has no one-time prekeys left
+type exhaustedKeyRepo struct {
+	KeyRepo
+	device *DeviceKeys
+}
+
+func (r *exhaustedKeyRepo) GetDevice(ctx context.Context, userID uuid.UUID, deviceID string) (*DeviceKeys, error) {
+	if userID != r.device.UserID || deviceID != r.device.DeviceID {
+		return nil, ErrKeysNotFound
+	}
+	return r.device, nil
+}
+
+func (r *exhaustedKeyRepo) TakePrekey(ctx context.Context, userID uuid.UUID, deviceID string) (*OneTimePrekey, error) {
+	return nil, nil
+}
+
+func (r *exhaustedKeyRepo) CountPrekeys(ctx context.Context, userID uuid.UUID, deviceID string) (int64, error) {
+	return 0, nil
+}
+
+type fetchUsers struct {
+	users.UserRepo
+}
+
+func (fetchUsers) GetByID(ctx context.Context, id uuid.UUID) (*users.User, error) {
+	return &users.User{ID: id}, nil
+}
+
+type noticeHub struct {
+	notices []map[string]interface{}
+}
+
+func (h *noticeHub) SendToUser(userID string, message interface{}) {
+	h.notices = append(h.notices, message.(map[string]interface{}))
+}
+
+func (h *noticeHub) SendToUsers(userIDs []string, message interface{}) {}
+
+func (h *noticeHub) SendToDevice(userID, deviceID string, message interface{}) {
+	h.notices = append(h.notices, message.(map[string]interface{}))
+}
+
+func TestGetBundle_ExhaustedPrekeysNotifyDevice(t *testing.T) {
+	device := &DeviceKeys{UserID: uuid.New(), DeviceID: DefaultDeviceID}
+	hub := &noticeHub{}
+	svc := NewKeyService(&exhaustedKeyRepo{device: device}, fetchUsers{}, nil, hub, &audit.Logger{})
+
+	// An empty device ID means the default device, as when publishing
+	bundle, err := svc.GetBundle(context.Background(), uuid.New(), device.UserID, "")
+	if err != nil {
+		t.Fatalf("GetBundle: %v", err)
+	}
+	if bundle.OneTimePrekey != nil {
+		t.Fatal("expected a bundle without a one-time prekey")
+	}
+	if len(hub.notices) != 1 || hub.notices[0]["type"] != "PREKEYS_LOW" {
+		t.Fatalf("notices %v, want one PREKEYS_LOW", hub.notices)
+	}
+}
@@ -0,0 +1,11 @@
+package legalhold
+
+import "errors"
+
+var (
+	ErrHoldNotFound      = errors.New("legal hold not found")
+	ErrAlreadyHeld       = errors.New("target is already under legal hold")
+	ErrHoldReleased      = errors.New("legal hold already released")
+	ErrInvalidTargetType = errors.New("invalid legal hold target type")
+	ErrReasonRequired    = errors.New("a reason is required to place a legal hold")
+)
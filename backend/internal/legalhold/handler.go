@@ -0,0 +1,115 @@
+package legalhold
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"telegraph/internal/channels"
+	"telegraph/internal/middleware"
+	"telegraph/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service HoldService
+}
+
+func NewHandler(service HoldService) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListHolds)
+	r.Post("/", h.PlaceHold)
+	r.Post("/{id}/release", h.ReleaseHold)
+
+	return r
+}
+
+func (h *Handler) ListHolds(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("active") != "false"
+
+	holds, err := h.service.ListHolds(r.Context(), activeOnly)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, holds, http.StatusOK)
+}
+
+func (h *Handler) PlaceHold(w http.ResponseWriter, r *http.Request) {
+	var req PlaceHoldRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hold, err := h.service.PlaceHold(r.Context(), req, user.ID)
+	if err != nil {
+		switch err {
+		case users.ErrUserNotFound, channels.ErrChannelNotFound:
+			respondError(w, "target_not_found", http.StatusNotFound)
+		case ErrAlreadyHeld:
+			respondError(w, err.Error(), http.StatusConflict)
+		case ErrInvalidTargetType, ErrReasonRequired:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, hold, http.StatusCreated)
+}
+
+func (h *Handler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	holdID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_hold_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.ReleaseHold(r.Context(), holdID, user.ID); err != nil {
+		switch err {
+		case ErrHoldNotFound:
+			respondError(w, "hold_not_found", http.StatusNotFound)
+		case ErrHoldReleased:
+			respondError(w, err.Error(), http.StatusConflict)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "hold_released"}, http.StatusOK)
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,35 @@
+package legalhold
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TargetType identifies what kind of resource a hold applies to
+type TargetType string
+
+const (
+	TargetUser    TargetType = "user"
+	TargetChannel TargetType = "channel"
+)
+
+// Hold preserves all data belonging to a user or channel until released
+type Hold struct {
+	ID         uuid.UUID  `json:"id" bson:"_id"`
+	TargetType TargetType `json:"target_type" bson:"target_type"`
+	TargetID   uuid.UUID  `json:"target_id" bson:"target_id"`
+	Reason     string     `json:"reason" bson:"reason"`
+	Active     bool       `json:"active" bson:"active"`
+	PlacedBy   uuid.UUID  `json:"placed_by" bson:"placed_by"`
+	PlacedAt   time.Time  `json:"placed_at" bson:"placed_at"`
+	ReleasedBy *uuid.UUID `json:"released_by,omitempty" bson:"released_by,omitempty"`
+	ReleasedAt *time.Time `json:"released_at,omitempty" bson:"released_at,omitempty"`
+}
+
+// PlaceHoldRequest is the payload for placing a new hold
+type PlaceHoldRequest struct {
+	TargetType TargetType `json:"target_type"`
+	TargetID   uuid.UUID  `json:"target_id"`
+	Reason     string     `json:"reason"`
+}
@@ -0,0 +1,118 @@
+package legalhold
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type HoldRepo interface {
+	Create(ctx context.Context, h *Hold) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Hold, error)
+	GetActive(ctx context.Context, targetType TargetType, targetID uuid.UUID) (*Hold, error)
+	List(ctx context.Context, activeOnly bool) ([]*Hold, error)
+	Release(ctx context.Context, id, releasedBy uuid.UUID) error
+}
+
+type mongoHoldRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoHoldRepo(db *mongo.Database) HoldRepo {
+	return &mongoHoldRepo{
+		collection: db.Collection("legal_holds"),
+	}
+}
+
+// EnsureIndexes allows at most one active hold per target, so two admins
+// placing the same hold at once can't both succeed
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("legal_holds").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}},
+		Options: options.Index().
+			SetName("one_active_hold_per_target").
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"active": true}),
+	})
+	return err
+}
+
+func (r *mongoHoldRepo) Create(ctx context.Context, h *Hold) error {
+	h.ID = uuid.New()
+	h.PlacedAt = time.Now()
+	h.Active = true
+
+	_, err := r.collection.InsertOne(ctx, h)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyHeld
+	}
+	return err
+}
+
+func (r *mongoHoldRepo) GetByID(ctx context.Context, id uuid.UUID) (*Hold, error) {
+	var hold Hold
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&hold)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *mongoHoldRepo) GetActive(ctx context.Context, targetType TargetType, targetID uuid.UUID) (*Hold, error) {
+	var hold Hold
+	err := r.collection.FindOne(ctx, bson.M{
+		"target_type": targetType,
+		"target_id":   targetID,
+		"active":      true,
+	}).Decode(&hold)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *mongoHoldRepo) List(ctx context.Context, activeOnly bool) ([]*Hold, error) {
+	filter := bson.M{}
+	if activeOnly {
+		filter["active"] = true
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "placed_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var holds []*Hold
+	if err := cursor.All(ctx, &holds); err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+func (r *mongoHoldRepo) Release(ctx context.Context, id, releasedBy uuid.UUID) error {
+	update := bson.M{"$set": bson.M{
+		"active":      false,
+		"released_by": releasedBy,
+		"released_at": time.Now(),
+	}}
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "active": true}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrHoldReleased
+	}
+	return nil
+}
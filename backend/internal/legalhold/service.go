@@ -0,0 +1,160 @@
+package legalhold
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/channels"
+	"telegraph/internal/users"
+
+	"github.com/google/uuid"
+)
+
+type HoldService interface {
+	PlaceHold(ctx context.Context, req PlaceHoldRequest, adminID uuid.UUID) (*Hold, error)
+	ReleaseHold(ctx context.Context, holdID, adminID uuid.UUID) error
+	ListHolds(ctx context.Context, activeOnly bool) ([]*Hold, error)
+	IsUserHeld(ctx context.Context, userID uuid.UUID) (bool, error)
+	IsChannelHeld(ctx context.Context, channelID uuid.UUID) (bool, error)
+}
+
+type holdService struct {
+	repo        HoldRepo
+	userRepo    users.UserRepo
+	channelRepo channels.ChannelRepo
+	audit       *audit.Logger
+}
+
+func NewHoldService(repo HoldRepo, userRepo users.UserRepo, channelRepo channels.ChannelRepo, audit *audit.Logger) HoldService {
+	return &holdService{repo: repo, userRepo: userRepo, channelRepo: channelRepo, audit: audit}
+}
+
+func (s *holdService) PlaceHold(ctx context.Context, req PlaceHoldRequest, adminID uuid.UUID) (*Hold, error) {
+	if req.Reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	// Make sure the target exists before preserving anything for it
+	switch req.TargetType {
+	case TargetUser:
+		if _, err := s.userRepo.GetByID(ctx, req.TargetID); err != nil {
+			return nil, err
+		}
+	case TargetChannel:
+		if _, err := s.channelRepo.GetByID(ctx, req.TargetID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidTargetType
+	}
+
+	held, err := s.isHeld(ctx, req.TargetType, req.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if held {
+		return nil, ErrAlreadyHeld
+	}
+
+	hold := &Hold{
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Reason:     req.Reason,
+		PlacedBy:   adminID,
+	}
+	if err := s.repo.Create(ctx, hold); err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &adminID,
+		Action:   audit.EventLegalHoldPlaced,
+		Resource: hold.TargetID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Placed legal hold %s on %s: %s", hold.ID, hold.TargetType, hold.Reason),
+	})
+
+	return hold, nil
+}
+
+func (s *holdService) ReleaseHold(ctx context.Context, holdID, adminID uuid.UUID) error {
+	hold, err := s.repo.GetByID(ctx, holdID)
+	if err != nil {
+		return err
+	}
+	if !hold.Active {
+		// Releasing again retries a purge that failed the first time
+		s.purgeDeleted(ctx, hold, adminID)
+		return ErrHoldReleased
+	}
+
+	if err := s.repo.Release(ctx, holdID, adminID); err != nil {
+		return err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &adminID,
+		Action:   audit.EventLegalHoldReleased,
+		Resource: hold.TargetID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Released legal hold %s on %s", hold.ID, hold.TargetType),
+	})
+
+	// The release stands even if the purge fails; it is logged and retried
+	// the next time someone releases the hold
+	s.purgeDeleted(ctx, hold, adminID)
+	return nil
+}
+
+// purgeDeleted finishes deletions the released hold was holding back: a user
+// or channel deleted while held is removed once no hold covers it anymore.
+// Purging is idempotent, so failures are only logged.
+func (s *holdService) purgeDeleted(ctx context.Context, hold *Hold, adminID uuid.UUID) {
+	held, err := s.isHeld(ctx, hold.TargetType, hold.TargetID)
+	if err == nil && held {
+		return
+	}
+	if err == nil {
+		switch hold.TargetType {
+		case TargetUser:
+			err = s.userRepo.PurgeDeleted(ctx, hold.TargetID)
+		case TargetChannel:
+			err = s.channelRepo.PurgeDeleted(ctx, hold.TargetID)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to purge %s %s after releasing legal hold %s: %v", hold.TargetType, hold.TargetID, hold.ID, err)
+		s.audit.Log(ctx, audit.AuditLog{
+			UserID:   &adminID,
+			Action:   audit.EventLegalHoldReleased,
+			Resource: hold.TargetID.String(),
+			Result:   "failure",
+			Details:  fmt.Sprintf("Purge after releasing legal hold %s failed; release it again to retry", hold.ID),
+		})
+	}
+}
+
+func (s *holdService) ListHolds(ctx context.Context, activeOnly bool) ([]*Hold, error) {
+	return s.repo.List(ctx, activeOnly)
+}
+
+func (s *holdService) IsUserHeld(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return s.isHeld(ctx, TargetUser, userID)
+}
+
+func (s *holdService) IsChannelHeld(ctx context.Context, channelID uuid.UUID) (bool, error) {
+	return s.isHeld(ctx, TargetChannel, channelID)
+}
+
+func (s *holdService) isHeld(ctx context.Context, targetType TargetType, targetID uuid.UUID) (bool, error) {
+	_, err := s.repo.GetActive(ctx, targetType, targetID)
+	if err == ErrHoldNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,184 @@
+package messages
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Envelope versions and algorithms accepted in encryption_meta
+const (
+	EnvelopeVersion1 = 1
+
+	AlgorithmAES256GCM = "AES-256-GCM" // See EncryptAESGCM
+
+	GCMNonceSize      = 12
+	Ed25519SigSize    = 64
+	MaxDeviceIDLength = 64 // Same limit as the key directory
+)
+
+// Envelope codes returned to clients when encryption_meta is rejected
+const (
+	EnvelopeMissing            = "envelope_missing"
+	EnvelopeUnknownField       = "envelope_unknown_field"
+	EnvelopeUnsupportedVersion = "envelope_unsupported_version"
+	EnvelopeUnsupportedAlg     = "envelope_unsupported_algorithm"
+	EnvelopeInvalidIV          = "envelope_invalid_iv"
+	EnvelopeInvalidKeyEpoch    = "envelope_invalid_key_epoch"
+	EnvelopeInvalidDeviceID    = "envelope_invalid_device_id"
+	EnvelopeInvalidSignature   = "envelope_invalid_signature"
+	EnvelopeNonceReused        = "envelope_nonce_reused"
+)
+
+// Envelope is the validated form of a message's encryption_meta:
+//
+//	{"version": 1, "algorithm": "AES-256-GCM", "iv": "<base64, 12 bytes>",
+//	 "key_epoch": 0, "device_id": "phone", "signature": "<base64, optional>"}
+type Envelope struct {
+	Version   int
+	Algorithm string
+	IV        []byte
+	KeyEpoch  int64
+	DeviceID  string
+	Signature []byte
+}
+
+// EnvelopeError explains why encryption_meta was rejected
+type EnvelopeError struct {
+	Code  string
+	Field string
+}
+
+func (e *EnvelopeError) Error() string {
+	if e.Field == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Field)
+}
+
+func envelopeError(code, field string) *EnvelopeError {
+	return &EnvelopeError{Code: code, Field: field}
+}
+
+var envelopeFields = map[string]bool{
+	"version":   true,
+	"algorithm": true,
+	"iv":        true,
+	"key_epoch": true,
+	"device_id": true,
+	"signature": true,
+}
+
+// ParseEnvelope validates encryption_meta against the envelope schema.
+// Unknown fields are rejected so a buggy client can't smuggle in data that
+// other clients would later choke on.
+func ParseEnvelope(meta map[string]interface{}) (*Envelope, error) {
+	if len(meta) == 0 {
+		return nil, envelopeError(EnvelopeMissing, "")
+	}
+	for field := range meta {
+		if !envelopeFields[field] {
+			return nil, envelopeError(EnvelopeUnknownField, field)
+		}
+	}
+
+	env := &Envelope{}
+
+	version, ok := metaInt(meta["version"])
+	if !ok || version != EnvelopeVersion1 {
+		return nil, envelopeError(EnvelopeUnsupportedVersion, "version")
+	}
+	env.Version = int(version)
+
+	algorithm, _ := meta["algorithm"].(string)
+	if algorithm != AlgorithmAES256GCM {
+		return nil, envelopeError(EnvelopeUnsupportedAlg, "algorithm")
+	}
+	env.Algorithm = algorithm
+
+	iv, err := metaBase64(meta["iv"])
+	if err != nil || len(iv) != GCMNonceSize {
+		return nil, envelopeError(EnvelopeInvalidIV, "iv")
+	}
+	env.IV = iv
+
+	epoch, ok := metaInt(meta["key_epoch"])
+	if !ok || epoch < 0 {
+		return nil, envelopeError(EnvelopeInvalidKeyEpoch, "key_epoch")
+	}
+	env.KeyEpoch = epoch
+
+	deviceID, _ := meta["device_id"].(string)
+	if strings.TrimSpace(deviceID) == "" || len(deviceID) > MaxDeviceIDLength {
+		return nil, envelopeError(EnvelopeInvalidDeviceID, "device_id")
+	}
+	env.DeviceID = deviceID
+
+	if raw, present := meta["signature"]; present {
+		sig, err := metaBase64(raw)
+		if err != nil || len(sig) != Ed25519SigSize {
+			return nil, envelopeError(EnvelopeInvalidSignature, "signature")
+		}
+		env.Signature = sig
+	}
+
+	return env, nil
+}
+
+// NonceKey identifies the IV for nonce-reuse checks within a key epoch
+func (e *Envelope) NonceKey() string {
+	return base64.StdEncoding.EncodeToString(e.IV)
+}
+
+// SigningPayload is what a sender signs with their device identity key: the
+// channel, every envelope field except the signature, and the ciphertext.
+// Variable-length fields are length-prefixed so they can't be shifted into
+// one another.
+func (e *Envelope) SigningPayload(channelID uuid.UUID, content []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("telegraph-message-v1")
+	buf.Write(channelID[:])
+	binary.Write(&buf, binary.BigEndian, int64(e.Version))
+	writeField(&buf, []byte(e.Algorithm))
+	writeField(&buf, e.IV)
+	binary.Write(&buf, binary.BigEndian, e.KeyEpoch)
+	writeField(&buf, []byte(e.DeviceID))
+	writeField(&buf, content)
+	return buf.Bytes()
+}
+
+func writeField(buf *bytes.Buffer, field []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(field)))
+	buf.Write(field)
+}
+
+// metaInt reads an integer field, which JSON decodes as a float64
+func metaInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case float64:
+		if n != math.Trunc(n) || math.IsInf(n, 0) {
+			return 0, false
+		}
+		return int64(n), true
+	case int64:
+		return n, true
+	case int32:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func metaBase64(v interface{}) ([]byte, error) {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return nil, fmt.Errorf("not a base64 string")
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
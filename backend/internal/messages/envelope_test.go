@@ -0,0 +1,99 @@
+package messages
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func validMeta() map[string]interface{} {
+	return map[string]interface{}{
+		"version":   float64(1),
+		"algorithm": AlgorithmAES256GCM,
+		"iv":        base64.StdEncoding.EncodeToString(make([]byte, GCMNonceSize)),
+		"key_epoch": float64(3),
+		"device_id": "phone",
+	}
+}
+
+func TestParseEnvelope_Valid(t *testing.T) {
+	meta := validMeta()
+	meta["signature"] = base64.StdEncoding.EncodeToString(make([]byte, Ed25519SigSize))
+
+	env, err := ParseEnvelope(meta)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if env.KeyEpoch != 3 || env.DeviceID != "phone" || len(env.IV) != GCMNonceSize || len(env.Signature) != Ed25519SigSize {
+		t.Errorf("unexpected envelope: %+v", env)
+	}
+}
+
+func TestParseEnvelope_Rejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(map[string]interface{})
+		code   string
+	}{
+		{"empty", func(m map[string]interface{}) {
+			for k := range m {
+				delete(m, k)
+			}
+		}, EnvelopeMissing},
+		{"unknown field", func(m map[string]interface{}) { m["extra"] = "x" }, EnvelopeUnknownField},
+		{"version", func(m map[string]interface{}) { m["version"] = float64(2) }, EnvelopeUnsupportedVersion},
+		{"algorithm", func(m map[string]interface{}) { m["algorithm"] = "AES-128-CBC" }, EnvelopeUnsupportedAlg},
+		{"iv length", func(m map[string]interface{}) {
+			m["iv"] = base64.StdEncoding.EncodeToString(make([]byte, 16))
+		}, EnvelopeInvalidIV},
+		{"iv encoding", func(m map[string]interface{}) { m["iv"] = "not base64!" }, EnvelopeInvalidIV},
+		{"fractional epoch", func(m map[string]interface{}) { m["key_epoch"] = 1.5 }, EnvelopeInvalidKeyEpoch},
+		{"negative epoch", func(m map[string]interface{}) { m["key_epoch"] = float64(-1) }, EnvelopeInvalidKeyEpoch},
+		{"missing device", func(m map[string]interface{}) { delete(m, "device_id") }, EnvelopeInvalidDeviceID},
+		{"short signature", func(m map[string]interface{}) {
+			m["signature"] = base64.StdEncoding.EncodeToString(make([]byte, 10))
+		}, EnvelopeInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := validMeta()
+			tt.mutate(meta)
+
+			_, err := ParseEnvelope(meta)
+			var envErr *EnvelopeError
+			if !errors.As(err, &envErr) {
+				t.Fatalf("expected EnvelopeError, got %v", err)
+			}
+			if envErr.Code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, envErr.Code)
+			}
+		})
+	}
+}
+
+func TestEnvelope_SigningPayload(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	env, err := ParseEnvelope(validMeta())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	channelID := uuid.New()
+	content := []byte("ciphertext")
+	sig := ed25519.Sign(priv, env.SigningPayload(channelID, content))
+
+	if !ed25519.Verify(pub, env.SigningPayload(channelID, content), sig) {
+		t.Error("expected signature to verify")
+	}
+	if ed25519.Verify(pub, env.SigningPayload(uuid.New(), content), sig) {
+		t.Error("signature must not verify for another channel")
+	}
+	env.KeyEpoch++
+	if ed25519.Verify(pub, env.SigningPayload(channelID, content), sig) {
+		t.Error("signature must cover the envelope")
+	}
+}
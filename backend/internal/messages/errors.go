@@ -1,6 +1,11 @@
 package messages
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
 
 var (
 	ErrMessageNotFound     = errors.New("message not found")
@@ -9,4 +14,33 @@ var (
 	ErrContentTooLarge     = errors.New("content exceeds maximum size")
 	ErrNotChannelMember    = errors.New("not a member of this channel")
 	ErrInvalidEncryption   = errors.New("invalid encryption metadata")
+	ErrBroadcastReadOnly   = errors.New("only the owner and admins can post in broadcast channels")
+	ErrIntegrationsDisabled = errors.New("incoming webhooks are not enabled for this channel")
+	ErrMuted               = errors.New("you are muted in this channel")
+	ErrRekeyRequired       = errors.New("the channel key must be rotated before new messages can be sent")
+	ErrStaleKeyEpoch       = errors.New("encryption_meta.key_epoch does not match the channel's current key epoch")
+	ErrSignatureRequired   = errors.New("messages from accounts with registered device keys must be signed")
+	ErrUnknownSigningKey   = errors.New("no identity key is registered for encryption_meta.device_id")
+	ErrBadSignature        = errors.New("message signature does not verify against the device identity key")
+	ErrWrongDevice         = errors.New("encryption_meta.device_id is not the device this session belongs to")
+	ErrServerEncryptionUnavailable = errors.New("server-managed encryption is not configured")
+	ErrNotServerManaged    = errors.New("channel does not use server-managed encryption")
+	ErrNotChannelAdmin     = errors.New("only the owner and admins can manage channel encryption")
+	ErrDataKeyConflict     = errors.New("channel data key version already exists")
+	ErrDataKeyNotFound     = errors.New("channel data key not found")
 )
+
+// RateLimitError is returned when a member posts faster than the channel's
+// slow mode or burst settings allow
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited: retry after %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds rounds the wait up to whole seconds for the Retry-After header
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
@@ -2,9 +2,11 @@ package messages
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"telegraph/internal/channels"
 	"telegraph/internal/middleware"
 
 	"github.com/go-chi/chi/v5"
@@ -57,10 +59,23 @@ func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		respondError(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
+	if user.Bot {
+		req.SenderType = SenderTypeBot
+	}
 
 	message, err := h.service.SendMessage(r.Context(), req, user.ID, channelID)
 	if err != nil {
-		if err == ErrNotChannelMember {
+		var rateErr *RateLimitError
+		if errors.As(err, &rateErr) {
+			respondRateLimited(w, rateErr)
+			return
+		}
+		var envErr *EnvelopeError
+		if errors.As(err, &envErr) {
+			respondEnvelopeError(w, envErr)
+			return
+		}
+		if err == ErrNotChannelMember || err == ErrBroadcastReadOnly || err == ErrMuted || err == ErrWrongDevice {
 			respondError(w, err.Error(), http.StatusForbidden)
 			return
 		}
@@ -68,6 +83,18 @@ func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 			respondError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if err == ErrSignatureRequired || err == ErrUnknownSigningKey || err == ErrBadSignature {
+			respondError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err == ErrServerEncryptionUnavailable {
+			respondError(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err == ErrRekeyRequired || err == ErrStaleKeyEpoch {
+			respondError(w, err.Error(), http.StatusConflict)
+			return
+		}
 		respondError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -147,22 +174,76 @@ func (h *Handler) EditMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req struct {
-		Content []byte `json:"content"`
-	}
+	var req EditMessageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, "invalid_request", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.EditMessage(r.Context(), messageID, user.ID, req.Content); err != nil {
-		respondError(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.EditMessage(r.Context(), messageID, user.ID, req); err != nil {
+		var envErr *EnvelopeError
+		if errors.As(err, &envErr) {
+			respondEnvelopeError(w, envErr)
+			return
+		}
+		switch err {
+		case ErrMessageNotFound:
+			respondError(w, "message_not_found", http.StatusNotFound)
+		case ErrNotSender, ErrWrongDevice:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrContentTooLarge:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		case ErrSignatureRequired, ErrUnknownSigningKey, ErrBadSignature:
+			respondError(w, err.Error(), http.StatusUnauthorized)
+		case ErrServerEncryptionUnavailable:
+			respondError(w, err.Error(), http.StatusServiceUnavailable)
+		case ErrRekeyRequired, ErrStaleKeyEpoch:
+			respondError(w, err.Error(), http.StatusConflict)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
 	respondJSON(w, map[string]string{"message": "edited"}, http.StatusOK)
 }
 
+// RotateDataKey rotates a server-managed channel's data key
+func (h *Handler) RotateDataKey(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(chi.URLParam(r, "channelId"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	version, err := h.service.RotateDataKey(r.Context(), channelID, user.ID)
+	if err != nil {
+		switch err {
+		case channels.ErrChannelNotFound:
+			respondError(w, err.Error(), http.StatusNotFound)
+		case ErrNotServerManaged:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		case ErrNotChannelAdmin:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrDataKeyConflict:
+			respondError(w, err.Error(), http.StatusConflict)
+		case ErrServerEncryptionUnavailable:
+			respondError(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, map[string]int{"data_key_version": version}, http.StatusOK)
+}
+
 func (h *Handler) MarkAsRead(w http.ResponseWriter, r *http.Request) {
 	messageID, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
@@ -262,3 +343,19 @@ func respondJSON(w http.ResponseWriter, data interface{}, status int) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
+
+func respondRateLimited(w http.ResponseWriter, err *RateLimitError) {
+	w.Header().Set("Retry-After", strconv.Itoa(err.RetryAfterSeconds()))
+	respondJSON(w, map[string]interface{}{
+		"error":       "rate_limited",
+		"retry_after": err.RetryAfterSeconds(),
+	}, http.StatusTooManyRequests)
+}
+
+// respondEnvelopeError reports which part of encryption_meta was rejected
+func respondEnvelopeError(w http.ResponseWriter, err *EnvelopeError) {
+	respondJSON(w, map[string]string{
+		"error": err.Code,
+		"field": err.Field,
+	}, http.StatusBadRequest)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"telegraph/internal/channels"
 	"telegraph/internal/users"
 
 	"github.com/go-chi/chi/v5"
@@ -22,7 +23,7 @@ type MockService struct {
 	GetMessagesFunc     func(ctx context.Context, channelID uuid.UUID, limit, offset int) ([]*Message, error)
 	MarkAsDeliveredFunc func(ctx context.Context, messageID, userID uuid.UUID) error
 	MarkAsReadFunc      func(ctx context.Context, messageID, userID uuid.UUID) error
-	EditMessageFunc     func(ctx context.Context, messageID, userID uuid.UUID, newContent []byte) error
+	EditMessageFunc     func(ctx context.Context, messageID, userID uuid.UUID, req EditMessageRequest) error
 	DeleteMessageFunc   func(ctx context.Context, messageID, userID uuid.UUID, userRole string) error
 }
 
@@ -52,12 +53,21 @@ func (m *MockService) MarkAsDelivered(ctx context.Context, messageID, userID uui
 func (m *MockService) MarkAsRead(ctx context.Context, messageID, userID uuid.UUID) error {
 	return nil
 }
-func (m *MockService) EditMessage(ctx context.Context, messageID, userID uuid.UUID, newContent []byte) error {
+func (m *MockService) EditMessage(ctx context.Context, messageID, userID uuid.UUID, req EditMessageRequest) error {
 	return nil
 }
 func (m *MockService) DeleteMessage(ctx context.Context, messageID, userID uuid.UUID, userRole string) error {
 	return nil
 }
+func (m *MockService) RotateDataKey(ctx context.Context, channelID, requestorID uuid.UUID) (int, error) {
+	return 1, nil
+}
+func (m *MockService) PostIntegrationMessage(ctx context.Context, channelID, hookID uuid.UUID, senderName, text string) (*Message, error) {
+	return &Message{}, nil
+}
+func (m *MockService) ReservePost(channel *channels.Channel, senderID uuid.UUID) (func(), error) {
+	return func() {}, nil
+}
 
 func TestHandler_SendTyping(t *testing.T) {
 	mockService := &MockService{
@@ -17,6 +17,23 @@ const (
 	ContentTypeDocument ContentType = "document"
 )
 
+// SenderType distinguishes messages posted by people from automated ones
+type SenderType string
+
+const (
+	SenderTypeUser        SenderType = "user"
+	SenderTypeBot         SenderType = "bot"
+	SenderTypeIntegration SenderType = "integration" // Incoming webhooks
+)
+
+// EncryptionSchemeNone marks messages stored in plaintext (encryption_meta.scheme).
+// Only integrations in channels that opted in may post them.
+const EncryptionSchemeNone = "none"
+
+// EncryptionSchemeServer marks messages the server encrypted at rest with a
+// channel data key. They are decrypted again before being returned.
+const EncryptionSchemeServer = "server"
+
 // MessageStatus represents delivery/read status
 type MessageStatus string
 
@@ -39,10 +56,16 @@ type FileAttachment struct {
 type Message struct {
 	ID             uuid.UUID              `json:"id" bson:"id"`
 	SenderID       uuid.UUID              `json:"sender_id" bson:"sender_id"`
+	SenderType     SenderType             `json:"sender_type,omitempty" bson:"sender_type,omitempty"`
+	SenderName     string                 `json:"sender_name,omitempty" bson:"sender_name,omitempty"` // Integrations only
 	ChannelID      uuid.UUID              `json:"channel_id" bson:"channel_id"`
 	Content        []byte                 `json:"content" bson:"content"` // Encrypted blob
 	ContentType    ContentType            `json:"content_type" bson:"content_type"`
 	EncryptionMeta map[string]interface{} `json:"encryption_meta" bson:"encryption_meta"` // IV, algorithm info
+	KeyEpoch       int64                  `json:"key_epoch,omitempty" bson:"key_epoch,omitempty"` // Group key generation (group and broadcast channels)
+	SenderDeviceID string                 `json:"sender_device_id,omitempty" bson:"sender_device_id,omitempty"`
+	Signature      []byte                 `json:"signature,omitempty" bson:"signature,omitempty"` // Ed25519 by the sender device's identity key
+	DataKeyVersion int                    `json:"-" bson:"data_key_version,omitempty"` // Server-managed channels only
 	
 	// Attachments
 	Attachments []FileAttachment `json:"attachments,omitempty" bson:"attachments,omitempty"`
@@ -51,6 +74,7 @@ type Message struct {
 	Status        MessageStatus            `json:"status" bson:"status"`
 	DeliveredTo   []uuid.UUID              `json:"delivered_to,omitempty" bson:"delivered_to,omitempty"`
 	ReadBy        []uuid.UUID              `json:"read_by,omitempty" bson:"read_by,omitempty"`
+	Views         int64                    `json:"views,omitempty" bson:"views,omitempty"` // Broadcast channels only
 	
 	// Reply/Forward
 	ReplyTo       *uuid.UUID `json:"reply_to,omitempty" bson:"reply_to,omitempty"`
@@ -65,6 +89,33 @@ type Message struct {
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 }
 
+// MessageVersion preserves the content a message had before an edit.
+// Versions are only recorded while the sender or channel is under legal hold
+// and are never returned to regular users.
+type MessageVersion struct {
+	ID             uuid.UUID              `json:"id" bson:"_id"`
+	MessageID      uuid.UUID              `json:"message_id" bson:"message_id"`
+	ChannelID      uuid.UUID              `json:"channel_id" bson:"channel_id"`
+	SenderID       uuid.UUID              `json:"sender_id" bson:"sender_id"`
+	Content        []byte                 `json:"content" bson:"content"`
+	EncryptionMeta map[string]interface{} `json:"encryption_meta" bson:"encryption_meta"`
+	DataKeyVersion int                    `json:"data_key_version,omitempty" bson:"data_key_version,omitempty"` // Server-managed channels only
+	EditedAt       *time.Time             `json:"edited_at,omitempty" bson:"edited_at,omitempty"`
+	ReplacedAt     time.Time              `json:"replaced_at" bson:"replaced_at"`
+}
+
+// DataKey is a server-managed channel's AES-256 key, wrapped with the master
+// key. The highest version is used for new messages; older versions are kept
+// for messages that have not been re-encrypted yet and for held versions.
+type DataKey struct {
+	ID         uuid.UUID `bson:"_id"`
+	ChannelID  uuid.UUID `bson:"channel_id"`
+	Version    int       `bson:"version"`
+	WrappedKey []byte    `bson:"wrapped_key"`
+	Nonce      []byte    `bson:"nonce"`
+	CreatedAt  time.Time `bson:"created_at"`
+}
+
 // SendMessageRequest is the payload for sending a message
 type SendMessageRequest struct {
 	Content        []byte                 `json:"content"` // Already encrypted by client
@@ -73,4 +124,13 @@ type SendMessageRequest struct {
 	Attachments    []FileAttachment       `json:"attachments,omitempty"`
 	ReplyTo        *uuid.UUID             `json:"reply_to,omitempty"`
 	ForwardedFrom  *uuid.UUID             `json:"forwarded_from,omitempty"`
+
+	SenderType SenderType `json:"-"` // Set by the handler from the authenticated account
+}
+
+// EditMessageRequest replaces a message's content. Edits are re-encrypted, so
+// they need a fresh envelope (and IV) of their own.
+type EditMessageRequest struct {
+	Content        []byte                 `json:"content"`
+	EncryptionMeta map[string]interface{} `json:"encryption_meta"`
 }
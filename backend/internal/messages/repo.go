@@ -2,6 +2,8 @@ package messages
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,16 +19,25 @@ type MessageRepo interface {
 	Update(ctx context.Context, m *Message) error
 	SoftDelete(ctx context.Context, id uuid.UUID) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	SaveVersion(ctx context.Context, m *Message) error
 	CountAfter(ctx context.Context, channelID uuid.UUID, after time.Time) (int64, error)
+	IncrementViews(ctx context.Context, channelID uuid.UUID, after, upTo time.Time) error
+	ReserveNonce(ctx context.Context, channelID uuid.UUID, keyEpoch int64, nonce string) error
+	ListBelowDataKeyVersion(ctx context.Context, channelID uuid.UUID, version, limit int) ([]*Message, error)
+	UpdateEncryptedContent(ctx context.Context, m *Message, fromVersion int) (bool, error)
 }
 
 type mongoMessageRepo struct {
 	collection *mongo.Collection
+	versions   *mongo.Collection
+	nonces     *mongo.Collection
 }
 
 func NewMongoMessageRepo(db *mongo.Database) MessageRepo {
 	return &mongoMessageRepo{
 		collection: db.Collection("messages"),
+		versions:   db.Collection("message_versions"),
+		nonces:     db.Collection("message_nonces"),
 	}
 }
 
@@ -83,6 +94,22 @@ func (r *mongoMessageRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+func (r *mongoMessageRepo) SaveVersion(ctx context.Context, m *Message) error {
+	version := MessageVersion{
+		ID:             uuid.New(),
+		MessageID:      m.ID,
+		ChannelID:      m.ChannelID,
+		SenderID:       m.SenderID,
+		Content:        m.Content,
+		EncryptionMeta: m.EncryptionMeta,
+		DataKeyVersion: m.DataKeyVersion,
+		EditedAt:       m.EditedAt,
+		ReplacedAt:     time.Now(),
+	}
+	_, err := r.versions.InsertOne(ctx, version)
+	return err
+}
+
 func (r *mongoMessageRepo) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.collection.DeleteOne(ctx, bson.M{"id": id})
 	return err
@@ -96,3 +123,124 @@ func (r *mongoMessageRepo) CountAfter(ctx context.Context, channelID uuid.UUID,
 	}
 	return r.collection.CountDocuments(ctx, filter)
 }
+
+// IncrementViews adds one view to every message in (after, upTo]
+func (r *mongoMessageRepo) IncrementViews(ctx context.Context, channelID uuid.UUID, after, upTo time.Time) error {
+	filter := bson.M{
+		"channel_id": channelID,
+		"timestamp":  bson.M{"$gt": after, "$lte": upTo},
+		"deleted":    false,
+	}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$inc": bson.M{"views": 1}})
+	return err
+}
+
+// ReserveNonce records an IV as used for a channel key epoch. The composite _id
+// makes the check atomic; a second use returns the nonce_reused envelope error.
+func (r *mongoMessageRepo) ReserveNonce(ctx context.Context, channelID uuid.UUID, keyEpoch int64, nonce string) error {
+	doc := bson.M{
+		"_id":        fmt.Sprintf("%s:%d:%s", channelID, keyEpoch, nonce),
+		"channel_id": channelID,
+		"key_epoch":  keyEpoch,
+		"created_at": time.Now(),
+	}
+	_, err := r.nonces.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return envelopeError(EnvelopeNonceReused, "iv")
+	}
+	return err
+}
+
+// ListBelowDataKeyVersion returns server-encrypted messages that still use an
+// older data key. Messages without content have nothing to re-encrypt.
+func (r *mongoMessageRepo) ListBelowDataKeyVersion(ctx context.Context, channelID uuid.UUID, version, limit int) ([]*Message, error) {
+	filter := bson.M{
+		"channel_id":       channelID,
+		"data_key_version": bson.M{"$lt": version},
+		"content":          bson.M{"$exists": true},
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []*Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// UpdateEncryptedContent replaces the ciphertext of a message that is still
+// encrypted under fromVersion and has not been edited since it was loaded
+// (m.EditedAt). It reports false when the message changed in the meantime.
+func (r *mongoMessageRepo) UpdateEncryptedContent(ctx context.Context, m *Message, fromVersion int) (bool, error) {
+	filter := bson.M{
+		"id":               m.ID,
+		"data_key_version": fromVersion,
+		"edited_at":        m.EditedAt,
+	}
+	update := bson.M{"$set": bson.M{
+		"content":          m.Content,
+		"encryption_meta":  m.EncryptionMeta,
+		"data_key_version": m.DataKeyVersion,
+	}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+type DataKeyRepo interface {
+	Create(ctx context.Context, k *DataKey) error
+	GetLatest(ctx context.Context, channelID uuid.UUID) (*DataKey, error)
+	GetVersion(ctx context.Context, channelID uuid.UUID, version int) (*DataKey, error)
+}
+
+type mongoDataKeyRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDataKeyRepo(db *mongo.Database) DataKeyRepo {
+	return &mongoDataKeyRepo{collection: db.Collection("channel_data_keys")}
+}
+
+// Create inserts a key version. The _id is derived from channel and version
+// so two concurrent rotations can't both create the same version.
+func (r *mongoDataKeyRepo) Create(ctx context.Context, k *DataKey) error {
+	k.ID = uuid.NewSHA1(k.ChannelID, []byte(strconv.Itoa(k.Version)))
+	k.CreatedAt = time.Now()
+	_, err := r.collection.InsertOne(ctx, k)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDataKeyConflict
+	}
+	return err
+}
+
+// GetLatest returns the channel's newest key, or nil if it has none yet
+func (r *mongoDataKeyRepo) GetLatest(ctx context.Context, channelID uuid.UUID) (*DataKey, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	var k DataKey
+	err := r.collection.FindOne(ctx, bson.M{"channel_id": channelID}, opts).Decode(&k)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func (r *mongoDataKeyRepo) GetVersion(ctx context.Context, channelID uuid.UUID, version int) (*DataKey, error) {
+	var k DataKey
+	err := r.collection.FindOne(ctx, bson.M{"channel_id": channelID, "version": version}).Decode(&k)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDataKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
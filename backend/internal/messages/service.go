@@ -2,12 +2,16 @@ package messages
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
+	"log"
 	"time"
 
 	"telegraph/internal/acl"
 	"telegraph/internal/audit"
 	"telegraph/internal/channels"
+	"telegraph/internal/ratelimit"
+	"telegraph/internal/users"
 
 	"github.com/google/uuid"
 )
@@ -20,9 +24,12 @@ type MessageService interface {
 	DeleteMessage(ctx context.Context, messageID, userID uuid.UUID, userRole string) error
 	MarkAsDelivered(ctx context.Context, messageID, userID uuid.UUID) error
 	MarkAsRead(ctx context.Context, messageID, userID uuid.UUID) error
-	EditMessage(ctx context.Context, messageID, userID uuid.UUID, newContent []byte) error
+	EditMessage(ctx context.Context, messageID, userID uuid.UUID, req EditMessageRequest) error
 	BroadcastTyping(ctx context.Context, userID, channelID uuid.UUID, typing bool) error
 	GetUnreadCounts(ctx context.Context, userID uuid.UUID) (map[string]int, error)
+	PostIntegrationMessage(ctx context.Context, channelID, hookID uuid.UUID, senderName, text string) (*Message, error)
+	RotateDataKey(ctx context.Context, channelID, requestorID uuid.UUID) (int, error)
+	ReservePost(channel *channels.Channel, senderID uuid.UUID) (func(), error)
 }
 
 type messageService struct {
@@ -30,16 +37,49 @@ type messageService struct {
 	channelRepo channels.ChannelRepo
 	audit       *audit.Logger
 	hub         Hub
+	holds       HoldChecker
+	events      EventPublisher
+	signatures  SignatureVerifier
+	dataKeys    *DataKeyManager
+	limiter     *ratelimit.Limiter
 }
 
 // Hub interface for WebSocket broadcasting
 type Hub interface {
 	SendToUser(userID string, message interface{})
+	SendToUsers(userIDs []string, message interface{})
 	BroadcastTyping(userID, channelID string, typing bool)
 }
 
-func NewMessageService(repo MessageRepo, channelRepo channels.ChannelRepo, audit *audit.Logger, hub Hub) MessageService {
-	return &messageService{repo: repo, channelRepo: channelRepo, audit: audit, hub: hub}
+// HoldChecker reports whether legal holds require message data to be preserved
+type HoldChecker interface {
+	IsUserHeld(ctx context.Context, userID uuid.UUID) (bool, error)
+	IsChannelHeld(ctx context.Context, channelID uuid.UUID) (bool, error)
+}
+
+// EventPublisher notifies external integrations such as webhooks about new messages
+type EventPublisher interface {
+	Publish(ctx context.Context, eventType string, channelID *uuid.UUID, data interface{})
+}
+
+// SignatureVerifier looks up device identity keys in the key directory
+type SignatureVerifier interface {
+	IdentityKey(ctx context.Context, userID uuid.UUID, deviceID string) ([]byte, error)
+	HasIdentityKeys(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+func NewMessageService(repo MessageRepo, channelRepo channels.ChannelRepo, audit *audit.Logger, hub Hub, holds HoldChecker, events EventPublisher, signatures SignatureVerifier, dataKeys *DataKeyManager) MessageService {
+	return &messageService{
+		repo:        repo,
+		channelRepo: channelRepo,
+		audit:       audit,
+		hub:         hub,
+		holds:       holds,
+		events:      events,
+		signatures:  signatures,
+		dataKeys:    dataKeys,
+		limiter:     ratelimit.NewLimiter(),
+	}
 }
 
 func (s *messageService) SendMessage(ctx context.Context, req SendMessageRequest, senderID, channelID uuid.UUID) (*Message, error) {
@@ -55,21 +95,50 @@ func (s *messageService) SendMessage(ctx context.Context, req SendMessageRequest
 	}
 
 	// Verify sender is member of channel
-	isMember, err := s.channelRepo.IsMember(ctx, channelID, senderID)
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err == channels.ErrChannelNotFound {
+		return nil, ErrNotChannelMember
+	}
 	if err != nil {
 		return nil, err
 	}
-	if !isMember {
-		return nil, ErrNotChannelMember
+	if err := checkPoster(channel, senderID); err != nil {
+		return nil, err
+	}
+
+	// Validate the encryption envelope. Server-managed channels receive
+	// plaintext over TLS and have no client envelope.
+	var envelope *Envelope
+	if !channel.ServerManaged() {
+		envelope, err = validateEnvelope(channel, req.EncryptionMeta)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.verifySignature(ctx, senderID, channelID, envelope, req.Content); err != nil {
+			return nil, err
+		}
+	}
+
+	// Enforce slow mode and burst limits (owners and admins are exempt)
+	release, err := s.reserveSendSlot(channel, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if envelope != nil {
+		if err := s.repo.ReserveNonce(ctx, channelID, envelope.KeyEpoch, envelope.NonceKey()); err != nil {
+			release()
+			return nil, err
+		}
 	}
 
-	// Validate encryption metadata exists
-	if req.EncryptionMeta == nil || len(req.EncryptionMeta) == 0 {
-		return nil, ErrInvalidEncryption
+	if req.SenderType == "" {
+		req.SenderType = SenderTypeUser
 	}
 
 	message := &Message{
 		SenderID:       senderID,
+		SenderType:     req.SenderType,
 		ChannelID:      channelID,
 		Content:        req.Content,
 		ContentType:    req.ContentType,
@@ -81,35 +150,217 @@ func (s *messageService) SendMessage(ctx context.Context, req SendMessageRequest
 		Deleted:        false,
 		Edited:         false,
 	}
+	if envelope != nil {
+		message.KeyEpoch = envelope.KeyEpoch
+		message.SenderDeviceID = envelope.DeviceID
+		message.Signature = envelope.Signature
+	}
+	// The server writes encryption_meta in server-managed channels, so
+	// clients can't claim a "source" such as an incoming webhook
+	if channel.ServerManaged() {
+		message.EncryptionMeta = nil
+	}
 
-	if err := s.repo.Create(ctx, message); err != nil {
+	if err := s.create(ctx, channel, message); err != nil {
+		release()
 		return nil, err
 	}
 
+	s.announce(ctx, channel, message, &senderID)
+
+	return message, nil
+}
+
+// PostIntegrationMessage stores a plaintext message from an incoming webhook.
+// The channel has to opt in because these messages bypass end-to-end encryption.
+func (s *messageService) PostIntegrationMessage(ctx context.Context, channelID, hookID uuid.UUID, senderName, text string) (*Message, error) {
+	if len(text) > MaxContentSize {
+		return nil, ErrContentTooLarge
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.Settings.AllowIncomingWebhooks {
+		return nil, ErrIntegrationsDisabled
+	}
+
+	message := &Message{
+		SenderID:    hookID,
+		SenderType:  SenderTypeIntegration,
+		SenderName:  senderName,
+		ChannelID:   channelID,
+		Content:     []byte(text),
+		ContentType: ContentTypeText,
+		EncryptionMeta: map[string]interface{}{
+			"scheme": EncryptionSchemeNone,
+			"source": "incoming_webhook",
+		},
+		Status: MessageStatusSent,
+	}
+
+	if err := s.create(ctx, channel, message); err != nil {
+		return nil, err
+	}
+
+	s.announce(audit.ContextWithActor(ctx, audit.ActorIntegration), channel, message, nil)
+
+	return message, nil
+}
+
+// create stores a message, encrypting it at rest first in server-managed
+// channels. The caller's copy keeps the plaintext for fan-out.
+func (s *messageService) create(ctx context.Context, channel *channels.Channel, message *Message) error {
+	if !channel.ServerManaged() {
+		return s.repo.Create(ctx, message)
+	}
+
+	plaintext := message.Content
+	if err := s.dataKeys.Seal(ctx, message, plaintext); err != nil {
+		return err
+	}
+	if err := s.repo.Create(ctx, message); err != nil {
+		return err
+	}
+
+	message.Content = plaintext
+	message.EncryptionMeta = serverMeta(message.EncryptionMeta)
+	return nil
+}
+
+// announce fans a newly stored message out to members and integrations and
+// records it in the audit log
+func (s *messageService) announce(ctx context.Context, channel *channels.Channel, message *Message, senderID *uuid.UUID) {
 	// Broadcast message to all channel members via WebSocket
-	channel, _ := s.channelRepo.GetByID(ctx, channelID)
-	if channel != nil && s.hub != nil {
+	if s.hub != nil {
 		wsMessage := map[string]interface{}{
 			"type":       "MESSAGE_NEW",
-			"channel_id": channelID.String(),
+			"channel_id": channel.ID.String(),
 			"message":    message,
 		}
-		
-		for _, member := range channel.Members {
-			s.hub.SendToUser(member.UserID.String(), wsMessage)
-		}
+		s.hub.SendToUsers(channel.MemberIDs(), wsMessage)
+	}
+	if s.events != nil {
+		s.events.Publish(ctx, "MESSAGE_NEW", &channel.ID, message)
 	}
 
 	// Audit Log
 	s.audit.Log(ctx, audit.AuditLog{
-		UserID:   &senderID,
+		UserID:   senderID,
 		Action:   audit.EventMessageSent,
 		Resource: message.ID.String(),
 		Result:   "success",
-		Details:  fmt.Sprintf("Sent message to channel %s", channelID),
+		Details:  fmt.Sprintf("Sent message to channel %s (sender_type=%s)", channel.ID, message.SenderType),
 	})
+}
 
-	return message, nil
+// validateEnvelope parses encryption_meta and checks it against the channel.
+// Group messages must be encrypted with the current channel key.
+func validateEnvelope(channel *channels.Channel, meta map[string]interface{}) (*Envelope, error) {
+	envelope, err := ParseEnvelope(meta)
+	if err != nil {
+		return nil, err
+	}
+	if channel.UsesGroupKeys() {
+		if channel.RekeyRequired {
+			return nil, ErrRekeyRequired
+		}
+		if envelope.KeyEpoch != channel.KeyEpoch {
+			return nil, ErrStaleKeyEpoch
+		}
+	}
+	return envelope, nil
+}
+
+// verifySignature checks the envelope signature against the sending device's
+// identity key. Once a user has registered device keys every message must be
+// signed, so a stolen session token alone can't post as them. The envelope
+// has to name the device the session belongs to.
+func (s *messageService) verifySignature(ctx context.Context, senderID, channelID uuid.UUID, envelope *Envelope, content []byte) error {
+	if envelope.DeviceID != users.DeviceIDFromContext(ctx) {
+		return ErrWrongDevice
+	}
+	if s.signatures == nil {
+		return nil
+	}
+	if envelope.Signature == nil {
+		registered, err := s.signatures.HasIdentityKeys(ctx, senderID)
+		if err != nil {
+			return err
+		}
+		if registered {
+			return ErrSignatureRequired
+		}
+		return nil
+	}
+
+	identityKey, err := s.signatures.IdentityKey(ctx, senderID, envelope.DeviceID)
+	if err != nil {
+		return err
+	}
+	if len(identityKey) != ed25519.PublicKeySize {
+		return ErrUnknownSigningKey
+	}
+	if !ed25519.Verify(ed25519.PublicKey(identityKey), envelope.SigningPayload(channelID, content), envelope.Signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// checkPoster reports whether senderID may post in the channel at all
+func checkPoster(channel *channels.Channel, senderID uuid.UUID) error {
+	if channel.MemberRole(senderID) == "" {
+		return ErrNotChannelMember
+	}
+	if channel.IsMuted(senderID, time.Now()) {
+		return ErrMuted
+	}
+
+	// Broadcast channels are read-only for subscribers
+	if channel.Type == channels.ChannelTypeBroadcast && !channel.IsOwnerOrAdmin(senderID) {
+		return ErrBroadcastReadOnly
+	}
+	return nil
+}
+
+// ReservePost applies the same membership, mute, broadcast and slow mode
+// checks as SendMessage for content that is posted some other way, such as
+// polls. The returned func gives the send slot back if posting fails.
+func (s *messageService) ReservePost(channel *channels.Channel, senderID uuid.UUID) (func(), error) {
+	if err := checkPoster(channel, senderID); err != nil {
+		return nil, err
+	}
+	return s.reserveSendSlot(channel, senderID)
+}
+
+// reserveSendSlot counts a message against the channel's slow mode and burst
+// limits in one step, so concurrent sends can't all get under the limit, and
+// returns a RateLimitError if the sender has to wait. The returned func gives
+// the slot back when the message ends up not being stored.
+func (s *messageService) reserveSendSlot(channel *channels.Channel, senderID uuid.UUID) (func(), error) {
+	settings := channel.Settings
+	var tiers []ratelimit.Tier
+	if settings.SlowModeSeconds > 0 {
+		tiers = append(tiers, ratelimit.Tier{Limit: 1, Window: time.Duration(settings.SlowModeSeconds) * time.Second})
+	}
+	if settings.BurstLimit > 0 && settings.BurstWindowSeconds > 0 {
+		tiers = append(tiers, ratelimit.Tier{Limit: settings.BurstLimit, Window: time.Duration(settings.BurstWindowSeconds) * time.Second})
+	}
+	if len(tiers) == 0 || channel.IsOwnerOrAdmin(senderID) {
+		return func() {}, nil
+	}
+
+	key := sendKey(channel.ID, senderID)
+	at, wait := s.limiter.Reserve(key, tiers...)
+	if wait > 0 {
+		return nil, &RateLimitError{RetryAfter: wait}
+	}
+	return func() { s.limiter.Release(key, at) }, nil
+}
+
+func sendKey(channelID, userID uuid.UUID) string {
+	return "send:" + channelID.String() + ":" + userID.String()
 }
 
 func (s *messageService) GetMessages(ctx context.Context, channelID, userID uuid.UUID, limit, offset int) ([]*Message, error) {
@@ -130,7 +381,96 @@ func (s *messageService) GetMessages(ctx context.Context, channelID, userID uuid
 		offset = 0
 	}
 
-	return s.repo.GetByChannelID(ctx, channelID, limit, offset)
+	messages, err := s.repo.GetByChannelID(ctx, channelID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypt messages the server encrypted at rest
+	for _, m := range messages {
+		if m.DataKeyVersion == 0 {
+			continue
+		}
+		if err := s.dataKeys.Open(ctx, m); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// RotateDataKey switches a server-managed channel to a new data key and
+// re-encrypts existing messages with it in the background
+func (s *messageService) RotateDataKey(ctx context.Context, channelID, requestorID uuid.UUID) (int, error) {
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return 0, err
+	}
+	if !channel.ServerManaged() {
+		return 0, ErrNotServerManaged
+	}
+	if !channel.IsOwnerOrAdmin(requestorID) {
+		return 0, ErrNotChannelAdmin
+	}
+
+	version, err := s.dataKeys.Rotate(ctx, channelID)
+	if err != nil {
+		return 0, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &requestorID,
+		Action:   audit.EventChannelDataKeyRotated,
+		Resource: channelID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Rotated server-managed data key to version %d", version),
+	})
+
+	go s.reencrypt(context.Background(), channelID, version)
+
+	return version, nil
+}
+
+// reencryptBatch bounds how many messages are loaded at once during re-encryption
+const reencryptBatch = 100
+
+// reencrypt moves every message encrypted under an older data key to the
+// newest one. Held versions keep their original keys, which are never deleted.
+func (s *messageService) reencrypt(ctx context.Context, channelID uuid.UUID, version int) {
+	total := 0
+	for {
+		batch, err := s.repo.ListBelowDataKeyVersion(ctx, channelID, version, reencryptBatch)
+		if err != nil {
+			log.Printf("Re-encryption of channel %s stopped: %v", channelID, err)
+			return
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, m := range batch {
+			from := m.DataKeyVersion
+			if err := s.dataKeys.Open(ctx, m); err != nil {
+				log.Printf("Re-encryption of channel %s stopped: %v", channelID, err)
+				return
+			}
+			if err := s.dataKeys.Seal(ctx, m, m.Content); err != nil {
+				log.Printf("Re-encryption of channel %s stopped: %v", channelID, err)
+				return
+			}
+			// An edit that landed since the batch was loaded wins; if it still
+			// used an old key the message shows up in the next batch
+			updated, err := s.repo.UpdateEncryptedContent(ctx, m, from)
+			if err != nil {
+				log.Printf("Re-encryption of channel %s stopped: %v", channelID, err)
+				return
+			}
+			if updated {
+				total++
+			}
+		}
+	}
+
+	log.Printf("Re-encrypted %d messages in channel %s with data key %d", total, channelID, version)
 }
 
 func (s *messageService) DeleteMessage(ctx context.Context, messageID, userID uuid.UUID, userRole string) error {
@@ -139,17 +479,22 @@ func (s *messageService) DeleteMessage(ctx context.Context, messageID, userID uu
 		return err
 	}
 
-	// Users can delete their own messages
-	if message.SenderID == userID {
-		return s.repo.SoftDelete(ctx, messageID)
+	// Users can delete their own messages, moderators and admins can delete any message
+	if message.SenderID != userID && !acl.HasPermission(userRole, acl.PermissionDeleteAnyMessage) {
+		return fmt.Errorf("insufficient permissions to delete message")
 	}
 
-	// Moderators and admins can delete any message
-	if acl.HasPermission(userRole, acl.PermissionDeleteAnyMessage) {
-		return s.repo.SoftDelete(ctx, messageID)
-	}
+	// Deleted messages are hidden but kept, which also satisfies legal holds
+	return s.repo.SoftDelete(ctx, messageID)
+}
 
-	return fmt.Errorf("insufficient permissions to delete message")
+// isHeld reports whether the message's sender or channel is under legal hold
+func (s *messageService) isHeld(ctx context.Context, message *Message) (bool, error) {
+	held, err := s.holds.IsChannelHeld(ctx, message.ChannelID)
+	if err != nil || held {
+		return held, err
+	}
+	return s.holds.IsUserHeld(ctx, message.SenderID)
 }
 
 func (s *messageService) MarkAsDelivered(ctx context.Context, messageID, userID uuid.UUID) error {
@@ -178,13 +523,31 @@ func (s *messageService) MarkAsRead(ctx context.Context, messageID, userID uuid.
 		return err
 	}
 
-	// Update last read pointer in channel member
-	if err := s.channelRepo.UpdateLastRead(ctx, message.ChannelID, userID, messageID); err != nil {
+	channel, err := s.channelRepo.GetByID(ctx, message.ChannelID)
+	if err != nil {
 		return err
 	}
 
-	// Broadcast read receipt
-	if s.hub != nil {
+	// Only the request that actually moves the read pointer forward goes on,
+	// so a message is never counted or receipted twice for the same reader
+	previous, err := s.channelRepo.AdvanceLastRead(ctx, message.ChannelID, userID, messageID, message.Timestamp)
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		return nil
+	}
+
+	// Broadcast posts count a view for everything between the previous read
+	// pointer and this message
+	if channel.Type == channels.ChannelTypeBroadcast {
+		if err := s.countViews(ctx, channel.ID, previous, message); err != nil {
+			return err
+		}
+	}
+
+	// Broadcast read receipt (subscribers of broadcast channels stay anonymous)
+	if s.hub != nil && channel.Type != channels.ChannelTypeBroadcast {
 		s.hub.SendToUser(message.SenderID.String(), map[string]interface{}{
 			"type":       "MESSAGE_READ",
 			"message_id": messageID,
@@ -195,7 +558,26 @@ func (s *messageService) MarkAsRead(ctx context.Context, messageID, userID uuid.
 	return nil
 }
 
-func (s *messageService) EditMessage(ctx context.Context, messageID, userID uuid.UUID, newContent []byte) error {
+// countViews increments view counters for the posts between the reader's
+// previous read pointer and message
+func (s *messageService) countViews(ctx context.Context, channelID uuid.UUID, previous *channels.ChannelMember, message *Message) error {
+	since := previous.JoinedAt
+	switch {
+	case previous.LastReadAt != nil:
+		since = *previous.LastReadAt
+	case previous.LastReadMessageID != nil:
+		// Pointers stored before LastReadAt existed
+		if last, err := s.repo.GetByID(ctx, *previous.LastReadMessageID); err == nil {
+			since = last.Timestamp
+		}
+	}
+	if !message.Timestamp.After(since) {
+		return nil
+	}
+	return s.repo.IncrementViews(ctx, channelID, since, message.Timestamp)
+}
+
+func (s *messageService) EditMessage(ctx context.Context, messageID, userID uuid.UUID, req EditMessageRequest) error {
 	message, err := s.repo.GetByID(ctx, messageID)
 	if err != nil {
 		return err
@@ -205,14 +587,69 @@ func (s *messageService) EditMessage(ctx context.Context, messageID, userID uuid
 		return ErrNotSender
 	}
 
-	message.Content = newContent
+	if len(req.Content) > MaxContentSize {
+		return ErrContentTooLarge
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, message.ChannelID)
+	if err != nil {
+		return err
+	}
+	var envelope *Envelope
+	if !channel.ServerManaged() {
+		envelope, err = validateEnvelope(channel, req.EncryptionMeta)
+		if err != nil {
+			return err
+		}
+		if err := s.verifySignature(ctx, userID, channel.ID, envelope, req.Content); err != nil {
+			return err
+		}
+	}
+
+	// Keep the prior version when a legal hold is in place
+	held, err := s.isHeld(ctx, message)
+	if err != nil {
+		return err
+	}
+	if held {
+		if err := s.repo.SaveVersion(ctx, message); err != nil {
+			return err
+		}
+	}
+
+	// Reserved last, so an edit that fails above can be retried with the
+	// same ciphertext
+	if envelope != nil {
+		if err := s.repo.ReserveNonce(ctx, channel.ID, envelope.KeyEpoch, envelope.NonceKey()); err != nil {
+			return err
+		}
+	}
+
+	message.Content = req.Content
+	if !channel.ServerManaged() {
+		message.EncryptionMeta = req.EncryptionMeta
+	}
+	if envelope != nil {
+		message.KeyEpoch = envelope.KeyEpoch
+		message.SenderDeviceID = envelope.DeviceID
+		message.Signature = envelope.Signature
+	}
 	message.Edited = true
 	now := time.Now()
 	message.EditedAt = &now
 
+	if channel.ServerManaged() {
+		if err := s.dataKeys.Seal(ctx, message, req.Content); err != nil {
+			return err
+		}
+	}
 	if err := s.repo.Update(ctx, message); err != nil {
 		return err
 	}
+	if channel.ServerManaged() {
+		message.Content = req.Content
+		message.EncryptionMeta = serverMeta(message.EncryptionMeta)
+	}
 
 	// Broadcast edit
 	if s.hub != nil {
@@ -230,9 +667,7 @@ func (s *messageService) EditMessage(ctx context.Context, messageID, userID uuid
 				"channel_id": message.ChannelID.String(),
 				"message":    message,
 			}
-			for _, member := range channel.Members {
-				s.hub.SendToUser(member.UserID.String(), wsMessage)
-			}
+			s.hub.SendToUsers(channel.MemberIDs(), wsMessage)
 		}
 	}
 	return nil
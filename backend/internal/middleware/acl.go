@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"telegraph/internal/acl"
+	"telegraph/internal/audit"
 	"telegraph/internal/users"
 
 	"github.com/google/uuid"
@@ -41,8 +42,16 @@ func LoadUser(userRepo users.UserRepo) func(http.Handler) http.Handler {
 				return
 			}
 
+			if user.Suspended {
+				respondError(w, "account_suspended", http.StatusForbidden)
+				return
+			}
+
 			// Store full user in context
 			ctx := context.WithValue(r.Context(), userContextKey, user)
+			if user.Bot {
+				ctx = audit.ContextWithActor(ctx, audit.ActorBot)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
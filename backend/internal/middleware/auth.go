@@ -1,10 +1,13 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
 	"telegraph/internal/users"
+
+	"github.com/google/uuid"
 )
 
 func JWTAuth(jwtMgr *users.JWTManager) func(http.Handler) http.Handler {
@@ -23,13 +26,64 @@ func JWTAuth(jwtMgr *users.JWTManager) func(http.Handler) http.Handler {
 				return
 			}
 
-			userID, err := jwtMgr.Verify(parts[1])
+			claims, err := jwtMgr.Parse(parts[1])
 			if err != nil {
 				http.Error(w, "invalid_token", 401)
 				return
 			}
 
+			ctx := users.ContextWithUserID(r.Context(), claims.UserID)
+			ctx = users.ContextWithDeviceID(ctx, claims.DeviceID)
+			ctx = users.ContextWithSessionID(ctx, claims.SessionID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// BotAuthenticator resolves bot tokens to the bot's user ID
+type BotAuthenticator interface {
+	AuthenticateBot(ctx context.Context, token string) (uuid.UUID, error)
+}
+
+// Authenticate accepts either a user JWT ("Bearer <jwt>") or a bot token ("Bot <token>")
+func Authenticate(jwtMgr *users.JWTManager, bots BotAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			auth := r.Header.Get("Authorization")
+			if auth == "" {
+				http.Error(w, "missing_token", 401)
+				return
+			}
+
+			parts := strings.Split(auth, " ")
+			if len(parts) != 2 {
+				http.Error(w, "invalid_auth_header", 401)
+				return
+			}
+
+			var userID, deviceID, sessionID string
+			if parts[0] == "Bot" {
+				botID, err := bots.AuthenticateBot(r.Context(), parts[1])
+				if err != nil {
+					http.Error(w, "invalid_token", 401)
+					return
+				}
+				userID = botID.String()
+			} else {
+				claims, err := jwtMgr.Parse(parts[1])
+				if err != nil {
+					http.Error(w, "invalid_token", 401)
+					return
+				}
+				userID = claims.UserID
+				deviceID = claims.DeviceID
+				sessionID = claims.SessionID
+			}
+
 			ctx := users.ContextWithUserID(r.Context(), userID)
+			ctx = users.ContextWithDeviceID(ctx, deviceID)
+			ctx = users.ContextWithSessionID(ctx, sessionID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP replaces r.RemoteAddr with the client address reported in header,
+// but only for requests that arrive from one of the trusted proxies. The
+// header is read right to left, skipping trusted hops, so a client can't
+// pick its own address by sending the header itself. With no trusted
+// proxies the connection's address is always used.
+func RealIP(header string, trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := clientIP(r, header, trusted); ip != "" {
+				r.RemoteAddr = net.JoinHostPort(ip, "0")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the forwarded client address, or "" to keep RemoteAddr
+func clientIP(r *http.Request, header string, trusted []*net.IPNet) string {
+	if len(trusted) == 0 || header == "" {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(net.ParseIP(host), trusted) {
+		return ""
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values(header) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if !isTrusted(ip, trusted) {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct client ignores header", "198.51.100.4:5000", "203.0.113.9", ""},
+		{"trusted proxy", "10.0.0.2:5000", "203.0.113.9", "203.0.113.9"},
+		{"spoofed left entries are skipped", "10.0.0.2:5000", "1.2.3.4, 203.0.113.9", "203.0.113.9"},
+		{"chained trusted proxies", "10.0.0.2:5000", "203.0.113.9, 10.0.0.7", "203.0.113.9"},
+		{"garbage header", "10.0.0.2:5000", "not-an-ip", ""},
+		{"missing header", "10.0.0.2:5000", "", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := clientIP(r, "X-Forwarded-For", trusted); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:5000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	if got := clientIP(r, "X-Forwarded-For", nil); got != "" {
+		t.Errorf("without trusted proxies got %q", got)
+	}
+}
@@ -0,0 +1,15 @@
+package moderation
+
+import "errors"
+
+var (
+	ErrReportNotFound    = errors.New("report not found")
+	ErrAlreadyReported   = errors.New("message already reported by this user")
+	ErrPlaintextRequired = errors.New("plaintext of the reported message is required")
+	ErrPlaintextTooLarge = errors.New("reported plaintext exceeds maximum size")
+	ErrCannotReportOwn   = errors.New("cannot report your own message")
+	ErrReportNotOpen     = errors.New("report is not open")
+	ErrReportResolved    = errors.New("report is already resolved")
+	ErrNotClaimant       = errors.New("report is claimed by another moderator")
+	ErrInvalidResolution = errors.New("invalid resolution action")
+)
@@ -0,0 +1,191 @@
+package moderation
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"telegraph/internal/channels"
+	"telegraph/internal/messages"
+	"telegraph/internal/middleware"
+	"telegraph/internal/users"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service ModerationService
+}
+
+func NewHandler(service ModerationService) *Handler {
+	return &Handler{service: service}
+}
+
+// Routes returns the moderation queue routes (moderators and above)
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/reports", h.ListReports)
+	r.Get("/reports/{id}", h.GetReport)
+	r.Post("/reports/{id}/claim", h.ClaimReport)
+	r.Post("/reports/{id}/resolve", h.ResolveReport)
+
+	return r
+}
+
+func (h *Handler) ReportMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_message_id", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.service.ReportMessage(r.Context(), messageID, user.ID, req)
+	if err != nil {
+		switch err {
+		case messages.ErrMessageNotFound:
+			respondError(w, "message_not_found", http.StatusNotFound)
+		case messages.ErrNotChannelMember:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrAlreadyReported:
+			respondError(w, err.Error(), http.StatusConflict)
+		case ErrPlaintextRequired, ErrPlaintextTooLarge, ErrCannotReportOwn:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, report, http.StatusCreated)
+}
+
+func (h *Handler) ListReports(w http.ResponseWriter, r *http.Request) {
+	status := ReportStatus(r.URL.Query().Get("status"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	reports, err := h.service.ListReports(r.Context(), status, limit, offset)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, reports, http.StatusOK)
+}
+
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_report_id", http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.GetReport(r.Context(), reportID)
+	if err != nil {
+		if err == ErrReportNotFound {
+			respondError(w, "report_not_found", http.StatusNotFound)
+			return
+		}
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, report, http.StatusOK)
+}
+
+func (h *Handler) ClaimReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_report_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.service.ClaimReport(r.Context(), reportID, user.ID)
+	if err != nil {
+		switch err {
+		case ErrReportNotFound:
+			respondError(w, "report_not_found", http.StatusNotFound)
+		case ErrReportNotOpen:
+			respondError(w, err.Error(), http.StatusConflict)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, report, http.StatusOK)
+}
+
+func (h *Handler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_report_id", http.StatusBadRequest)
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	report, err := h.service.ResolveReport(r.Context(), reportID, user, req)
+	if err != nil {
+		switch err {
+		case ErrReportNotFound:
+			respondError(w, "report_not_found", http.StatusNotFound)
+		case ErrInvalidResolution:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		case ErrNotClaimant, channels.ErrNotChannelOwner:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrReportResolved, ErrReportNotOpen:
+			respondError(w, err.Error(), http.StatusConflict)
+		case users.ErrUserNotFound, messages.ErrMessageNotFound, channels.ErrChannelNotFound:
+			respondError(w, err.Error(), http.StatusNotFound)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, report, http.StatusOK)
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
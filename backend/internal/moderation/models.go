@@ -0,0 +1,97 @@
+package moderation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportStatus tracks a report through the moderation queue
+type ReportStatus string
+
+const (
+	ReportStatusOpen     ReportStatus = "open"
+	ReportStatusClaimed  ReportStatus = "claimed"
+	ReportStatusResolved ReportStatus = "resolved"
+)
+
+// ResolutionAction is what a moderator decided to do about a report
+type ResolutionAction string
+
+const (
+	ActionDismiss       ResolutionAction = "dismiss"
+	ActionDeleteMessage ResolutionAction = "delete_message"
+	ActionRemoveMember  ResolutionAction = "remove_member"
+	ActionSuspendUser   ResolutionAction = "suspend_user"
+)
+
+func (a ResolutionAction) valid() bool {
+	switch a {
+	case ActionDismiss, ActionDeleteMessage, ActionRemoveMember, ActionSuspendUser:
+		return true
+	}
+	return false
+}
+
+const (
+	// ClaimSLA is how long a report may wait before a moderator picks it up
+	ClaimSLA = 4 * time.Hour
+	// ResolveSLA is how long a report may stay unresolved after it was filed
+	ResolveSLA = 24 * time.Hour
+
+	// MaxPlaintextSize caps the decrypted content a reporter can attach
+	MaxPlaintextSize = 64 * 1024
+)
+
+// Report is a member's complaint about a message.
+// Messages are end-to-end encrypted, so the reporter attaches the plaintext
+// they saw; moderators review that attestation, not the stored ciphertext.
+type Report struct {
+	ID             uuid.UUID    `json:"id" bson:"_id"`
+	MessageID      uuid.UUID    `json:"message_id" bson:"message_id"`
+	ChannelID      uuid.UUID    `json:"channel_id" bson:"channel_id"`
+	ReporterID     uuid.UUID    `json:"reporter_id" bson:"reporter_id"`
+	ReportedUserID uuid.UUID    `json:"reported_user_id" bson:"reported_user_id"`
+	Reason         string       `json:"reason" bson:"reason"`
+	Plaintext      string       `json:"plaintext" bson:"plaintext"`
+	Status         ReportStatus `json:"status" bson:"status"`
+
+	// SLA tracking
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+	ClaimDueAt   time.Time `json:"claim_due_at" bson:"claim_due_at"`
+	ResolveDueAt time.Time `json:"resolve_due_at" bson:"resolve_due_at"`
+	SLABreached  bool      `json:"sla_breached" bson:"-"`
+
+	ClaimedBy *uuid.UUID `json:"claimed_by,omitempty" bson:"claimed_by,omitempty"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty" bson:"claimed_at,omitempty"`
+
+	Resolution     ResolutionAction `json:"resolution,omitempty" bson:"resolution,omitempty"`
+	ResolutionNote string           `json:"resolution_note,omitempty" bson:"resolution_note,omitempty"`
+	ResolvedBy     *uuid.UUID       `json:"resolved_by,omitempty" bson:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time       `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+}
+
+// CreateReportRequest is the payload for reporting a message
+type CreateReportRequest struct {
+	Reason    string `json:"reason"`
+	Plaintext string `json:"plaintext"` // Decrypted content as seen by the reporter
+}
+
+// ResolveReportRequest is the payload for closing a report
+type ResolveReportRequest struct {
+	Action ResolutionAction `json:"action"`
+	Note   string           `json:"note"`
+}
+
+// checkSLA flags reports that missed their claim or resolve deadline
+func (r *Report) checkSLA(now time.Time) {
+	switch r.Status {
+	case ReportStatusOpen:
+		r.SLABreached = now.After(r.ClaimDueAt) || now.After(r.ResolveDueAt)
+	case ReportStatusClaimed:
+		r.SLABreached = (r.ClaimedAt != nil && r.ClaimedAt.After(r.ClaimDueAt)) || now.After(r.ResolveDueAt)
+	case ReportStatusResolved:
+		r.SLABreached = (r.ClaimedAt != nil && r.ClaimedAt.After(r.ClaimDueAt)) ||
+			(r.ResolvedAt != nil && r.ResolvedAt.After(r.ResolveDueAt))
+	}
+}
@@ -0,0 +1,122 @@
+package moderation
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReportRepo interface {
+	Create(ctx context.Context, r *Report) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Report, error)
+	Exists(ctx context.Context, messageID, reporterID uuid.UUID) (bool, error)
+	List(ctx context.Context, status ReportStatus, limit, offset int) ([]*Report, error)
+	Claim(ctx context.Context, id, moderatorID uuid.UUID) error
+	Resolve(ctx context.Context, id, moderatorID uuid.UUID, action ResolutionAction, note string) error
+}
+
+type mongoReportRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoReportRepo(db *mongo.Database) ReportRepo {
+	return &mongoReportRepo{
+		collection: db.Collection("message_reports"),
+	}
+}
+
+func (r *mongoReportRepo) Create(ctx context.Context, report *Report) error {
+	report.ID = uuid.New()
+	report.Status = ReportStatusOpen
+	report.CreatedAt = time.Now()
+	report.ClaimDueAt = report.CreatedAt.Add(ClaimSLA)
+	report.ResolveDueAt = report.CreatedAt.Add(ResolveSLA)
+
+	_, err := r.collection.InsertOne(ctx, report)
+	return err
+}
+
+func (r *mongoReportRepo) GetByID(ctx context.Context, id uuid.UUID) (*Report, error) {
+	var report Report
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&report)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrReportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (r *mongoReportRepo) Exists(ctx context.Context, messageID, reporterID uuid.UUID) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"message_id": messageID, "reporter_id": reporterID})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *mongoReportRepo) List(ctx context.Context, status ReportStatus, limit, offset int) ([]*Report, error) {
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	// Oldest deadline first so the queue is worked in SLA order
+	opts := options.Find().
+		SetSort(bson.D{{Key: "resolve_due_at", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reports []*Report
+	if err := cursor.All(ctx, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func (r *mongoReportRepo) Claim(ctx context.Context, id, moderatorID uuid.UUID) error {
+	filter := bson.M{"_id": id, "status": ReportStatusOpen}
+	update := bson.M{"$set": bson.M{
+		"status":     ReportStatusClaimed,
+		"claimed_by": moderatorID,
+		"claimed_at": time.Now(),
+	}}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrReportNotOpen
+	}
+	return nil
+}
+
+func (r *mongoReportRepo) Resolve(ctx context.Context, id, moderatorID uuid.UUID, action ResolutionAction, note string) error {
+	filter := bson.M{"_id": id, "status": bson.M{"$ne": ReportStatusResolved}}
+	update := bson.M{"$set": bson.M{
+		"status":          ReportStatusResolved,
+		"resolution":      action,
+		"resolution_note": note,
+		"resolved_by":     moderatorID,
+		"resolved_at":     time.Now(),
+	}}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrReportResolved
+	}
+	return nil
+}
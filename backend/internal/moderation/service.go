@@ -0,0 +1,256 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"telegraph/internal/acl"
+	"telegraph/internal/audit"
+	"telegraph/internal/channels"
+	"telegraph/internal/messages"
+	"telegraph/internal/users"
+
+	"github.com/google/uuid"
+)
+
+type ModerationService interface {
+	ReportMessage(ctx context.Context, messageID, reporterID uuid.UUID, req CreateReportRequest) (*Report, error)
+	ListReports(ctx context.Context, status ReportStatus, limit, offset int) ([]*Report, error)
+	GetReport(ctx context.Context, reportID uuid.UUID) (*Report, error)
+	ClaimReport(ctx context.Context, reportID, moderatorID uuid.UUID) (*Report, error)
+	ResolveReport(ctx context.Context, reportID uuid.UUID, moderator *users.User, req ResolveReportRequest) (*Report, error)
+}
+
+// SessionRevoker signs a user out everywhere and drops their live connections
+type SessionRevoker interface {
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+type moderationService struct {
+	repo        ReportRepo
+	messageRepo messages.MessageRepo
+	messageSvc  messages.MessageService
+	channelRepo channels.ChannelRepo
+	channelSvc  channels.ChannelService
+	userSvc     users.UserService
+	sessions    SessionRevoker
+	audit       *audit.Logger
+}
+
+func NewModerationService(
+	repo ReportRepo,
+	messageRepo messages.MessageRepo,
+	messageSvc messages.MessageService,
+	channelRepo channels.ChannelRepo,
+	channelSvc channels.ChannelService,
+	userSvc users.UserService,
+	sessions SessionRevoker,
+	audit *audit.Logger,
+) ModerationService {
+	return &moderationService{
+		repo:        repo,
+		messageRepo: messageRepo,
+		messageSvc:  messageSvc,
+		channelRepo: channelRepo,
+		channelSvc:  channelSvc,
+		userSvc:     userSvc,
+		sessions:    sessions,
+		audit:       audit,
+	}
+}
+
+func (s *moderationService) ReportMessage(ctx context.Context, messageID, reporterID uuid.UUID, req CreateReportRequest) (*Report, error) {
+	if strings.TrimSpace(req.Plaintext) == "" {
+		return nil, ErrPlaintextRequired
+	}
+	if len(req.Plaintext) > MaxPlaintextSize {
+		return nil, ErrPlaintextTooLarge
+	}
+
+	message, err := s.messageRepo.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.Deleted {
+		return nil, messages.ErrMessageNotFound
+	}
+	if message.SenderID == reporterID {
+		return nil, ErrCannotReportOwn
+	}
+
+	// Only members who could actually read the message may report it
+	isMember, err := s.channelRepo.IsMember(ctx, message.ChannelID, reporterID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, messages.ErrNotChannelMember
+	}
+
+	exists, err := s.repo.Exists(ctx, messageID, reporterID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrAlreadyReported
+	}
+
+	report := &Report{
+		MessageID:      message.ID,
+		ChannelID:      message.ChannelID,
+		ReporterID:     reporterID,
+		ReportedUserID: message.SenderID,
+		Reason:         req.Reason,
+		Plaintext:      req.Plaintext,
+	}
+	if err := s.repo.Create(ctx, report); err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &reporterID,
+		Action:   audit.EventMessageReported,
+		Resource: messageID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Filed report %s against user %s", report.ID, report.ReportedUserID),
+	})
+
+	return report, nil
+}
+
+func (s *moderationService) ListReports(ctx context.Context, status ReportStatus, limit, offset int) ([]*Report, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	reports, err := s.repo.List(ctx, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, r := range reports {
+		r.checkSLA(now)
+	}
+	return reports, nil
+}
+
+func (s *moderationService) GetReport(ctx context.Context, reportID uuid.UUID) (*Report, error) {
+	report, err := s.repo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	report.checkSLA(time.Now())
+	return report, nil
+}
+
+func (s *moderationService) ClaimReport(ctx context.Context, reportID, moderatorID uuid.UUID) (*Report, error) {
+	if err := s.repo.Claim(ctx, reportID, moderatorID); err != nil {
+		if err == ErrReportNotOpen {
+			// Distinguish a missing report from one someone else already took
+			if _, getErr := s.repo.GetByID(ctx, reportID); getErr != nil {
+				return nil, getErr
+			}
+		}
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &moderatorID,
+		Action:   audit.EventReportClaimed,
+		Resource: reportID.String(),
+		Result:   "success",
+	})
+
+	return s.GetReport(ctx, reportID)
+}
+
+func (s *moderationService) ResolveReport(ctx context.Context, reportID uuid.UUID, moderator *users.User, req ResolveReportRequest) (*Report, error) {
+	if !req.Action.valid() {
+		return nil, ErrInvalidResolution
+	}
+
+	report, err := s.repo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch report.Status {
+	case ReportStatusResolved:
+		return nil, ErrReportResolved
+	case ReportStatusOpen:
+		// Resolving straight from the queue implicitly claims the report
+		if _, err := s.ClaimReport(ctx, reportID, moderator.ID); err != nil {
+			return nil, err
+		}
+	case ReportStatusClaimed:
+		if report.ClaimedBy != nil && *report.ClaimedBy != moderator.ID && !acl.HasRoleOrHigher(moderator.Role, acl.RoleAdmin) {
+			return nil, ErrNotClaimant
+		}
+	}
+
+	if err := s.applyResolution(ctx, report, moderator, req.Action); err != nil {
+		s.audit.Log(ctx, audit.AuditLog{
+			UserID:   &moderator.ID,
+			Action:   audit.EventReportResolved,
+			Resource: reportID.String(),
+			Result:   "failure",
+			Details:  fmt.Sprintf("Action %s failed: %v", req.Action, err),
+		})
+		return nil, err
+	}
+
+	if err := s.repo.Resolve(ctx, reportID, moderator.ID, req.Action, req.Note); err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &moderator.ID,
+		Action:   audit.EventReportResolved,
+		Resource: reportID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Resolved with %s against user %s", req.Action, report.ReportedUserID),
+	})
+
+	return s.GetReport(ctx, reportID)
+}
+
+// applyResolution carries out the moderator's decision
+func (s *moderationService) applyResolution(ctx context.Context, report *Report, moderator *users.User, action ResolutionAction) error {
+	switch action {
+	case ActionDismiss:
+		return nil
+
+	case ActionDeleteMessage:
+		return s.messageSvc.DeleteMessage(ctx, report.MessageID, moderator.ID, moderator.Role)
+
+	case ActionRemoveMember:
+		return s.channelSvc.RemoveMember(ctx, report.ChannelID, moderator.ID, report.ReportedUserID)
+
+	case ActionSuspendUser:
+		if err := s.userSvc.SuspendUser(ctx, report.ReportedUserID); err != nil {
+			return err
+		}
+		// Suspension stops new requests; revoking the sessions also ends
+		// refresh tokens and closes the user's open sockets
+		if err := s.sessions.RevokeAllSessions(ctx, report.ReportedUserID); err != nil {
+			return err
+		}
+		s.audit.Log(ctx, audit.AuditLog{
+			UserID:   &moderator.ID,
+			Action:   audit.EventUserSuspended,
+			Resource: report.ReportedUserID.String(),
+			Result:   "success",
+			Details:  fmt.Sprintf("Suspended via report %s", report.ID),
+		})
+		return nil
+
+	default:
+		return ErrInvalidResolution
+	}
+}
@@ -0,0 +1,193 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepEvery controls how often stale keys are dropped from memory
+const sweepEvery = 1024
+
+type entry struct {
+	events []time.Time
+	window time.Duration
+}
+
+// Limiter is an in-memory sliding-window rate limiter keyed by arbitrary strings
+type Limiter struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	records int
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		entries: make(map[string]*entry),
+	}
+}
+
+// RetryAfter reports how long key must wait before it may act again when at
+// most limit events are allowed per window. Zero means the action is allowed now.
+func (l *Limiter) RetryAfter(key string, limit int, window time.Duration) time.Duration {
+	if limit <= 0 || window <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+
+	now := time.Now()
+	events := e.within(now, window)
+	if len(events) < limit {
+		return 0
+	}
+
+	// The oldest event inside the window has to expire first
+	oldest := events[len(events)-limit]
+	return oldest.Add(window).Sub(now)
+}
+
+// Record registers an event for key. window is how long the event stays relevant.
+func (l *Limiter) Record(key string, window time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &entry{}
+		l.entries[key] = e
+	}
+	if window > e.window {
+		e.window = window
+	}
+	e.events = append(e.events, time.Now())
+
+	l.records++
+	if l.records%sweepEvery == 0 {
+		l.sweep()
+	}
+}
+
+// Tier allows at most Limit events per Window
+type Tier struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Reserve checks key against every tier and, if all of them allow it,
+// records an event in the same locked step so concurrent callers can't all
+// slip under the limit. It returns the event's time, which Release takes to
+// give the slot back, or how long to wait when the key is limited.
+func (l *Limiter) Reserve(key string, tiers ...Tier) (time.Time, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var longest, wait time.Duration
+	e, ok := l.entries[key]
+	for _, t := range tiers {
+		if t.Limit <= 0 || t.Window <= 0 {
+			continue
+		}
+		if t.Window > longest {
+			longest = t.Window
+		}
+		if !ok {
+			continue
+		}
+		events := e.within(now, t.Window)
+		if len(events) < t.Limit {
+			continue
+		}
+		// The oldest event inside the window has to expire first
+		if w := events[len(events)-t.Limit].Add(t.Window).Sub(now); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return time.Time{}, wait
+	}
+
+	if !ok {
+		e = &entry{}
+		l.entries[key] = e
+	}
+	if longest > e.window {
+		e.window = longest
+	}
+	e.events = append(e.events, now)
+
+	l.records++
+	if l.records%sweepEvery == 0 {
+		l.sweep()
+	}
+	return now, 0
+}
+
+// Release removes the event Reserve recorded at the given time
+func (l *Limiter) Release(key string, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	for i, t := range e.events {
+		if t.Equal(at) {
+			e.events = append(e.events[:i], e.events[i+1:]...)
+			return
+		}
+	}
+}
+
+// Allow checks and records an event in one locked step
+func (l *Limiter) Allow(key string, limit int, window time.Duration) (bool, time.Duration) {
+	if _, wait := l.Reserve(key, Tier{Limit: limit, Window: window}); wait > 0 {
+		return false, wait
+	}
+	return true, 0
+}
+
+// Reset forgets every event recorded for key
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// sweep drops keys whose events have all expired. Caller must hold l.mu.
+func (l *Limiter) sweep() {
+	now := time.Now()
+	for key, e := range l.entries {
+		e.prune(now, e.window)
+		if len(e.events) == 0 {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// within returns the events newer than window without dropping older ones,
+// which a longer tier of the same key may still need
+func (e *entry) within(now time.Time, window time.Duration) []time.Time {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(e.events) && !e.events[i].After(cutoff) {
+		i++
+	}
+	return e.events[i:]
+}
+
+func (e *entry) prune(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(e.events) && !e.events[i].After(cutoff) {
+		i++
+	}
+	e.events = e.events[i:]
+}
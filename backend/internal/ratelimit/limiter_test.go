@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	l := NewLimiter()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("key", 3, time.Minute); !ok {
+			t.Fatalf("event %d should be allowed", i+1)
+		}
+	}
+
+	ok, wait := l.Allow("key", 3, time.Minute)
+	if ok {
+		t.Fatal("fourth event should be rejected")
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Errorf("unexpected retry-after %v", wait)
+	}
+
+	if ok, _ := l.Allow("other", 3, time.Minute); !ok {
+		t.Error("keys must be limited independently")
+	}
+}
+
+func TestLimiter_AllowConcurrent(t *testing.T) {
+	l := NewLimiter()
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := l.Allow("key", 5, time.Minute); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := allowed.Load(); n != 5 {
+		t.Fatalf("%d concurrent events allowed, want 5", n)
+	}
+}
+
+func TestLimiter_WindowExpiry(t *testing.T) {
+	l := NewLimiter()
+
+	if ok, _ := l.Allow("key", 1, 20*time.Millisecond); !ok {
+		t.Fatal("first event should be allowed")
+	}
+	if ok, _ := l.Allow("key", 1, 20*time.Millisecond); ok {
+		t.Fatal("second event inside the window should be rejected")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if ok, _ := l.Allow("key", 1, 20*time.Millisecond); !ok {
+		t.Error("event after the window should be allowed")
+	}
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	l := NewLimiter()
+	l.Record("key", time.Minute)
+
+	if wait := l.RetryAfter("key", 1, time.Minute); wait == 0 {
+		t.Fatal("expected key to be limited")
+	}
+
+	l.Reset("key")
+	if wait := l.RetryAfter("key", 1, time.Minute); wait != 0 {
+		t.Errorf("expected no wait after reset, got %v", wait)
+	}
+}
+
+func TestLimiter_ReserveAndRelease(t *testing.T) {
+	l := NewLimiter()
+	tiers := []Tier{{Limit: 2, Window: time.Minute}, {Limit: 3, Window: time.Hour}}
+
+	first, wait := l.Reserve("key", tiers...)
+	if wait != 0 {
+		t.Fatalf("first reservation waited %v", wait)
+	}
+	if _, wait := l.Reserve("key", tiers...); wait != 0 {
+		t.Fatalf("second reservation waited %v", wait)
+	}
+	if _, wait := l.Reserve("key", tiers...); wait <= 0 {
+		t.Fatal("third reservation inside the first tier should be limited")
+	}
+
+	l.Release("key", first)
+	if _, wait := l.Reserve("key", tiers...); wait != 0 {
+		t.Fatalf("released slot was not given back, waited %v", wait)
+	}
+}
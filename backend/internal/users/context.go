@@ -6,7 +6,11 @@ import (
 
 type ctxKey string
 
-const userIDKey ctxKey = "user_id"
+const (
+	userIDKey    ctxKey = "user_id"
+	deviceIDKey  ctxKey = "device_id"
+	sessionIDKey ctxKey = "session_id"
+)
 
 // ContextWithUserID stores the authenticated user id inside the context.
 func ContextWithUserID(ctx context.Context, userID string) context.Context {
@@ -22,3 +26,25 @@ func UserIDFromContext(ctx context.Context) string {
 	id, _ := val.(string)
 	return id
 }
+
+// ContextWithDeviceID stores the device the request was made from.
+func ContextWithDeviceID(ctx context.Context, deviceID string) context.Context {
+	return context.WithValue(ctx, deviceIDKey, deviceID)
+}
+
+// DeviceIDFromContext extracts the authenticated device id, or "" if unknown.
+func DeviceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(deviceIDKey).(string)
+	return id
+}
+
+// ContextWithSessionID stores the login session the access token belongs to.
+func ContextWithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionIDKey, sessionID)
+}
+
+// SessionIDFromContext extracts the session id, or "" for tokens without one.
+func SessionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey).(string)
+	return id
+}
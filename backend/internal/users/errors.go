@@ -6,4 +6,13 @@ var (
 	ErrInvalidCredentials = errors.New("invalid_credentials")
 	ErrEmailExists        = errors.New("email_already_exists")
 	ErrUserNotFound       = errors.New("user_not_found")
+	ErrAccountSuspended   = errors.New("account_suspended")
+	ErrPasswordTooShort   = errors.New("password_too_short")
+
+	ErrInvalidVerificationCode = errors.New("invalid_verification_code")
+	ErrEmailAlreadyVerified    = errors.New("email_already_verified")
+	ErrVerificationRateLimited = errors.New("too_many_verification_requests")
+
+	ErrNoSigningKey = errors.New("key ring has no private key to sign with")
+	ErrUnknownKey   = errors.New("unknown signing key")
 )
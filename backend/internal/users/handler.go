@@ -2,6 +2,7 @@ package users
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 	
@@ -10,17 +11,20 @@ import (
 )
 
 type Handler struct {
-	svc UserService
-	jwt *JWTManager
+	svc    UserService
+	jwt    *JWTManager
+	verify VerificationService
 }
 
-func NewHandler(svc UserService, jwt *JWTManager) *Handler {
-	return &Handler{svc: svc, jwt: jwt}
+func NewHandler(svc UserService, jwt *JWTManager, verify VerificationService) *Handler {
+	return &Handler{svc: svc, jwt: jwt, verify: verify}
 }
 
 func (h *Handler) Routes() http.Handler {
 	r := chi.NewRouter()
 	r.Post("/register", h.Register)
+	r.Post("/verify-email", h.VerifyEmail)
+	r.Post("/verify-email/resend", h.ResendVerification)
 	r.Get("/search", h.SearchUsers)
 	return r
 }
@@ -49,13 +53,72 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account exists either way; the user can ask for another code
+	if err := h.verify.SendCode(r.Context(), u); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", u.ID, err)
+	}
+
 	json.NewEncoder(w).Encode(map[string]any{
-		"id":       u.ID,
-		"username": u.Username,
-		"email":    u.Email,
+		"id":                         u.ID,
+		"username":                   u.Username,
+		"email":                      u.Email,
+		"email_verification_pending": u.EmailVerificationPending,
 	})
 }
 
+// VerifyEmail confirms a registration with the emailed code
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad_request", 400)
+		return
+	}
+
+	switch err := h.verify.Verify(r.Context(), body.Email, body.Code); err {
+	case nil:
+		w.WriteHeader(204)
+	case ErrInvalidVerificationCode:
+		http.Error(w, err.Error(), 400)
+	case ErrVerificationRateLimited:
+		http.Error(w, err.Error(), 429)
+	default:
+		http.Error(w, "verification_failed", 500)
+	}
+}
+
+// ResendVerification emails a new code. The response does not reveal
+// whether the address is registered.
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad_request", 400)
+		return
+	}
+
+	err := h.verify.Resend(r.Context(), body.Email)
+	if err == ErrVerificationRateLimited {
+		http.Error(w, err.Error(), 429)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to resend verification email: %v", err)
+	}
+	w.WriteHeader(202)
+}
+
+// JWKS publishes the public keys access tokens are signed with so other
+// services can verify them
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.jwt.JWKS())
+}
+
 // Me
 func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
 	userID := UserIDFromContext(r.Context())
@@ -8,37 +8,118 @@ import (
 )
 
 type JWTManager struct {
-	secret []byte
-	exp    time.Duration
+	keys *KeyRing
+	exp  time.Duration
 }
 
+// NewJWTManager signs HS256 tokens with a shared secret
 func NewJWTManager(secret string, exp time.Duration) *JWTManager {
+	return NewJWTManagerWithKeys(NewHMACKeyRing(secret), exp)
+}
+
+// NewJWTManagerWithKeys signs with the key ring's active key and accepts
+// tokens from any key in the ring
+func NewJWTManagerWithKeys(keys *KeyRing, exp time.Duration) *JWTManager {
 	return &JWTManager{
-		secret: []byte(secret),
-		exp:    exp,
+		keys: keys,
+		exp:  exp,
 	}
 }
 
+// JWKS returns the public keys other services verify tokens with
+func (j *JWTManager) JWKS() JWKSet {
+	return j.keys.JWKS()
+}
+
+// Claims are the access token fields the API reads
+type Claims struct {
+	UserID    string
+	Role      string
+	DeviceID  string // Empty for tokens not tied to a device
+	SessionID string // Refresh token family the token was issued from
+
+	// Authentication methods used at login (RFC 8176), e.g. "pwd", "otp"
+	AMR []string
+	// MFA is set when login was completed with a second factor
+	MFA bool
+}
+
 func (j *JWTManager) Generate(userID, role string) (string, error) {
+	return j.GenerateClaims(Claims{UserID: userID, Role: role})
+}
+
+// GenerateClaims signs an access token for the given claims
+func (j *JWTManager) GenerateClaims(c Claims) (string, error) {
 
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
+		"user_id": c.UserID,
+		"role":    c.Role,
 		"exp":     time.Now().Add(j.exp).Unix(),
 	}
+	if c.DeviceID != "" {
+		claims["device_id"] = c.DeviceID
+	}
+	if c.SessionID != "" {
+		claims["sid"] = c.SessionID
+	}
+	if len(c.AMR) > 0 {
+		claims["amr"] = c.AMR
+	}
+	if c.MFA {
+		claims["mfa"] = true
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secret)
+	key := j.keys.Active()
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.private)
 }
 
 func (j *JWTManager) Verify(tokenStr string) (string, error) {
+	c, err := j.Parse(tokenStr)
+	if err != nil {
+		return "", err
+	}
+	return c.UserID, nil
+}
+
+// Parse verifies an access token and returns its claims
+func (j *JWTManager) Parse(tokenStr string) (*Claims, error) {
 	tok, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-		return j.secret, nil
-	})
+		kid, _ := t.Header["kid"].(string)
+		key, err := j.keys.Key(kid)
+		if err != nil {
+			return nil, err
+		}
+		// The key decides the algorithm, never the token
+		if t.Method.Alg() != key.Algorithm {
+			return nil, errors.New("unexpected signing algorithm")
+		}
+		return key.public, nil
+	}, jwt.WithValidMethods(j.keys.algorithms()))
 	if err != nil || !tok.Valid {
-		return "", errors.New("invalid_token")
+		return nil, errors.New("invalid_token")
 	}
 
 	claims := tok.Claims.(jwt.MapClaims)
-	return claims["user_id"].(string), nil
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, errors.New("invalid_token")
+	}
+	role, _ := claims["role"].(string)
+	deviceID, _ := claims["device_id"].(string)
+	sessionID, _ := claims["sid"].(string)
+	mfa, _ := claims["mfa"].(bool)
+
+	var amr []string
+	if methods, ok := claims["amr"].([]any); ok {
+		for _, m := range methods {
+			if s, ok := m.(string); ok {
+				amr = append(amr, s)
+			}
+		}
+	}
+	return &Claims{UserID: userID, Role: role, DeviceID: deviceID, SessionID: sessionID, AMR: amr, MFA: mfa}, nil
 }
@@ -0,0 +1,206 @@
+package users
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeEd25519Key(t *testing.T, dir, name string) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, name, "PRIVATE KEY", der)
+	return priv
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "2026-01-01.pem")
+
+	oldRing, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := NewJWTManagerWithKeys(oldRing, time.Hour).GenerateClaims(Claims{UserID: "u1", Role: "member"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Adding a newer key makes it the signing key; the old one still verifies
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "2026-06-01.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	ring, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ring.Active().ID != "2026-06-01" || ring.Active().Algorithm != AlgRS256 {
+		t.Fatalf("active key = %s/%s", ring.Active().ID, ring.Active().Algorithm)
+	}
+	mgr := NewJWTManagerWithKeys(ring, time.Hour)
+
+	newToken, err := mgr.GenerateClaims(Claims{UserID: "u2", Role: "admin", SessionID: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Header["kid"] != "2026-06-01" {
+		t.Fatalf("kid header = %v", tok.Header["kid"])
+	}
+
+	for token, want := range map[string]string{oldToken: "u1", newToken: "u2"} {
+		c, err := mgr.Parse(token)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if c.UserID != want {
+			t.Fatalf("user = %s, want %s", c.UserID, want)
+		}
+	}
+
+	jwks := mgr.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("jwks has %d keys", len(jwks.Keys))
+	}
+	if jwks.Keys[0].KeyType != "OKP" || jwks.Keys[0].Curve != "Ed25519" || jwks.Keys[1].KeyType != "RSA" || jwks.Keys[1].E != "AQAB" {
+		t.Fatalf("unexpected jwks %+v", jwks.Keys)
+	}
+
+	// Once the old key is removed its tokens stop verifying
+	os.Remove(filepath.Join(dir, "2026-01-01.pem"))
+	ring, err = LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewJWTManagerWithKeys(ring, time.Hour).Parse(oldToken); err == nil {
+		t.Fatal("token from removed key accepted")
+	}
+}
+
+func TestParseRejectsAlgorithmSwitch(t *testing.T) {
+	dir := t.TempDir()
+	priv := writeEd25519Key(t, dir, "k1.pem")
+	ring, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr := NewJWTManagerWithKeys(ring, time.Hour)
+
+	claims := jwt.MapClaims{"user_id": "u1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	// HS256 keyed with the public key, the classic confusion attack
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "k1"
+	s, err := forged.SignedString([]byte(priv.Public().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Parse(s); err == nil {
+		t.Fatal("HS256 token accepted by EdDSA key ring")
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+	unsigned.Header["kid"] = "k1"
+	s, err = unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Parse(s); err == nil {
+		t.Fatal("unsigned token accepted")
+	}
+
+	// Tokens without a kid only work with a shared secret ring
+	legacy, err := NewJWTManager("secret", time.Hour).GenerateClaims(Claims{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Parse(legacy); err == nil {
+		t.Fatal("token without kid accepted")
+	}
+}
+
+func TestLoadKeyRingNeedsPrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	priv := writeEd25519Key(t, dir, "signing.pem")
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "verify-only.pem", "PUBLIC KEY", der)
+
+	if _, err := LoadKeyRing(dir, "verify-only"); err != ErrNoSigningKey {
+		t.Fatalf("err = %v, want ErrNoSigningKey", err)
+	}
+	if _, err := LoadKeyRing(t.TempDir(), ""); err != ErrNoSigningKey {
+		t.Fatalf("empty dir err = %v, want ErrNoSigningKey", err)
+	}
+}
+
+func TestKeyRingAcceptsHMACSecretForVerification(t *testing.T) {
+	legacy, err := NewJWTManager("old-secret", time.Hour).GenerateClaims(Claims{UserID: "u1", Role: "member"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "k1.pem")
+	ring, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring.AcceptHMACSecret("old-secret")
+	mgr := NewJWTManagerWithKeys(ring, time.Hour)
+
+	if c, err := mgr.Parse(legacy); err != nil || c.UserID != "u1" {
+		t.Fatalf("legacy token: %v, %v", c, err)
+	}
+
+	fresh, err := mgr.GenerateClaims(Claims{UserID: "u2", Role: "member"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, _, err := jwt.NewParser().ParseUnverified(fresh, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Method.Alg() != AlgEdDSA || tok.Header["kid"] != "k1" {
+		t.Fatalf("new token signed with %s kid %v, want the Ed25519 key", tok.Method.Alg(), tok.Header["kid"])
+	}
+
+	forged, err := NewJWTManager("wrong-secret", time.Hour).GenerateClaims(Claims{UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Parse(forged); err == nil {
+		t.Fatal("token signed with another secret accepted")
+	}
+}
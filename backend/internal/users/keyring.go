@@ -0,0 +1,225 @@
+package users
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms accepted in a key ring
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+	AlgHS256 = "HS256"
+)
+
+// minRSABits rejects RSA keys too small to sign tokens with
+const minRSABits = 2048
+
+// SigningKey is one key in a KeyRing. Keys loaded from a public key file
+// can only verify; they let tokens signed by a retired or not yet active
+// key stay valid during a rotation.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+
+	private any // ed25519.PrivateKey, *rsa.PrivateKey or []byte for HS256
+	public  any // ed25519.PublicKey, *rsa.PublicKey or []byte for HS256
+}
+
+// CanSign reports whether the key holds private key material
+func (k *SigningKey) CanSign() bool {
+	return k.private != nil
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	}
+	return jwt.SigningMethodHS256
+}
+
+// KeyRing holds the key new tokens are signed with and every key tokens
+// are still accepted from, looked up by the kid header
+type KeyRing struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewHMACKeyRing wraps a shared secret. Tokens carry no kid and the ring
+// publishes no JWKS, since the secret can't be shared.
+func NewHMACKeyRing(secret string) *KeyRing {
+	k := &SigningKey{Algorithm: AlgHS256, private: []byte(secret), public: []byte(secret)}
+	return &KeyRing{active: k, keys: map[string]*SigningKey{"": k}}
+}
+
+// AcceptHMACSecret lets the ring verify tokens signed with a shared secret
+// and no kid, so tokens issued before moving to asymmetric keys stay valid
+// until they expire. The secret never signs.
+func (r *KeyRing) AcceptHMACSecret(secret string) {
+	r.keys[""] = &SigningKey{Algorithm: AlgHS256, public: []byte(secret)}
+}
+
+// LoadKeyRing reads every *.pem file in dir. The file name without its
+// extension is the key's kid. Private keys (PKCS#8, or PKCS#1 for RSA) can
+// sign; public keys (PKIX) only verify. New tokens are signed with
+// activeKID, or with the private key whose kid sorts last when it is empty,
+// so date-named keys such as 2026-10-18.pem rotate by adding a file.
+func LoadKeyRing(dir, activeKID string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ring := &KeyRing{keys: map[string]*SigningKey{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		key, err := ParseSigningKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", path, err)
+		}
+		ring.keys[kid] = key
+		if key.CanSign() && activeKID == "" {
+			ring.active = key
+		}
+	}
+
+	if activeKID != "" {
+		ring.active = ring.keys[activeKID]
+	}
+	if ring.active == nil || !ring.active.CanSign() {
+		return nil, ErrNoSigningKey
+	}
+	return ring, nil
+}
+
+// ParseSigningKey reads an Ed25519 or RSA key from a PEM block
+func ParseSigningKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := &SigningKey{ID: kid}
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.Algorithm, k.private, k.public = AlgEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.Algorithm, k.public = AlgEdDSA, key
+	case *rsa.PrivateKey:
+		k.Algorithm, k.private, k.public = AlgRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.Algorithm, k.public = AlgRS256, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	if pub, ok := k.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA key must be at least %d bits", minRSABits)
+	}
+	return k, nil
+}
+
+// Active returns the key new tokens are signed with
+func (r *KeyRing) Active() *SigningKey {
+	return r.active
+}
+
+// Key looks up a verification key by kid
+func (r *KeyRing) Key(kid string) (*SigningKey, error) {
+	k, ok := r.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return k, nil
+}
+
+// algorithms lists the algorithms of every key, for pinning during parsing
+func (r *KeyRing) algorithms() []string {
+	seen := map[string]bool{}
+	var algs []string
+	for _, k := range r.keys {
+		if !seen[k.Algorithm] {
+			seen[k.Algorithm] = true
+			algs = append(algs, k.Algorithm)
+		}
+	}
+	return algs
+}
+
+// JWK is a public key in RFC 7517 form
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	Curve string `json:"crv,omitempty"` // OKP
+	X     string `json:"x,omitempty"`   // OKP
+	N     string `json:"n,omitempty"`   // RSA
+	E     string `json:"e,omitempty"`   // RSA
+}
+
+// JWKSet is the body of /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public half of every asymmetric key, sorted by kid.
+// Shared secrets are never published.
+func (r *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range r.keys {
+		jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+		switch pub := k.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
@@ -37,6 +37,67 @@ type User struct {
 	SecurityLabel  string                 `json:"security_label" bson:"security_label"` // MAC: "public", "internal", "confidential"
 	Attributes     map[string]interface{} `json:"attributes" bson:"attributes"`     // ABAC: custom attributes
 
+	// Bot accounts authenticate with a bot token instead of a password
+	Bot        bool       `json:"bot,omitempty" bson:"bot,omitempty"`
+	BotOwnerID *uuid.UUID `json:"bot_owner_id,omitempty" bson:"bot_owner_id,omitempty"`
+
+	// Moderation
+	Suspended   bool       `json:"suspended,omitempty" bson:"suspended,omitempty"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty" bson:"suspended_at,omitempty"`
+
+	// Accounts registered by email stay restricted until the address is confirmed.
+	// Accounts created before verification existed have neither field set.
+	EmailVerificationPending bool       `json:"email_verification_pending,omitempty" bson:"email_verification_pending,omitempty"`
+	EmailVerifiedAt          *time.Time `json:"email_verified_at,omitempty" bson:"email_verified_at,omitempty"`
+
+	// Second factors the user has enrolled (see the MFAMethod constants)
+	MFAMethods []string `json:"mfa_methods,omitempty" bson:"mfa_methods,omitempty"`
+
+	// Soft deletion (only used while a legal hold preserves the account)
+	Deleted   bool       `json:"-" bson:"deleted,omitempty"`
+	DeletedAt *time.Time `json:"-" bson:"deleted_at,omitempty"`
+
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
 }
+
+// AttrMFAEnabled is the ABAC attribute checked by acl.RequireMFA
+const AttrMFAEnabled = "mfa_enabled"
+
+// Second factor methods
+const (
+	MFAMethodEmail    = "email"
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+// MFAEnabled reports whether login requires a second factor
+func (u *User) MFAEnabled() bool {
+	enabled, _ := u.Attributes[AttrMFAEnabled].(bool)
+	return enabled
+}
+
+// EnabledMFAMethods lists the second factors the user can log in with.
+// Accounts flagged mfa_enabled before methods were tracked use email.
+func (u *User) EnabledMFAMethods() []string {
+	if len(u.MFAMethods) == 0 && u.MFAEnabled() {
+		return []string{MFAMethodEmail}
+	}
+	return u.MFAMethods
+}
+
+// EmailVerified reports whether the account may use restricted features
+// such as creating channels and appearing in search
+func (u *User) EmailVerified() bool {
+	return !u.EmailVerificationPending
+}
+
+// HasMFAMethod reports whether the given second factor is enabled
+func (u *User) HasMFAMethod(method string) bool {
+	for _, m := range u.EnabledMFAMethods() {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
@@ -16,9 +16,18 @@ type UserRepo interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*User, error)
 	Update(ctx context.Context, u *User) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	PurgeDeleted(ctx context.Context, id uuid.UUID) error
+	SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error
+	SetPasswordHash(ctx context.Context, id uuid.UUID, hash string) error
+	MarkEmailVerified(ctx context.Context, id uuid.UUID) error
 	Search(ctx context.Context, query string) ([]*User, error)
+	ListBots(ctx context.Context, ownerID *uuid.UUID) ([]*User, error)
 }
 
+// notDeleted hides accounts that are only kept around because of a legal hold
+var notDeleted = bson.M{"$ne": true}
+
 type mongoUserRepo struct {
 	collection *mongo.Collection
 }
@@ -40,7 +49,7 @@ func (r *mongoUserRepo) Create(ctx context.Context, u *User) error {
 
 func (r *mongoUserRepo) GetByEmail(ctx context.Context, email string) (*User, error) {
 	var user User
-	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	err := r.collection.FindOne(ctx, bson.M{"email": email, "deleted": notDeleted}).Decode(&user)
 	if err == mongo.ErrNoDocuments {
 		return nil, ErrUserNotFound
 	}
@@ -52,7 +61,7 @@ func (r *mongoUserRepo) GetByEmail(ctx context.Context, email string) (*User, er
 
 func (r *mongoUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	var user User
-	err := r.collection.FindOne(ctx, bson.M{"id": id}).Decode(&user)
+	err := r.collection.FindOne(ctx, bson.M{"id": id, "deleted": notDeleted}).Decode(&user)
 	if err == mongo.ErrNoDocuments {
 		return nil, ErrUserNotFound
 	}
@@ -76,6 +85,7 @@ func (r *mongoUserRepo) Update(ctx context.Context, u *User) error {
 			"account_type":   u.AccountType,
 			"security_label": u.SecurityLabel,
 			"attributes":     u.Attributes,
+			"mfa_methods":    u.MFAMethods,
 			"updated_at":     u.UpdatedAt,
 		},
 	}
@@ -89,13 +99,63 @@ func (r *mongoUserRepo) Delete(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+func (r *mongoUserRepo) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"deleted": true, "deleted_at": now, "updated_at": now}}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+// PurgeDeleted removes the account if it was only soft-deleted
+func (r *mongoUserRepo) PurgeDeleted(ctx context.Context, id uuid.UUID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"id": id, "deleted": true})
+	return err
+}
+
+func (r *mongoUserRepo) SetSuspended(ctx context.Context, id uuid.UUID, suspended bool) error {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"suspended": suspended, "updated_at": now}}
+	if suspended {
+		update["$set"].(bson.M)["suspended_at"] = now
+	} else {
+		update["$unset"] = bson.M{"suspended_at": ""}
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
+func (r *mongoUserRepo) SetPasswordHash(ctx context.Context, id uuid.UUID, hash string) error {
+	update := bson.M{"$set": bson.M{"password_hash": hash, "updated_at": time.Now()}}
+	res, err := r.collection.UpdateOne(ctx, bson.M{"id": id, "deleted": notDeleted}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepo) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	update := bson.M{
+		"$set":   bson.M{"email_verified_at": now, "updated_at": now},
+		"$unset": bson.M{"email_verification_pending": ""},
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"id": id}, update)
+	return err
+}
+
 func (r *mongoUserRepo) GetByEmailOrPhone(ctx context.Context, identifier string) (*User, error) {
 	var user User
 	// Try email first
-	filter := bson.M{"$or": []bson.M{
-		{"email": identifier},
-		{"phone": identifier},
-	}}
+	filter := bson.M{
+		"$or": []bson.M{
+			{"email": identifier},
+			{"phone": identifier},
+		},
+		"deleted": notDeleted,
+	}
 	
 	err := r.collection.FindOne(ctx, filter).Decode(&user)
 	if err == mongo.ErrNoDocuments {
@@ -114,6 +174,9 @@ func (r *mongoUserRepo) Search(ctx context.Context, query string) ([]*User, erro
 			{"email": bson.M{"$regex": query, "$options": "i"}},
 			{"phone": bson.M{"$regex": query, "$options": "i"}},
 		},
+		"deleted": notDeleted,
+		// Unverified accounts cannot be found until they confirm their email
+		"email_verification_pending": bson.M{"$ne": true},
 	}
 
 	cursor, err := r.collection.Find(ctx, filter)
@@ -130,3 +193,22 @@ func (r *mongoUserRepo) Search(ctx context.Context, query string) ([]*User, erro
 	return users, nil
 }
 
+// ListBots returns bot accounts owned by ownerID, or every bot when ownerID is nil
+func (r *mongoUserRepo) ListBots(ctx context.Context, ownerID *uuid.UUID) ([]*User, error) {
+	filter := bson.M{"bot": true, "deleted": notDeleted}
+	if ownerID != nil {
+		filter["bot_owner_id"] = *ownerID
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bots []*User
+	if err := cursor.All(ctx, &bots); err != nil {
+		return nil, err
+	}
+	return bots, nil
+}
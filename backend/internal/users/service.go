@@ -15,15 +15,30 @@ type UserService interface {
 	GetByEmailOrPhone(ctx context.Context, identifier string) (*User, error)
 	UpdateUser(ctx context.Context, u *User) error
 	DeleteUser(ctx context.Context, id uuid.UUID) error
+	SuspendUser(ctx context.Context, id uuid.UUID) error
 	SearchUsers(ctx context.Context, query string) ([]*User, error)
+	SetMFAMethod(ctx context.Context, id uuid.UUID, method string, enabled bool) error
+	SetPassword(ctx context.Context, id uuid.UUID, pw string) error
+}
+
+// MinPasswordLength is enforced whenever a password is changed
+const MinPasswordLength = 8
+
+// dummyPasswordHash is checked against when the email is unknown
+var dummyPasswordHash = HashPassword("telegraph-unknown-account")
+
+// HoldChecker reports whether a legal hold requires an account to be preserved
+type HoldChecker interface {
+	IsUserHeld(ctx context.Context, userID uuid.UUID) (bool, error)
 }
 
 type userService struct {
-	repo UserRepo
+	repo  UserRepo
+	holds HoldChecker
 }
 
-func NewUserService(repo UserRepo) UserService {
-	return &userService{repo: repo}
+func NewUserService(repo UserRepo, holds HoldChecker) UserService {
+	return &userService{repo: repo, holds: holds}
 }
 
 func (s *userService) Register(ctx context.Context, u *User, pw string) error {
@@ -40,6 +55,7 @@ func (s *userService) Register(ctx context.Context, u *User, pw string) error {
 	u.SecurityLabel = "public" // Default MAC label
 	u.AccountType = "basic"    // Default account type
 	u.Attributes = map[string]any{}
+	u.EmailVerificationPending = true
 
 	return s.repo.Create(ctx, u)
 }
@@ -49,6 +65,13 @@ func (s *userService) Login(ctx context.Context, email, pw string) (*User, error
 
 	u, err := s.repo.GetByEmail(ctx, email)
 	if err != nil {
+		// Spend as long as a wrong password would so unknown emails don't stand out
+		VerifyPassword(dummyPasswordHash, pw)
+		return nil, ErrInvalidCredentials
+	}
+
+	// Bots never log in with a password
+	if u.Bot {
 		return nil, ErrInvalidCredentials
 	}
 
@@ -56,6 +79,10 @@ func (s *userService) Login(ctx context.Context, email, pw string) (*User, error
 		return nil, ErrInvalidCredentials
 	}
 
+	if u.Suspended {
+		return nil, ErrAccountSuspended
+	}
+
 	return u, nil
 }
 
@@ -76,10 +103,59 @@ func (s *userService) UpdateUser(ctx context.Context, u *User) error {
 }
 
 func (s *userService) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	// Held accounts are hidden from every lookup but kept in storage
+	held, err := s.holds.IsUserHeld(ctx, id)
+	if err != nil {
+		return err
+	}
+	if held {
+		return s.repo.SoftDelete(ctx, id)
+	}
 	return s.repo.Delete(ctx, id)
 }
 
+func (s *userService) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.SetSuspended(ctx, id, true)
+}
+
+// SetPassword replaces a user's password. Callers are responsible for
+// revoking existing sessions.
+func (s *userService) SetPassword(ctx context.Context, id uuid.UUID, pw string) error {
+	if len(pw) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	return s.repo.SetPasswordHash(ctx, id, HashPassword(pw))
+}
+
 func (s *userService) SearchUsers(ctx context.Context, query string) ([]*User, error) {
 	return s.repo.Search(ctx, query)
 }
 
+// SetMFAMethod enables or disables one second factor. mfa_enabled is kept
+// in the ABAC attributes, set while any method remains, so acl.RequireMFA can see it.
+func (s *userService) SetMFAMethod(ctx context.Context, id uuid.UUID, method string, enabled bool) error {
+	u, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	methods := []string{}
+	for _, m := range u.EnabledMFAMethods() {
+		if m != method {
+			methods = append(methods, m)
+		}
+	}
+	if enabled {
+		methods = append(methods, method)
+	}
+	u.MFAMethods = methods
+
+	if u.Attributes == nil {
+		u.Attributes = map[string]any{}
+	}
+	u.Attributes[AttrMFAEnabled] = len(methods) > 0
+	return s.repo.Update(ctx, u)
+}
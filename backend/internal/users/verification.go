@@ -0,0 +1,242 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/ratelimit"
+)
+
+// Email verification limits
+const (
+	VerificationCodeTTL  = 24 * time.Hour
+	VerificationAttempts = 5 // wrong codes before a new one must be requested
+	ResendLimit          = 3
+	ResendWindow         = time.Hour
+	VerifyLimit          = 10
+	VerifyWindow         = time.Hour
+)
+
+// EmailVerification is the outstanding code for an unverified account.
+// Only a hash of the code is stored.
+type EmailVerification struct {
+	UserID    uuid.UUID `bson:"_id"`
+	CodeHash  string    `bson:"code_hash"`
+	Attempts  int       `bson:"attempts"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+type VerificationRepo interface {
+	Put(ctx context.Context, v *EmailVerification) error
+	UseAttempt(ctx context.Context, userID uuid.UUID, maxAttempts int) (*EmailVerification, error)
+	Consume(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error)
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+type mongoVerificationRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoVerificationRepo(db *mongo.Database) VerificationRepo {
+	return &mongoVerificationRepo{
+		collection: db.Collection("email_verifications"),
+	}
+}
+
+// Put replaces any earlier code for the user
+func (r *mongoVerificationRepo) Put(ctx context.Context, v *EmailVerification) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": v.UserID}, v, options.Replace().SetUpsert(true))
+	return err
+}
+
+// UseAttempt counts a guess against the user's code and returns it, as long
+// as the code has attempts left. Missing and used-up codes both report
+// ErrInvalidVerificationCode.
+func (r *mongoVerificationRepo) UseAttempt(ctx context.Context, userID uuid.UUID, maxAttempts int) (*EmailVerification, error) {
+	filter := bson.M{"_id": userID, "attempts": bson.M{"$lt": maxAttempts}}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var v EmailVerification
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvalidVerificationCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Consume deletes the code if it is still the one that was checked and
+// reports whether this call removed it
+func (r *mongoVerificationRepo) Consume(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID, "code_hash": codeHash})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount == 1, nil
+}
+
+func (r *mongoVerificationRepo) Delete(ctx context.Context, userID uuid.UUID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
+
+// EmailSender delivers verification codes
+type EmailSender interface {
+	Send(to, subject, body string) error
+}
+
+type VerificationService interface {
+	SendCode(ctx context.Context, u *User) error
+	Resend(ctx context.Context, email string) error
+	Verify(ctx context.Context, email, code string) error
+}
+
+type verificationService struct {
+	repo    VerificationRepo
+	users   UserRepo
+	email   EmailSender
+	audit   *audit.Logger
+	limiter *ratelimit.Limiter
+}
+
+func NewVerificationService(repo VerificationRepo, users UserRepo, email EmailSender, audit *audit.Logger) VerificationService {
+	return &verificationService{
+		repo:    repo,
+		users:   users,
+		email:   email,
+		audit:   audit,
+		limiter: ratelimit.NewLimiter(),
+	}
+}
+
+// SendCode emails a fresh verification code, replacing any earlier one
+func (s *verificationService) SendCode(ctx context.Context, u *User) error {
+	if u.EmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+	if ok, _ := s.limiter.Allow("resend:"+u.ID.String(), ResendLimit, ResendWindow); !ok {
+		return ErrVerificationRateLimited
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	now := time.Now()
+	err = s.repo.Put(ctx, &EmailVerification{
+		UserID:    u.ID,
+		CodeHash:  hashVerificationCode(code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(VerificationCodeTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	body := "Welcome to Telegraph!\n\nYour verification code: " + code + "\n\n" +
+		"It expires in 24 hours. If you didn't create an account, ignore this email."
+	return s.email.Send(u.Email, "Telegraph: confirm your email", body)
+}
+
+// Resend sends a new code to an unverified account. The rate limit is keyed
+// by address and checked before the lookup, and unknown and already verified
+// addresses are ignored, so every address gets the same answers and the
+// caller cannot probe for accounts.
+func (s *verificationService) Resend(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	if ok, _ := s.limiter.Allow("resend-email:"+email, ResendLimit, ResendWindow); !ok {
+		return ErrVerificationRateLimited
+	}
+
+	u, err := s.users.GetByEmail(ctx, email)
+	if err != nil || u.EmailVerified() {
+		return nil
+	}
+	// The account's own limit also counts the code sent at registration;
+	// hitting it must look the same as an unknown address
+	if err := s.SendCode(ctx, u); err != ErrVerificationRateLimited {
+		return err
+	}
+	return nil
+}
+
+// Verify confirms the address with a code from SendCode. Unknown and already
+// verified addresses fail like a wrong code so the caller cannot probe for
+// accounts. Each guess uses up an attempt before it is compared, so parallel
+// guesses can't exceed VerificationAttempts.
+func (s *verificationService) Verify(ctx context.Context, email, code string) error {
+	email = normalizeEmail(email)
+	if ok, _ := s.limiter.Allow("verify:"+email, VerifyLimit, VerifyWindow); !ok {
+		return ErrVerificationRateLimited
+	}
+
+	u, err := s.users.GetByEmail(ctx, email)
+	if err != nil || u.EmailVerified() {
+		return ErrInvalidVerificationCode
+	}
+
+	v, err := s.repo.UseAttempt(ctx, u.ID, VerificationAttempts)
+	if err != nil {
+		return err
+	}
+	if time.Now().After(v.ExpiresAt) {
+		_ = s.repo.Delete(ctx, u.ID)
+		return ErrInvalidVerificationCode
+	}
+
+	got := hashVerificationCode(strings.TrimSpace(code))
+	if subtle.ConstantTimeCompare([]byte(got), []byte(v.CodeHash)) != 1 {
+		return ErrInvalidVerificationCode
+	}
+
+	// Only one of several requests with the right code gets to use it
+	consumed, err := s.repo.Consume(ctx, u.ID, got)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidVerificationCode
+	}
+	if err := s.users.MarkEmailVerified(ctx, u.ID); err != nil {
+		// Put the code back so the user can retry it
+		_ = s.repo.Put(ctx, v)
+		return err
+	}
+
+	userID := u.ID
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventEmailVerified,
+		Resource: "user:" + userID.String(),
+		Result:   "success",
+	})
+	return nil
+}
+
+func hashVerificationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
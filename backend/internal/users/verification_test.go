@@ -0,0 +1,168 @@
+package users
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+)
+
+// memoryVerificationRepo keeps verification codes in memory for tests
+type memoryVerificationRepo struct {
+	mu    sync.Mutex
+	codes map[uuid.UUID]*EmailVerification
+}
+
+func (r *memoryVerificationRepo) Put(ctx context.Context, v *EmailVerification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *v
+	r.codes[v.UserID] = &copied
+	return nil
+}
+
+func (r *memoryVerificationRepo) UseAttempt(ctx context.Context, userID uuid.UUID, maxAttempts int) (*EmailVerification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.codes[userID]
+	if !ok || v.Attempts >= maxAttempts {
+		return nil, ErrInvalidVerificationCode
+	}
+	v.Attempts++
+	copied := *v
+	return &copied, nil
+}
+
+func (r *memoryVerificationRepo) Consume(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.codes[userID]
+	if !ok || v.CodeHash != codeHash {
+		return false, nil
+	}
+	delete(r.codes, userID)
+	return true, nil
+}
+
+func (r *memoryVerificationRepo) Delete(ctx context.Context, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.codes, userID)
+	return nil
+}
+
+// verificationUsers serves the lookups the verification flow needs
+type verificationUsers struct {
+	UserRepo
+	byEmail map[string]*User
+}
+
+func (r *verificationUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	u, ok := r.byEmail[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (r *verificationUsers) MarkEmailVerified(ctx context.Context, id uuid.UUID) error {
+	for _, u := range r.byEmail {
+		if u.ID == id {
+			u.EmailVerificationPending = false
+		}
+	}
+	return nil
+}
+
+type codeSender struct {
+	codes []string
+}
+
+func (s *codeSender) Send(to, subject, body string) error {
+	_, rest, _ := strings.Cut(body, "Your verification code: ")
+	code, _, _ := strings.Cut(rest, "\n")
+	s.codes = append(s.codes, code)
+	return nil
+}
+
+func newTestVerification(t *testing.T) (VerificationService, *User, string) {
+	t.Helper()
+	pending := &User{ID: uuid.New(), Email: "ada@example.com", EmailVerificationPending: true}
+	verified := &User{ID: uuid.New(), Email: "grace@example.com"}
+	users := &verificationUsers{byEmail: map[string]*User{pending.Email: pending, verified.Email: verified}}
+	sender := &codeSender{}
+	repo := &memoryVerificationRepo{codes: map[uuid.UUID]*EmailVerification{}}
+
+	svc := NewVerificationService(repo, users, sender, &audit.Logger{})
+	if err := svc.SendCode(context.Background(), pending); err != nil {
+		t.Fatalf("SendCode: %v", err)
+	}
+	return svc, pending, sender.codes[0]
+}
+
+func TestVerify_ConfirmsOnce(t *testing.T) {
+	svc, u, code := newTestVerification(t)
+	ctx := context.Background()
+
+	if err := svc.Verify(ctx, " Ada@Example.com", code); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !u.EmailVerified() {
+		t.Fatal("email not marked verified")
+	}
+	if err := svc.Verify(ctx, u.Email, code); err != ErrInvalidVerificationCode {
+		t.Fatalf("second verify: got %v, want ErrInvalidVerificationCode", err)
+	}
+}
+
+func TestVerify_UnknownAndVerifiedLookAlike(t *testing.T) {
+	svc, _, code := newTestVerification(t)
+	ctx := context.Background()
+
+	unknown := svc.Verify(ctx, "nobody@example.com", code)
+	verified := svc.Verify(ctx, "grace@example.com", code)
+	if unknown != ErrInvalidVerificationCode || verified != ErrInvalidVerificationCode {
+		t.Fatalf("unknown: %v, verified: %v; want both ErrInvalidVerificationCode", unknown, verified)
+	}
+}
+
+func TestVerify_AttemptsRunOut(t *testing.T) {
+	svc, u, code := newTestVerification(t)
+	ctx := context.Background()
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < VerificationAttempts; i++ {
+		if err := svc.Verify(ctx, u.Email, wrong); err != ErrInvalidVerificationCode {
+			t.Fatalf("attempt %d: got %v", i+1, err)
+		}
+	}
+	if err := svc.Verify(ctx, u.Email, code); err != ErrInvalidVerificationCode {
+		t.Fatalf("right code after the limit: got %v, want ErrInvalidVerificationCode", err)
+	}
+	if u.EmailVerified() {
+		t.Fatal("email verified after attempts ran out")
+	}
+}
+
+func TestResend_LimitedAlikeForEveryAddress(t *testing.T) {
+	svc, u, _ := newTestVerification(t)
+	ctx := context.Background()
+
+	for _, email := range []string{u.Email, "nobody@example.com", "grace@example.com"} {
+		for i := 0; i < ResendLimit; i++ {
+			if err := svc.Resend(ctx, email); err != nil {
+				t.Fatalf("%s resend %d: %v", email, i+1, err)
+			}
+		}
+		if err := svc.Resend(ctx, email); err != ErrVerificationRateLimited {
+			t.Fatalf("%s: got %v, want ErrVerificationRateLimited", email, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+)
+
+// COSE algorithm identifiers we accept (RFC 8152, RFC 8812)
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms are offered to authenticators in order of preference
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1 // also RSA n
+	coseX   = -2 // also RSA e
+	coseY   = -3
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// parseCOSEKey decodes a COSE_Key into a Go public key and its algorithm
+func parseCOSEKey(raw []byte) (crypto.PublicKey, int64, error) {
+	v, _, err := decodeCBOR(raw)
+	if err != nil {
+		return nil, 0, ErrInvalidPublicKey
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, 0, ErrInvalidPublicKey
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		return key, alg, nil
+
+	case kty == ktyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		return ed25519.PublicKey(x), alg, nil
+
+	case kty == ktyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseCrv)].([]byte)
+		e, _ := m[int64(coseX)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, ErrInvalidPublicKey
+		}
+		exp := int(new(big.Int).SetBytes(e).Int64())
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, alg, nil
+	}
+	return nil, 0, ErrUnsupportedAlgorithm
+}
+
+// verifySignature checks a WebAuthn signature made with alg over data
+func verifySignature(key crypto.PublicKey, alg int64, data, sig []byte) error {
+	switch alg {
+	case AlgES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		digest := sha256.Sum256(data)
+		if ok && ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+	case AlgEdDSA:
+		k, ok := key.(ed25519.PublicKey)
+		if ok && ed25519.Verify(k, data, sig) {
+			return nil
+		}
+	case AlgRS256:
+		k, ok := key.(*rsa.PublicKey)
+		digest := sha256.Sum256(data)
+		if ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+	return ErrInvalidSignature
+}
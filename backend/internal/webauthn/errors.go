@@ -0,0 +1,26 @@
+package webauthn
+
+import "errors"
+
+var (
+	ErrSessionNotFound    = errors.New("webauthn_session_not_found")
+	ErrSessionExpired     = errors.New("webauthn_session_expired")
+	ErrCredentialNotFound = errors.New("credential_not_found")
+	ErrCredentialExists   = errors.New("credential_already_registered")
+	ErrTooManyCredentials = errors.New("too_many_credentials")
+	ErrInvalidName        = errors.New("invalid_credential_name")
+
+	ErrInvalidClientData        = errors.New("invalid_client_data")
+	ErrChallengeMismatch        = errors.New("challenge_mismatch")
+	ErrOriginMismatch           = errors.New("origin_not_allowed")
+	ErrInvalidAuthenticatorData = errors.New("invalid_authenticator_data")
+	ErrRPIDMismatch             = errors.New("rp_id_mismatch")
+	ErrUserNotPresent           = errors.New("user_not_present")
+	ErrUserNotVerified          = errors.New("user_not_verified")
+	ErrInvalidAttestation       = errors.New("invalid_attestation")
+	ErrUnsupportedAttestation   = errors.New("unsupported_attestation_format")
+	ErrInvalidPublicKey         = errors.New("invalid_public_key")
+	ErrUnsupportedAlgorithm     = errors.New("unsupported_algorithm")
+	ErrInvalidSignature         = errors.New("invalid_signature")
+	ErrSignCountRegression      = errors.New("sign_count_regression")
+)
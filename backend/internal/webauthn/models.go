@@ -0,0 +1,193 @@
+package webauthn
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// RPName is shown by authenticators next to the relying party ID
+	RPName = "Telegraph"
+
+	CeremonyTimeout = 5 * time.Minute
+	MaxCredentials  = 10
+	MaxNameLength   = 64
+	ChallengeSize   = 32
+)
+
+// Config identifies this server as a WebAuthn relying party
+type Config struct {
+	RPID    string   // Effective domain, e.g. "telegraph.example"
+	Origins []string // Origins clients may run ceremonies from
+}
+
+// Ceremony is the kind of operation a challenge was issued for
+type Ceremony string
+
+const (
+	CeremonyRegistration   Ceremony = "registration"
+	CeremonyAuthentication Ceremony = "authentication"
+)
+
+// Purpose is what an authentication challenge was issued for. A challenge
+// only finishes the flow it was started for.
+type Purpose string
+
+const (
+	PurposeMFA          Purpose = "mfa"          // Second factor after a password
+	PurposePasswordless Purpose = "passwordless" // Sole factor; requires user verification
+)
+
+// Credential is a registered passkey or security key
+type Credential struct {
+	ID                uuid.UUID  `json:"id" bson:"_id"`
+	UserID            uuid.UUID  `json:"-" bson:"user_id"`
+	CredentialID      []byte     `json:"credential_id" bson:"credential_id"`
+	PublicKey         []byte     `json:"-" bson:"public_key"` // COSE_Key
+	Algorithm         int64      `json:"algorithm" bson:"algorithm"`
+	SignCount         uint32     `json:"sign_count" bson:"sign_count"`
+	AAGUID            []byte     `json:"aaguid" bson:"aaguid"`
+	AttestationFormat string     `json:"attestation_format" bson:"attestation_format"`
+	Name              string     `json:"name" bson:"name"`
+	CreatedAt         time.Time  `json:"created_at" bson:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+}
+
+// Session is an outstanding challenge. It is consumed by the first
+// finish request, successful or not.
+type Session struct {
+	ID        uuid.UUID  `bson:"_id"`
+	Ceremony  Ceremony   `bson:"ceremony"`
+	Challenge []byte     `bson:"challenge"`
+	UserID    *uuid.UUID `bson:"user_id,omitempty"` // Nil for discoverable-credential login
+	Purpose   Purpose    `bson:"purpose,omitempty"`
+	RequireUV bool       `bson:"require_uv"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+}
+
+// LoginResult is a verified assertion
+type LoginResult struct {
+	UserID       uuid.UUID
+	Credential   *Credential
+	UserVerified bool
+}
+
+// Base64URL is binary data carried as unpadded base64url in JSON, as
+// the WebAuthn JSON serialization does
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Options sent to navigator.credentials.create() / get()
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string    `json:"type"`
+	ID   Base64URL `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+type CreationOptions struct {
+	Challenge              Base64URL              `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+type RequestOptions struct {
+	Challenge        Base64URL              `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+type RegistrationBegin struct {
+	SessionID uuid.UUID       `json:"session_id"`
+	PublicKey CreationOptions `json:"public_key"`
+}
+
+type LoginBegin struct {
+	SessionID uuid.UUID      `json:"session_id"`
+	PublicKey RequestOptions `json:"public_key"`
+}
+
+// Credentials returned by the browser
+
+type AttestationResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AttestationObject Base64URL `json:"attestationObject"`
+}
+
+type AssertionResponse struct {
+	ClientDataJSON    Base64URL `json:"clientDataJSON"`
+	AuthenticatorData Base64URL `json:"authenticatorData"`
+	Signature         Base64URL `json:"signature"`
+	UserHandle        Base64URL `json:"userHandle,omitempty"`
+}
+
+type RegistrationCredential struct {
+	RawID    Base64URL           `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+type AssertionCredential struct {
+	RawID    Base64URL         `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
+
+type FinishRegistrationRequest struct {
+	SessionID  uuid.UUID              `json:"session_id"`
+	Name       string                 `json:"name"`
+	Credential RegistrationCredential `json:"credential"`
+}
+
+type FinishLoginRequest struct {
+	SessionID  uuid.UUID           `json:"session_id"`
+	Credential AssertionCredential `json:"credential"`
+}
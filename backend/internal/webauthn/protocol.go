@@ -0,0 +1,290 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent   = 0x01
+	flagUserVerified  = 0x04
+	flagAttestedData  = 0x40
+	flagExtensionData = 0x80
+)
+
+// Client data types
+const (
+	clientDataCreate = "webauthn.create"
+	clientDataGet    = "webauthn.get"
+)
+
+// idFidoGenCeAAGUID marks the AAGUID extension in packed attestation certificates
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+type authenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// Only present when flagAttestedData is set
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func (a *authenticatorData) UserVerified() bool {
+	return a.Flags&flagUserVerified != 0
+}
+
+// verifyClientData checks the browser-provided context of a ceremony
+func verifyClientData(cfg Config, raw []byte, wantType string, challenge []byte) error {
+	var cd collectedClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return ErrInvalidClientData
+	}
+	if cd.Type != wantType || cd.CrossOrigin {
+		return ErrInvalidClientData
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	for _, origin := range cfg.Origins {
+		if cd.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+// parseAuthenticatorData decodes the binary authenticator data structure
+func parseAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	if len(raw) < 37 {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	ad := &authenticatorData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rest := raw[37:]
+
+	if ad.Flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		ad.AAGUID = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		ad.CredentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		// The key is a CBOR item of unknown length; decode it to find the end
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		ad.PublicKey = rest[:n]
+		rest = rest[n:]
+	}
+
+	if ad.Flags&flagExtensionData != 0 {
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		rest = rest[n:]
+	}
+
+	if len(rest) != 0 {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	return ad, nil
+}
+
+// checkAuthenticatorData applies the checks shared by both ceremonies
+func checkAuthenticatorData(cfg Config, ad *authenticatorData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(cfg.RPID))
+	if subtle.ConstantTimeCompare(ad.RPIDHash, rpIDHash[:]) != 1 {
+		return ErrRPIDMismatch
+	}
+	if ad.Flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if requireUV && !ad.UserVerified() {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+// verifyRegistration validates an attestation response and returns the
+// new credential. Only "none" and "packed" attestation are accepted; we do
+// not check attestation certificates against a trust store.
+func verifyRegistration(cfg Config, challenge []byte, requireUV bool, resp AttestationResponse) (*Credential, error) {
+	if err := verifyClientData(cfg, resp.ClientDataJSON, clientDataCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	v, _, err := decodeCBOR(resp.AttestationObject)
+	if err != nil {
+		return nil, ErrInvalidAttestation
+	}
+	obj, ok := v.(map[any]any)
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+	format, _ := obj["fmt"].(string)
+	attStmt, _ := obj["attStmt"].(map[any]any)
+	rawAuthData, _ := obj["authData"].([]byte)
+	if attStmt == nil || rawAuthData == nil {
+		return nil, ErrInvalidAttestation
+	}
+
+	ad, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(cfg, ad, requireUV); err != nil {
+		return nil, err
+	}
+	if ad.Flags&flagAttestedData == 0 {
+		return nil, ErrInvalidAuthenticatorData
+	}
+
+	key, alg, err := parseCOSEKey(ad.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(resp.ClientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+
+	switch format {
+	case "none":
+		if len(attStmt) != 0 {
+			return nil, ErrInvalidAttestation
+		}
+	case "packed":
+		if err := verifyPackedAttestation(attStmt, ad, key, alg, signed); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedAttestation
+	}
+
+	return &Credential{
+		CredentialID:      append([]byte(nil), ad.CredentialID...),
+		PublicKey:         append([]byte(nil), ad.PublicKey...),
+		Algorithm:         alg,
+		SignCount:         ad.SignCount,
+		AAGUID:            append([]byte(nil), ad.AAGUID...),
+		AttestationFormat: format,
+	}, nil
+}
+
+// verifyPackedAttestation handles both full (x5c) and self attestation
+func verifyPackedAttestation(attStmt map[any]any, ad *authenticatorData, credKey crypto.PublicKey, credAlg int64, signed []byte) error {
+	alg, ok := attStmt["alg"].(int64)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	sig, ok := attStmt["sig"].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+
+	x5c, hasX5C := attStmt["x5c"].([]any)
+	if !hasX5C {
+		// Self attestation: signed with the credential key itself
+		if alg != credAlg {
+			return ErrInvalidAttestation
+		}
+		if err := verifySignature(credKey, alg, signed, sig); err != nil {
+			return ErrInvalidAttestation
+		}
+		return nil
+	}
+
+	if len(x5c) == 0 {
+		return ErrInvalidAttestation
+	}
+	der, ok := x5c[0].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil || cert.IsCA {
+		return ErrInvalidAttestation
+	}
+	if err := verifySignature(cert.PublicKey, alg, signed, sig); err != nil {
+		return ErrInvalidAttestation
+	}
+
+	// If the certificate names an authenticator model it must match
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		var aaguid []byte
+		if _, err := asn1.Unmarshal(ext.Value, &aaguid); err != nil || subtle.ConstantTimeCompare(aaguid, ad.AAGUID) != 1 {
+			return ErrInvalidAttestation
+		}
+	}
+	return nil
+}
+
+// verifyAssertion validates an authentication response against a stored
+// credential and returns the parsed authenticator data
+func verifyAssertion(cfg Config, challenge []byte, requireUV bool, cred *Credential, resp AssertionResponse) (*authenticatorData, error) {
+	if err := verifyClientData(cfg, resp.ClientDataJSON, clientDataGet, challenge); err != nil {
+		return nil, err
+	}
+
+	ad, err := parseAuthenticatorData(resp.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAuthenticatorData(cfg, ad, requireUV); err != nil {
+		return nil, err
+	}
+
+	key, alg, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(resp.ClientDataJSON)
+	signed := append(append([]byte{}, resp.AuthenticatorData...), clientDataHash[:]...)
+	if err := verifySignature(key, alg, signed, resp.Signature); err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
+
+// signCountValid detects cloned authenticators. Counters only move forward;
+// authenticators that don't implement one always report zero.
+func signCountValid(stored, received uint32) bool {
+	if stored == 0 && received == 0 {
+		return true
+	}
+	return received > stored
+}
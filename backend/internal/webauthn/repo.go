@@ -0,0 +1,127 @@
+package webauthn
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Credential IDs are chosen by authenticators and unique across users, so
+// the document ID is derived from them and a second registration of the
+// same credential collides on insert
+var credentialNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("telegraph:webauthn:credential"))
+
+func credentialDocID(credentialID []byte) uuid.UUID {
+	return uuid.NewSHA1(credentialNamespace, credentialID)
+}
+
+type WebAuthnRepo interface {
+	CreateSession(ctx context.Context, s *Session) error
+	TakeSession(ctx context.Context, id uuid.UUID) (*Session, error)
+
+	CreateCredential(ctx context.Context, c *Credential) error
+	GetCredential(ctx context.Context, credentialID []byte) (*Credential, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]*Credential, error)
+	CountCredentials(ctx context.Context, userID uuid.UUID) (int64, error)
+	UpdateSignCount(ctx context.Context, id uuid.UUID, old, new uint32) error
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type mongoWebAuthnRepo struct {
+	sessions    *mongo.Collection
+	credentials *mongo.Collection
+}
+
+func NewMongoWebAuthnRepo(db *mongo.Database) WebAuthnRepo {
+	return &mongoWebAuthnRepo{
+		sessions:    db.Collection("webauthn_sessions"),
+		credentials: db.Collection("webauthn_credentials"),
+	}
+}
+
+func (r *mongoWebAuthnRepo) CreateSession(ctx context.Context, s *Session) error {
+	_, err := r.sessions.InsertOne(ctx, s)
+	return err
+}
+
+// TakeSession removes and returns a session so its challenge is single use
+func (r *mongoWebAuthnRepo) TakeSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	var s Session
+	err := r.sessions.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *mongoWebAuthnRepo) CreateCredential(ctx context.Context, c *Credential) error {
+	c.ID = credentialDocID(c.CredentialID)
+	_, err := r.credentials.InsertOne(ctx, c)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCredentialExists
+	}
+	return err
+}
+
+func (r *mongoWebAuthnRepo) GetCredential(ctx context.Context, credentialID []byte) (*Credential, error) {
+	var c Credential
+	err := r.credentials.FindOne(ctx, bson.M{"_id": credentialDocID(credentialID)}).Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *mongoWebAuthnRepo) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*Credential, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.credentials.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	creds := []*Credential{}
+	if err := cursor.All(ctx, &creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+func (r *mongoWebAuthnRepo) CountCredentials(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return r.credentials.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+// UpdateSignCount only applies if the stored counter is still old, so two
+// concurrent logins with the same assertion cannot both succeed
+func (r *mongoWebAuthnRepo) UpdateSignCount(ctx context.Context, id uuid.UUID, old, new uint32) error {
+	update := bson.M{"$set": bson.M{"sign_count": new, "last_used_at": time.Now()}}
+	res, err := r.credentials.UpdateOne(ctx, bson.M{"_id": id, "sign_count": old}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSignCountRegression
+	}
+	return nil
+}
+
+func (r *mongoWebAuthnRepo) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	res, err := r.credentials.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package webauthn
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"telegraph/internal/audit"
+
+	"github.com/google/uuid"
+)
+
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID, username string) (*RegistrationBegin, error)
+	FinishRegistration(ctx context.Context, userID uuid.UUID, req FinishRegistrationRequest) (*Credential, error)
+	BeginLogin(ctx context.Context, userID *uuid.UUID, purpose Purpose) (*LoginBegin, error)
+	BeginDecoyLogin(ctx context.Context, account string, purpose Purpose) (*LoginBegin, error)
+	FinishLogin(ctx context.Context, purpose Purpose, req FinishLoginRequest) (*LoginResult, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]*Credential, error)
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) (int64, error)
+}
+
+type webAuthnService struct {
+	repo     WebAuthnRepo
+	cfg      Config
+	audit    *audit.Logger
+	decoyKey []byte // Derives fake credential IDs for BeginDecoyLogin
+}
+
+func NewWebAuthnService(repo WebAuthnRepo, cfg Config, audit *audit.Logger) WebAuthnService {
+	decoyKey := make([]byte, 32)
+	if _, err := rand.Read(decoyKey); err != nil {
+		panic(fmt.Sprintf("webauthn: generating decoy key: %v", err))
+	}
+	return &webAuthnService{repo: repo, cfg: cfg, audit: audit, decoyKey: decoyKey}
+}
+
+// BeginRegistration issues creation options for a new passkey
+func (s *webAuthnService) BeginRegistration(ctx context.Context, userID uuid.UUID, username string) (*RegistrationBegin, error) {
+	existing, err := s.repo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= MaxCredentials {
+		return nil, ErrTooManyCredentials
+	}
+
+	session, err := s.newSession(ctx, CeremonyRegistration, &userID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+
+	return &RegistrationBegin{
+		SessionID: session.ID,
+		PublicKey: CreationOptions{
+			Challenge: session.Challenge,
+			RP:        RelyingParty{ID: s.cfg.RPID, Name: RPName},
+			User: UserEntity{
+				ID:          userID[:],
+				Name:        username,
+				DisplayName: username,
+			},
+			PubKeyCredParams:   params,
+			Timeout:            CeremonyTimeout.Milliseconds(),
+			ExcludeCredentials: descriptors(existing),
+			AuthenticatorSelection: AuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: "preferred",
+			},
+			Attestation: "none",
+		},
+	}, nil
+}
+
+// FinishRegistration verifies the authenticator's attestation and stores the credential
+func (s *webAuthnService) FinishRegistration(ctx context.Context, userID uuid.UUID, req FinishRegistrationRequest) (*Credential, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.Name) > MaxNameLength {
+		return nil, ErrInvalidName
+	}
+
+	session, err := s.takeSession(ctx, req.SessionID, CeremonyRegistration, "")
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID == nil || *session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+
+	cred, err := verifyRegistration(s.cfg, session.Challenge, session.RequireUV, req.Credential.Response)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(cred.CredentialID, req.Credential.RawID) {
+		return nil, ErrInvalidAuthenticatorData
+	}
+
+	count, err := s.repo.CountCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxCredentials {
+		return nil, ErrTooManyCredentials
+	}
+
+	cred.UserID = userID
+	cred.Name = req.Name
+	if cred.Name == "" {
+		cred.Name = fmt.Sprintf("Passkey %d", count+1)
+	}
+	cred.CreatedAt = time.Now()
+	if err := s.repo.CreateCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventPasskeyRegistered,
+		Resource: "webauthn_credential:" + cred.ID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Registered passkey '%s' (%s attestation, alg %d)", cred.Name, cred.AttestationFormat, cred.Algorithm),
+	})
+
+	return cred, nil
+}
+
+// BeginLogin issues request options. With a user the assertion must use one
+// of their credentials; without one any discoverable credential is accepted.
+func (s *webAuthnService) BeginLogin(ctx context.Context, userID *uuid.UUID, purpose Purpose) (*LoginBegin, error) {
+	allow := []CredentialDescriptor{}
+	if userID != nil {
+		creds, err := s.repo.ListCredentials(ctx, *userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(creds) == 0 {
+			return nil, ErrCredentialNotFound
+		}
+		allow = descriptors(creds)
+	}
+
+	session, err := s.newSession(ctx, CeremonyAuthentication, userID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	return s.loginOptions(session, allow), nil
+}
+
+func (s *webAuthnService) loginOptions(session *Session, allow []CredentialDescriptor) *LoginBegin {
+	uv := "preferred"
+	if session.RequireUV {
+		uv = "required"
+	}
+	return &LoginBegin{
+		SessionID: session.ID,
+		PublicKey: RequestOptions{
+			Challenge:        session.Challenge,
+			RPID:             s.cfg.RPID,
+			Timeout:          CeremonyTimeout.Milliseconds(),
+			AllowCredentials: allow,
+			UserVerification: uv,
+		},
+	}
+}
+
+// BeginDecoyLogin answers a login for an account without passkeys the way
+// BeginLogin would for one that has them, so the response doesn't tell
+// whether the account exists. The fake credential IDs are derived from
+// account and stay the same between requests; the challenge can't be
+// finished because no credential belongs to its random user.
+func (s *webAuthnService) BeginDecoyLogin(ctx context.Context, account string, purpose Purpose) (*LoginBegin, error) {
+	mac := hmac.New(sha256.New, s.decoyKey)
+	mac.Write([]byte(account))
+	credentialID := mac.Sum(nil)
+
+	nobody := uuid.New()
+	session, err := s.newSession(ctx, CeremonyAuthentication, &nobody, purpose)
+	if err != nil {
+		return nil, err
+	}
+	return s.loginOptions(session, []CredentialDescriptor{{Type: "public-key", ID: credentialID}}), nil
+}
+
+// FinishLogin verifies an assertion for a challenge issued for purpose and
+// advances the credential's sign counter
+func (s *webAuthnService) FinishLogin(ctx context.Context, purpose Purpose, req FinishLoginRequest) (*LoginResult, error) {
+	session, err := s.takeSession(ctx, req.SessionID, CeremonyAuthentication, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := s.repo.GetCredential(ctx, req.Credential.RawID)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != nil && *session.UserID != cred.UserID {
+		return nil, ErrCredentialNotFound
+	}
+	if handle := req.Credential.Response.UserHandle; len(handle) > 0 && !bytes.Equal(handle, cred.UserID[:]) {
+		return nil, ErrCredentialNotFound
+	}
+
+	ad, err := verifyAssertion(s.cfg, session.Challenge, session.RequireUV, cred, req.Credential.Response)
+	if err != nil {
+		return nil, err
+	}
+
+	if !signCountValid(cred.SignCount, ad.SignCount) {
+		return nil, ErrSignCountRegression
+	}
+	if err := s.repo.UpdateSignCount(ctx, cred.ID, cred.SignCount, ad.SignCount); err != nil {
+		return nil, err
+	}
+	cred.SignCount = ad.SignCount
+
+	return &LoginResult{
+		UserID:       cred.UserID,
+		Credential:   cred,
+		UserVerified: ad.UserVerified(),
+	}, nil
+}
+
+func (s *webAuthnService) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*Credential, error) {
+	return s.repo.ListCredentials(ctx, userID)
+}
+
+// DeleteCredential removes a passkey and returns how many the user has left
+func (s *webAuthnService) DeleteCredential(ctx context.Context, userID, id uuid.UUID) (int64, error) {
+	if err := s.repo.DeleteCredential(ctx, userID, id); err != nil {
+		return 0, err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventPasskeyRemoved,
+		Resource: "webauthn_credential:" + id.String(),
+		Result:   "success",
+	})
+
+	return s.repo.CountCredentials(ctx, userID)
+}
+
+func (s *webAuthnService) newSession(ctx context.Context, ceremony Ceremony, userID *uuid.UUID, purpose Purpose) (*Session, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:        uuid.New(),
+		Ceremony:  ceremony,
+		Challenge: challenge,
+		UserID:    userID,
+		Purpose:   purpose,
+		RequireUV: purpose == PurposePasswordless,
+		CreatedAt: now,
+		ExpiresAt: now.Add(CeremonyTimeout),
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *webAuthnService) takeSession(ctx context.Context, id uuid.UUID, ceremony Ceremony, purpose Purpose) (*Session, error) {
+	session, err := s.repo.TakeSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Ceremony != ceremony || session.Purpose != purpose {
+		return nil, ErrSessionNotFound
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	return session, nil
+}
+
+func descriptors(creds []*Credential) []CredentialDescriptor {
+	out := make([]CredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		out = append(out, CredentialDescriptor{Type: "public-key", ID: c.CredentialID})
+	}
+	return out
+}
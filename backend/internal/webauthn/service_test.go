@@ -0,0 +1,186 @@
+package webauthn
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"telegraph/internal/audit"
+)
+
+// memoryRepo keeps sessions and credentials in memory for tests
+type memoryRepo struct {
+	mu          sync.Mutex
+	sessions    map[uuid.UUID]*Session
+	credentials []*Credential
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{sessions: map[uuid.UUID]*Session{}}
+}
+
+func (r *memoryRepo) CreateSession(ctx context.Context, s *Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[s.ID] = s
+	return nil
+}
+
+func (r *memoryRepo) TakeSession(ctx context.Context, id uuid.UUID) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	delete(r.sessions, id)
+	return s, nil
+}
+
+func (r *memoryRepo) CreateCredential(ctx context.Context, c *Credential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.credentials = append(r.credentials, c)
+	return nil
+}
+
+func (r *memoryRepo) GetCredential(ctx context.Context, credentialID []byte) (*Credential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.credentials {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, ErrCredentialNotFound
+}
+
+func (r *memoryRepo) ListCredentials(ctx context.Context, userID uuid.UUID) ([]*Credential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*Credential
+	for _, c := range r.credentials {
+		if c.UserID == userID {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepo) CountCredentials(ctx context.Context, userID uuid.UUID) (int64, error) {
+	creds, _ := r.ListCredentials(ctx, userID)
+	return int64(len(creds)), nil
+}
+
+func (r *memoryRepo) UpdateSignCount(ctx context.Context, id uuid.UUID, old, new uint32) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.credentials {
+		if c.ID == id && c.SignCount == old {
+			c.SignCount = new
+			return nil
+		}
+	}
+	return ErrSignCountRegression
+}
+
+func (r *memoryRepo) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	return nil
+}
+
+func TestFinishLogin_ChallengeOnlyFinishesItsPurpose(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepo()
+	svc := NewWebAuthnService(repo, testConfig, &audit.Logger{})
+	auth := newEd25519Authenticator(t)
+	userID := uuid.New()
+
+	regChallenge := challenge(t)
+	cred, err := verifyRegistration(testConfig, regChallenge, false, auth.create(testConfig.RPID, testConfig.Origins[0], regChallenge, "none"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred.ID = uuid.New()
+	cred.UserID = userID
+	repo.CreateCredential(ctx, cred)
+
+	finish := func(begin *LoginBegin, purpose Purpose, flags byte) error {
+		assertion := auth.get(testConfig.RPID, testConfig.Origins[0], begin.PublicKey.Challenge, flags)
+		_, err := svc.FinishLogin(ctx, purpose, FinishLoginRequest{
+			SessionID:  begin.SessionID,
+			Credential: AssertionCredential{RawID: auth.credID, Type: "public-key", Response: assertion},
+		})
+		return err
+	}
+
+	// A second-factor challenge, which doesn't need user verification, can't
+	// be spent on a passwordless login
+	mfa, err := svc.BeginLogin(ctx, &userID, PurposeMFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := finish(mfa, PurposePasswordless, flagUserPresent); err != ErrSessionNotFound {
+		t.Fatalf("MFA challenge used for passwordless login: got %v, want ErrSessionNotFound", err)
+	}
+
+	passwordless, err := svc.BeginLogin(ctx, nil, PurposePasswordless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passwordless.PublicKey.UserVerification != "required" {
+		t.Errorf("passwordless challenge asks for user verification %q", passwordless.PublicKey.UserVerification)
+	}
+	if err := finish(passwordless, PurposePasswordless, flagUserPresent); err != ErrUserNotVerified {
+		t.Fatalf("passwordless login without user verification: got %v, want ErrUserNotVerified", err)
+	}
+
+	passwordless, err = svc.BeginLogin(ctx, nil, PurposePasswordless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := finish(passwordless, PurposePasswordless, flagUserPresent|flagUserVerified); err != nil {
+		t.Fatalf("passwordless login: %v", err)
+	}
+}
+
+func TestBeginDecoyLogin_StableAndUnfinishable(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryRepo()
+	svc := NewWebAuthnService(repo, testConfig, &audit.Logger{})
+	auth := newEd25519Authenticator(t)
+
+	first, err := svc.BeginDecoyLogin(ctx, "nobody@example.com", PurposePasswordless)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := svc.BeginDecoyLogin(ctx, "nobody@example.com", PurposePasswordless)
+	other, _ := svc.BeginDecoyLogin(ctx, "someone@example.com", PurposePasswordless)
+	if len(first.PublicKey.AllowCredentials) != 1 ||
+		!bytes.Equal(first.PublicKey.AllowCredentials[0].ID, again.PublicKey.AllowCredentials[0].ID) ||
+		bytes.Equal(first.PublicKey.AllowCredentials[0].ID, other.PublicKey.AllowCredentials[0].ID) {
+		t.Fatal("decoy credentials must be stable per account and differ between accounts")
+	}
+
+	// A real passkey can't be used to finish a decoy challenge
+	regChallenge := challenge(t)
+	cred, err := verifyRegistration(testConfig, regChallenge, false, auth.create(testConfig.RPID, testConfig.Origins[0], regChallenge, "none"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred.ID = uuid.New()
+	cred.UserID = uuid.New()
+	repo.CreateCredential(ctx, cred)
+
+	assertion := auth.get(testConfig.RPID, testConfig.Origins[0], first.PublicKey.Challenge, flagUserPresent|flagUserVerified)
+	_, err = svc.FinishLogin(ctx, PurposePasswordless, FinishLoginRequest{
+		SessionID:  first.SessionID,
+		Credential: AssertionCredential{RawID: auth.credID, Type: "public-key", Response: assertion},
+	})
+	if err != ErrCredentialNotFound {
+		t.Fatalf("finishing a decoy challenge: got %v, want ErrCredentialNotFound", err)
+	}
+}
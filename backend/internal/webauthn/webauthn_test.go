@@ -0,0 +1,365 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+)
+
+var testConfig = Config{RPID: "telegraph.test", Origins: []string{"https://telegraph.test"}}
+
+// Minimal CBOR encoder for building authenticator output in tests
+
+type cborPair struct {
+	key, value any
+}
+
+type cborMap []cborPair
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		b := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(b[1:], uint16(n))
+		return b
+	}
+	b := []byte{major<<5 | 26, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(n))
+	return b
+}
+
+func encodeCBOR(v any) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case int64:
+		return encodeCBOR(int(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []any:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case cborMap:
+		out := cborHead(5, uint64(len(v)))
+		for _, p := range v {
+			out = append(out, encodeCBOR(p.key)...)
+			out = append(out, encodeCBOR(p.value)...)
+		}
+		return out
+	}
+	panic("unsupported type")
+}
+
+// softAuthenticator is an in-memory authenticator holding one credential
+type softAuthenticator struct {
+	credID []byte
+	ec     *ecdsa.PrivateKey
+	ed     ed25519.PrivateKey
+	count  uint32
+}
+
+func newECAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{credID: []byte("credential-ec"), ec: key}
+}
+
+func newEd25519Authenticator(t *testing.T) *softAuthenticator {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{credID: []byte("credential-ed"), ed: key}
+}
+
+func (a *softAuthenticator) alg() int64 {
+	if a.ec != nil {
+		return AlgES256
+	}
+	return AlgEdDSA
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	if a.ec != nil {
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		a.ec.X.FillBytes(x)
+		a.ec.Y.FillBytes(y)
+		return encodeCBOR(cborMap{
+			{coseKty, ktyEC2}, {coseAlg, AlgES256}, {coseCrv, crvP256}, {coseX, x}, {coseY, y},
+		})
+	}
+	return encodeCBOR(cborMap{
+		{coseKty, ktyOKP}, {coseAlg, AlgEdDSA}, {coseCrv, crvEd25519}, {coseX, []byte(a.ed.Public().(ed25519.PublicKey))},
+	})
+}
+
+func (a *softAuthenticator) sign(data []byte) []byte {
+	if a.ed != nil {
+		return ed25519.Sign(a.ed, data)
+	}
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, a.ec, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	out := append([]byte{}, rpIDHash[:]...)
+	if attested {
+		flags |= flagAttestedData
+	}
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, a.count)
+
+	if attested {
+		out = append(out, make([]byte, 16)...) // AAGUID
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credID)))
+		out = append(out, a.credID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func clientData(typ, origin string, challenge []byte) []byte {
+	cd, _ := json.Marshal(map[string]any{
+		"type":      typ,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	return cd
+}
+
+// create answers navigator.credentials.create() with packed self attestation or none
+func (a *softAuthenticator) create(rpID, origin string, challenge []byte, format string) AttestationResponse {
+	cd := clientData(clientDataCreate, origin, challenge)
+	authData := a.authData(rpID, flagUserPresent|flagUserVerified, true)
+
+	attStmt := cborMap{}
+	if format == "packed" {
+		cdHash := sha256.Sum256(cd)
+		sig := a.sign(append(append([]byte{}, authData...), cdHash[:]...))
+		attStmt = cborMap{{"alg", a.alg()}, {"sig", sig}}
+	}
+
+	return AttestationResponse{
+		ClientDataJSON: cd,
+		AttestationObject: encodeCBOR(cborMap{
+			{"fmt", format}, {"attStmt", attStmt}, {"authData", authData},
+		}),
+	}
+}
+
+// get answers navigator.credentials.get()
+func (a *softAuthenticator) get(rpID, origin string, challenge []byte, flags byte) AssertionResponse {
+	a.count++
+	cd := clientData(clientDataGet, origin, challenge)
+	authData := a.authData(rpID, flags, false)
+	cdHash := sha256.Sum256(cd)
+
+	return AssertionResponse{
+		ClientDataJSON:    cd,
+		AuthenticatorData: authData,
+		Signature:         a.sign(append(append([]byte{}, authData...), cdHash[:]...)),
+	}
+}
+
+func challenge(t *testing.T) []byte {
+	c := make([]byte, ChallengeSize)
+	if _, err := rand.Read(c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	data := encodeCBOR(cborMap{
+		{"fmt", "none"}, {1, -7}, {-2, []byte{1, 2, 3}}, {"list", []any{1, "a"}},
+	})
+
+	v, n, err := decodeCBOR(append(data, 0xff))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != len(data) {
+		t.Errorf("expected %d bytes read, got %d", len(data), n)
+	}
+
+	m := v.(map[any]any)
+	if m["fmt"] != "none" || m[int64(1)] != int64(-7) || string(m[int64(-2)].([]byte)) != "\x01\x02\x03" {
+		t.Errorf("unexpected map: %#v", m)
+	}
+	if list := m["list"].([]any); len(list) != 2 || list[0] != int64(1) || list[1] != "a" {
+		t.Errorf("unexpected list: %#v", list)
+	}
+}
+
+func TestDecodeCBORRejectsMalformedInput(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		{0x5f},       // indefinite-length byte string
+		{0x45, 1, 2}, // byte string longer than the input
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // huge array
+		{0xa1, 0x40, 0x01}, // byte string map key
+	}
+	for _, in := range inputs {
+		if _, _, err := decodeCBOR(in); err == nil {
+			t.Errorf("expected %x to be rejected", in)
+		}
+	}
+}
+
+func TestRegistrationAndLoginWithSoftwareAuthenticator(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		auth   *softAuthenticator
+		format string
+	}{
+		{"packed ES256", newECAuthenticator(t), "packed"},
+		{"none Ed25519", newEd25519Authenticator(t), "none"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			regChallenge := challenge(t)
+			resp := tc.auth.create(testConfig.RPID, testConfig.Origins[0], regChallenge, tc.format)
+
+			cred, err := verifyRegistration(testConfig, regChallenge, false, resp)
+			if err != nil {
+				t.Fatalf("registration failed: %v", err)
+			}
+			if string(cred.CredentialID) != string(tc.auth.credID) || cred.Algorithm != tc.auth.alg() {
+				t.Errorf("unexpected credential: %+v", cred)
+			}
+			if cred.AttestationFormat != tc.format {
+				t.Errorf("expected format %s, got %s", tc.format, cred.AttestationFormat)
+			}
+
+			loginChallenge := challenge(t)
+			assertion := tc.auth.get(testConfig.RPID, testConfig.Origins[0], loginChallenge, flagUserPresent|flagUserVerified)
+
+			ad, err := verifyAssertion(testConfig, loginChallenge, true, cred, assertion)
+			if err != nil {
+				t.Fatalf("assertion failed: %v", err)
+			}
+			if !ad.UserVerified() || ad.SignCount != 1 {
+				t.Errorf("unexpected authenticator data: %+v", ad)
+			}
+			if !signCountValid(cred.SignCount, ad.SignCount) {
+				t.Errorf("expected sign count to advance")
+			}
+		})
+	}
+}
+
+func TestRegistrationRejectsForgedResponses(t *testing.T) {
+	auth := newECAuthenticator(t)
+	c := challenge(t)
+
+	cases := []struct {
+		name string
+		resp AttestationResponse
+		want error
+	}{
+		{"wrong challenge", auth.create(testConfig.RPID, testConfig.Origins[0], challenge(t), "packed"), ErrChallengeMismatch},
+		{"wrong origin", auth.create(testConfig.RPID, "https://evil.test", c, "packed"), ErrOriginMismatch},
+		{"wrong rp id", auth.create("evil.test", testConfig.Origins[0], c, "packed"), ErrRPIDMismatch},
+		{"unknown format", auth.create(testConfig.RPID, testConfig.Origins[0], c, "tpm"), ErrUnsupportedAttestation},
+	}
+	for _, tc := range cases {
+		if _, err := verifyRegistration(testConfig, c, false, tc.resp); err != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	// Self attestation signed by a different key
+	resp := auth.create(testConfig.RPID, testConfig.Origins[0], c, "packed")
+	other := newECAuthenticator(t)
+	other.credID = auth.credID
+	forged := other.create(testConfig.RPID, testConfig.Origins[0], c, "packed")
+	obj, _, _ := decodeCBOR(forged.AttestationObject)
+	stmt := obj.(map[any]any)["attStmt"].(map[any]any)
+	origObj, _, _ := decodeCBOR(resp.AttestationObject)
+	resp.AttestationObject = encodeCBOR(cborMap{
+		{"fmt", "packed"},
+		{"attStmt", cborMap{{"alg", stmt["alg"]}, {"sig", stmt["sig"]}}},
+		{"authData", origObj.(map[any]any)["authData"]},
+	})
+	if _, err := verifyRegistration(testConfig, c, false, resp); err != ErrInvalidAttestation {
+		t.Errorf("expected forged attestation to be rejected, got %v", err)
+	}
+}
+
+func TestAssertionChecks(t *testing.T) {
+	auth := newECAuthenticator(t)
+	regChallenge := challenge(t)
+	cred, err := verifyRegistration(testConfig, regChallenge, false, auth.create(testConfig.RPID, testConfig.Origins[0], regChallenge, "none"))
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+
+	c := challenge(t)
+
+	// Passwordless login requires user verification
+	resp := auth.get(testConfig.RPID, testConfig.Origins[0], c, flagUserPresent)
+	if _, err := verifyAssertion(testConfig, c, true, cred, resp); err != ErrUserNotVerified {
+		t.Errorf("expected ErrUserNotVerified, got %v", err)
+	}
+	if _, err := verifyAssertion(testConfig, c, false, cred, resp); err != nil {
+		t.Errorf("expected presence alone to be enough as a second factor, got %v", err)
+	}
+
+	// Tampered authenticator data no longer matches the signature
+	resp = auth.get(testConfig.RPID, testConfig.Origins[0], c, flagUserPresent|flagUserVerified)
+	resp.AuthenticatorData[33] ^= 0xff
+	if _, err := verifyAssertion(testConfig, c, false, cred, resp); err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	// A registration response cannot be replayed as an assertion
+	resp = auth.get(testConfig.RPID, testConfig.Origins[0], c, flagUserPresent)
+	resp.ClientDataJSON = clientData(clientDataCreate, testConfig.Origins[0], c)
+	if _, err := verifyAssertion(testConfig, c, false, cred, resp); err != ErrInvalidClientData {
+		t.Errorf("expected ErrInvalidClientData, got %v", err)
+	}
+}
+
+func TestSignCountValid(t *testing.T) {
+	cases := []struct {
+		stored, received uint32
+		want             bool
+	}{
+		{0, 0, true}, // authenticator without a counter
+		{0, 1, true},
+		{5, 6, true},
+		{5, 5, false}, // replayed or cloned
+		{5, 2, false},
+		{5, 0, false},
+	}
+	for _, c := range cases {
+		if got := signCountValid(c.stored, c.received); got != c.want {
+			t.Errorf("signCountValid(%d, %d) = %v, want %v", c.stored, c.received, got, c.want)
+		}
+	}
+}
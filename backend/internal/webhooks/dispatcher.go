@@ -0,0 +1,266 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"telegraph/internal/audit"
+
+	"github.com/google/uuid"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Telegraph-Event"
+	HeaderDelivery  = "X-Telegraph-Delivery"
+	HeaderTimestamp = "X-Telegraph-Timestamp"
+	HeaderSignature = "X-Telegraph-Signature"
+)
+
+// retryInterval is how often the worker looks for deliveries that are due
+const retryInterval = 5 * time.Second
+
+// Dispatcher turns events into signed HTTP deliveries and retries failures
+type Dispatcher struct {
+	subs       SubscriptionRepo
+	deliveries DeliveryRepo
+	client     *http.Client
+}
+
+func NewDispatcher(subs SubscriptionRepo, deliveries DeliveryRepo, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = newGuardedClient()
+	}
+	return &Dispatcher{subs: subs, deliveries: deliveries, client: client}
+}
+
+// Publish queues an event for every subscription that wants it.
+// It returns immediately; deliveries happen in the background.
+func (d *Dispatcher) Publish(ctx context.Context, eventType string, channelID *uuid.UUID, data interface{}) {
+	event := Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		ChannelID: channelID,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	go d.fanOut(event)
+}
+
+// PublishAudit forwards audit events to global subscriptions. It is meant
+// to be registered with audit.Logger.AddHook.
+func (d *Dispatcher) PublishAudit(ctx context.Context, event audit.AuditLog) {
+	d.Publish(ctx, EventAudit, nil, map[string]interface{}{
+		"id":         event.ID,
+		"action":     event.Action,
+		"user_id":    event.UserID,
+		"actor_type": event.ActorType,
+		"resource":   event.Resource,
+		"result":     event.Result,
+		"details":    event.Details,
+		"timestamp":  event.Timestamp,
+	})
+}
+
+func (d *Dispatcher) fanOut(event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	subs, err := d.subs.ListForEvent(ctx, event.ChannelID)
+	if err != nil {
+		log.Printf("webhooks: listing subscriptions for %s: %v", event.Type, err)
+		return
+	}
+
+	for _, sub := range subs {
+		if !sub.Wants(event.Type) {
+			continue
+		}
+		delivery, err := d.enqueue(ctx, sub, event)
+		if err != nil {
+			log.Printf("webhooks: queueing %s for %s: %v", event.Type, sub.ID, err)
+			continue
+		}
+		go d.attempt(context.Background(), sub, delivery)
+	}
+}
+
+// enqueue logs a new delivery. Its first retry is pushed out far enough
+// that the worker won't pick it up while the first attempt is in flight.
+func (d *Dispatcher) enqueue(ctx context.Context, sub *Subscription, event Event) (*Delivery, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &Delivery{
+		SubscriptionID: sub.ID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		Payload:        string(body),
+		Status:         DeliveryPending,
+		NextAttemptAt:  time.Now().Add(2 * RequestTimeout),
+	}
+	if err := d.deliveries.Create(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Ping sends a PING event to the subscription and waits for the result
+func (d *Dispatcher) Ping(ctx context.Context, sub *Subscription) (*Delivery, error) {
+	delivery, err := d.enqueue(ctx, sub, Event{
+		ID:        uuid.New(),
+		Type:      EventPing,
+		ChannelID: sub.ChannelID,
+		Timestamp: time.Now(),
+		Data:      map[string]string{"subscription_id": sub.ID.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, d.attempt(ctx, sub, delivery)
+}
+
+// Redeliver resets a dead delivery and tries it again straight away
+func (d *Dispatcher) Redeliver(ctx context.Context, sub *Subscription, delivery *Delivery) error {
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	delivery.NextAttemptAt = time.Now().Add(2 * RequestTimeout)
+	return d.attempt(ctx, sub, delivery)
+}
+
+// Run retries due deliveries until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.retryDue(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) retryDue(ctx context.Context) {
+	for {
+		delivery, err := d.deliveries.ClaimDue(ctx, time.Now(), 2*RequestTimeout)
+		if err != nil {
+			log.Printf("webhooks: claiming due deliveries: %v", err)
+			return
+		}
+		if delivery == nil {
+			return
+		}
+
+		sub, err := d.subs.GetByID(ctx, delivery.SubscriptionID)
+		if err == ErrSubscriptionNotFound {
+			delivery.Status = DeliveryDead
+			delivery.LastError = "subscription deleted"
+			d.deliveries.Update(ctx, delivery)
+			continue
+		}
+		if err != nil {
+			log.Printf("webhooks: loading subscription %s: %v", delivery.SubscriptionID, err)
+			return
+		}
+
+		d.attempt(ctx, sub, delivery)
+	}
+}
+
+// attempt makes one delivery attempt and records the outcome. A failed
+// attempt is rescheduled with exponential backoff until MaxAttempts, after
+// which the delivery is dead-lettered.
+func (d *Dispatcher) attempt(ctx context.Context, sub *Subscription, delivery *Delivery) error {
+	delivery.Attempts++
+	status, sendErr := d.send(ctx, sub, delivery)
+	delivery.LastStatusCode = status
+
+	now := time.Now()
+	if sendErr == nil {
+		delivery.Status = DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = sendErr.Error()
+		if delivery.Attempts >= MaxAttempts {
+			delivery.Status = DeliveryDead
+		} else {
+			delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+		}
+	}
+
+	if err := d.deliveries.Update(ctx, delivery); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *Subscription, delivery *Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Telegraph-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, deliveryError(err)
+	}
+	// Only the status code is kept; the response body is never stored
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>" with the
+// subscription secret. Receivers recompute it to verify a delivery.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns the wait before the retry that follows the given attempt
+func Backoff(attempt int) time.Duration {
+	wait := InitialBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if wait >= MaxBackoff {
+			return MaxBackoff
+		}
+	}
+	return wait
+}
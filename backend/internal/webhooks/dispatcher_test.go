@@ -0,0 +1,155 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryDeliveryRepo keeps deliveries in memory for tests
+type memoryDeliveryRepo struct {
+	deliveries map[uuid.UUID]*Delivery
+}
+
+func newMemoryDeliveryRepo() *memoryDeliveryRepo {
+	return &memoryDeliveryRepo{deliveries: map[uuid.UUID]*Delivery{}}
+}
+
+func (r *memoryDeliveryRepo) Create(ctx context.Context, d *Delivery) error {
+	d.ID = uuid.New()
+	d.CreatedAt = time.Now()
+	r.deliveries[d.ID] = d
+	return nil
+}
+
+func (r *memoryDeliveryRepo) GetByID(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	d, ok := r.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return d, nil
+}
+
+func (r *memoryDeliveryRepo) Update(ctx context.Context, d *Delivery) error {
+	r.deliveries[d.ID] = d
+	return nil
+}
+
+func (r *memoryDeliveryRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*Delivery, error) {
+	return nil, nil
+}
+
+func (r *memoryDeliveryRepo) List(ctx context.Context, subscriptionID uuid.UUID, status DeliveryStatus, limit int) ([]*Delivery, error) {
+	return nil, nil
+}
+
+func TestDispatcher_PingSignsPayload(t *testing.T) {
+	sub := &Subscription{ID: uuid.New(), Secret: "s3cret"}
+
+	var gotSignature, wantSignature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotSignature = r.Header.Get(HeaderSignature)
+		wantSignature = "sha256=" + Sign(sub.Secret, r.Header.Get(HeaderTimestamp), body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	sub.URL = server.URL
+
+	d := NewDispatcher(nil, newMemoryDeliveryRepo(), server.Client())
+	delivery, err := d.Ping(context.Background(), sub)
+	if err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+
+	if gotSignature == "" || gotSignature != wantSignature {
+		t.Errorf("signature = %q, want %q", gotSignature, wantSignature)
+	}
+	if delivery.Status != DeliveryDelivered || delivery.LastStatusCode != http.StatusNoContent {
+		t.Errorf("delivery = %s/%d, want delivered/204", delivery.Status, delivery.LastStatusCode)
+	}
+}
+
+func TestDispatcher_RetriesThenDeadLetters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sub := &Subscription{ID: uuid.New(), URL: server.URL, Secret: "s3cret"}
+	d := NewDispatcher(nil, newMemoryDeliveryRepo(), server.Client())
+
+	delivery, err := d.Ping(context.Background(), sub)
+	if err == nil {
+		t.Fatal("expected ping to a failing endpoint to return an error")
+	}
+	if delivery.Status != DeliveryPending || delivery.Attempts != 1 {
+		t.Fatalf("after first failure: status=%s attempts=%d", delivery.Status, delivery.Attempts)
+	}
+	if wait := time.Until(delivery.NextAttemptAt); wait < InitialBackoff-time.Second || wait > InitialBackoff {
+		t.Errorf("next attempt in %v, want about %v", wait, InitialBackoff)
+	}
+
+	for delivery.Attempts < MaxAttempts {
+		d.attempt(context.Background(), sub, delivery)
+	}
+	if delivery.Status != DeliveryDead {
+		t.Errorf("after %d attempts status = %s, want dead", MaxAttempts, delivery.Status)
+	}
+	if delivery.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("last status code = %d, want 500", delivery.LastStatusCode)
+	}
+}
+
+func TestBackoff_Capped(t *testing.T) {
+	if got := Backoff(1); got != InitialBackoff {
+		t.Errorf("Backoff(1) = %v, want %v", got, InitialBackoff)
+	}
+	if got := Backoff(3); got != 4*InitialBackoff {
+		t.Errorf("Backoff(3) = %v, want %v", got, 4*InitialBackoff)
+	}
+	if got := Backoff(50); got != MaxBackoff {
+		t.Errorf("Backoff(50) = %v, want %v", got, MaxBackoff)
+	}
+}
+
+func TestBlockedIP(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0", "::1", "fe80::1", "fc00::1", "224.0.0.1", "::ffff:127.0.0.1"} {
+		if !blockedIP(net.ParseIP(addr)) {
+			t.Errorf("%s should be blocked", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "2606:4700::1111"} {
+		if blockedIP(net.ParseIP(addr)) {
+			t.Errorf("%s should be allowed", addr)
+		}
+	}
+}
+
+func TestDispatcher_RefusesLoopbackAtDialTime(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	sub := &Subscription{ID: uuid.New(), URL: server.URL, Secret: "s3cret"}
+	d := NewDispatcher(nil, newMemoryDeliveryRepo(), nil)
+
+	delivery, err := d.Ping(context.Background(), sub)
+	if err != ErrBlockedAddress {
+		t.Fatalf("ping err = %v, want %v", err, ErrBlockedAddress)
+	}
+	if called {
+		t.Error("loopback server was reached")
+	}
+	if delivery.LastError != ErrBlockedAddress.Error() {
+		t.Errorf("last error = %q", delivery.LastError)
+	}
+}
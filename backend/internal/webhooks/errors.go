@@ -0,0 +1,17 @@
+package webhooks
+
+import "errors"
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrInvalidURL           = errors.New("webhook url must be an absolute http or https url")
+	ErrBlockedAddress       = errors.New("webhook url must not point at a private or local address")
+	ErrUnknownEvent         = errors.New("unknown webhook event type")
+	ErrNotAllowed           = errors.New("not allowed to manage this webhook")
+	ErrNotDead              = errors.New("only dead deliveries can be redelivered")
+	ErrHookNotFound         = errors.New("incoming webhook not found")
+	ErrInvalidHookToken     = errors.New("invalid incoming webhook token")
+	ErrHookNameRequired     = errors.New("incoming webhook name is required")
+	ErrEmptyMessage         = errors.New("message text is required")
+)
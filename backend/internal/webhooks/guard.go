@@ -0,0 +1,95 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// blockedIP reports whether a webhook may not be delivered to ip. Anything
+// that reaches the server itself or the network it sits on is refused so a
+// subscription can't be used to probe internal services.
+func blockedIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		return true // 0.0.0.0/8 is routed to the local host
+	}
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified()
+}
+
+// checkHost resolves host and fails if any of its addresses is blocked
+func checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if blockedIP(ip) {
+			return ErrBlockedAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return ErrInvalidURL
+	}
+	for _, addr := range addrs {
+		if blockedIP(addr.IP) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// dialControl runs after DNS resolution for every connection, including
+// redirects, so a host that re-resolves to an internal address is caught.
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrBlockedAddress
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || blockedIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// newGuardedClient returns the client used for deliveries. It ignores proxy
+// settings since the proxy would be dialed instead of the subscriber.
+func newGuardedClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: RequestTimeout,
+		Control: dialControl,
+	}
+	return &http.Client{
+		Timeout: RequestTimeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: RequestTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// deliveryError is what gets recorded for a failed request. Network errors
+// are reduced to a generic reason so the delivery log can't be used to map
+// what is listening behind the subscriber's host.
+func deliveryError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, ErrBlockedAddress):
+		return ErrBlockedAddress
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("request timed out")
+	default:
+		return errors.New("request failed")
+	}
+}
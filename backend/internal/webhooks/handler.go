@@ -0,0 +1,332 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"telegraph/internal/channels"
+	"telegraph/internal/messages"
+	"telegraph/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	service  WebhookService
+	incoming IncomingService
+}
+
+func NewHandler(service WebhookService, incoming IncomingService) *Handler {
+	return &Handler{service: service, incoming: incoming}
+}
+
+func (h *Handler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListSubscriptions)
+	r.Post("/", h.CreateSubscription)
+	r.Delete("/{id}", h.DeleteSubscription)
+	r.Post("/{id}/ping", h.Ping)
+	r.Get("/{id}/deliveries", h.ListDeliveries)
+	r.Get("/{id}/dead-letters", h.ListDeadLetters)
+	r.Post("/{id}/deliveries/{deliveryId}/redeliver", h.Redeliver)
+
+	// Incoming webhooks
+	r.Get("/incoming", h.ListIncomingHooks)
+	r.Post("/incoming", h.CreateIncomingHook)
+	r.Delete("/incoming/{id}", h.RevokeIncomingHook)
+
+	return r
+}
+
+func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var req CreateSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sub, secret, err := h.service.CreateSubscription(r.Context(), req, user.ID, user.Role)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	// The secret is only shown once
+	respondJSON(w, map[string]any{
+		"subscription": sub,
+		"secret":       secret,
+	}, http.StatusCreated)
+}
+
+// ListSubscriptions lists a channel's webhooks (?channel_id=) or the global ones
+func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	var channelID *uuid.UUID
+	if raw := r.URL.Query().Get("channel_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			respondError(w, "invalid_channel_id", http.StatusBadRequest)
+			return
+		}
+		channelID = &id
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	subs, err := h.service.ListSubscriptions(r.Context(), channelID, user.ID, user.Role)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	respondJSON(w, subs, http.StatusOK)
+}
+
+func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_webhook_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteSubscription(r.Context(), id, user.ID, user.Role); err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "webhook_deleted"}, http.StatusOK)
+}
+
+func (h *Handler) Ping(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_webhook_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	delivery, err := h.service.Ping(r.Context(), id, user.ID, user.Role)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	respondJSON(w, delivery, http.StatusOK)
+}
+
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	h.listDeliveries(w, r, DeliveryStatus(r.URL.Query().Get("status")))
+}
+
+func (h *Handler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.listDeliveries(w, r, DeliveryDead)
+}
+
+func (h *Handler) listDeliveries(w http.ResponseWriter, r *http.Request, status DeliveryStatus) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_webhook_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deliveries, err := h.service.ListDeliveries(r.Context(), id, status, user.ID, user.Role)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	respondJSON(w, deliveries, http.StatusOK)
+}
+
+func (h *Handler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_webhook_id", http.StatusBadRequest)
+		return
+	}
+	deliveryID, err := uuid.Parse(chi.URLParam(r, "deliveryId"))
+	if err != nil {
+		respondError(w, "invalid_delivery_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	delivery, err := h.service.Redeliver(r.Context(), id, deliveryID, user.ID, user.Role)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	respondJSON(w, delivery, http.StatusOK)
+}
+
+func (h *Handler) CreateIncomingHook(w http.ResponseWriter, r *http.Request) {
+	var req CreateIncomingHookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hook, token, err := h.incoming.CreateHook(r.Context(), req, user.ID, user.Role)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	// The token is only shown once; it is part of the posting URL
+	respondJSON(w, map[string]any{
+		"hook":  hook,
+		"token": token,
+		"path":  "/api/v1/hooks/" + hook.ID.String() + "/" + token,
+	}, http.StatusCreated)
+}
+
+// ListIncomingHooks lists the incoming webhooks of ?channel_id=
+func (h *Handler) ListIncomingHooks(w http.ResponseWriter, r *http.Request) {
+	channelID, err := uuid.Parse(r.URL.Query().Get("channel_id"))
+	if err != nil {
+		respondError(w, "invalid_channel_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hooks, err := h.incoming.ListHooks(r.Context(), channelID, user.ID, user.Role)
+	if err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	respondJSON(w, hooks, http.StatusOK)
+}
+
+func (h *Handler) RevokeIncomingHook(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, "invalid_hook_id", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		respondError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.incoming.RevokeHook(r.Context(), id, user.ID, user.Role); err != nil {
+		respondWebhookError(w, err)
+		return
+	}
+
+	respondJSON(w, map[string]string{"message": "hook_revoked"}, http.StatusOK)
+}
+
+// PostIncoming is the public endpoint external systems call. The token in
+// the URL is the only credential.
+func (h *Handler) PostIncoming(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, ErrInvalidHookToken.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var msg IncomingMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+		respondError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.incoming.Post(r.Context(), id, chi.URLParam(r, "token"), msg)
+	if err != nil {
+		var rateErr *messages.RateLimitError
+		if errors.As(err, &rateErr) {
+			w.Header().Set("Retry-After", strconv.Itoa(rateErr.RetryAfterSeconds()))
+			respondError(w, "rate_limited", http.StatusTooManyRequests)
+			return
+		}
+		switch err {
+		case ErrInvalidHookToken:
+			respondError(w, err.Error(), http.StatusUnauthorized)
+		case messages.ErrIntegrationsDisabled:
+			respondError(w, err.Error(), http.StatusForbidden)
+		case ErrEmptyMessage, messages.ErrContentTooLarge:
+			respondError(w, err.Error(), http.StatusBadRequest)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respondJSON(w, map[string]string{"message_id": message.ID.String()}, http.StatusCreated)
+}
+
+func respondWebhookError(w http.ResponseWriter, err error) {
+	switch err {
+	case ErrInvalidURL, ErrBlockedAddress, ErrUnknownEvent, ErrNotDead, ErrHookNameRequired:
+		respondError(w, err.Error(), http.StatusBadRequest)
+	case ErrNotAllowed:
+		respondError(w, err.Error(), http.StatusForbidden)
+	case ErrSubscriptionNotFound, ErrDeliveryNotFound, ErrHookNotFound, channels.ErrChannelNotFound:
+		respondError(w, err.Error(), http.StatusNotFound)
+	default:
+		respondError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Helper functions
+func respondError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func respondJSON(w http.ResponseWriter, data interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
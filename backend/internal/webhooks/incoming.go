@@ -0,0 +1,150 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"telegraph/internal/audit"
+	"telegraph/internal/channels"
+	"telegraph/internal/messages"
+	"telegraph/internal/ratelimit"
+
+	"github.com/google/uuid"
+)
+
+type IncomingService interface {
+	CreateHook(ctx context.Context, req CreateIncomingHookRequest, userID uuid.UUID, userRole string) (*IncomingHook, string, error)
+	ListHooks(ctx context.Context, channelID, userID uuid.UUID, userRole string) ([]*IncomingHook, error)
+	RevokeHook(ctx context.Context, id, userID uuid.UUID, userRole string) error
+	Post(ctx context.Context, id uuid.UUID, token string, msg IncomingMessage) (*messages.Message, error)
+}
+
+type incomingService struct {
+	repo        IncomingHookRepo
+	messageSvc  messages.MessageService
+	channelRepo channels.ChannelRepo
+	audit       *audit.Logger
+	limiter     *ratelimit.Limiter
+}
+
+func NewIncomingService(repo IncomingHookRepo, messageSvc messages.MessageService, channelRepo channels.ChannelRepo, audit *audit.Logger) IncomingService {
+	return &incomingService{
+		repo:        repo,
+		messageSvc:  messageSvc,
+		channelRepo: channelRepo,
+		audit:       audit,
+		limiter:     ratelimit.NewLimiter(),
+	}
+}
+
+// CreateHook creates an incoming webhook and returns its token.
+// The token is part of the posting URL and is only ever returned here.
+func (s *incomingService) CreateHook(ctx context.Context, req CreateIncomingHookRequest, userID uuid.UUID, userRole string) (*IncomingHook, string, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, "", ErrHookNameRequired
+	}
+
+	if err := authorize(ctx, s.channelRepo, &req.ChannelID, userID, userRole); err != nil {
+		return nil, "", err
+	}
+
+	token, err := randomSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	hook := &IncomingHook{
+		ChannelID: req.ChannelID,
+		Name:      req.Name,
+		TokenHash: hashToken(token),
+		CreatedBy: userID,
+	}
+	if err := s.repo.Create(ctx, hook); err != nil {
+		return nil, "", err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventIncomingHookCreated,
+		Resource: hook.ID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Created incoming webhook %q for channel %s", hook.Name, hook.ChannelID),
+	})
+
+	return hook, token, nil
+}
+
+func (s *incomingService) ListHooks(ctx context.Context, channelID, userID uuid.UUID, userRole string) ([]*IncomingHook, error) {
+	if err := authorize(ctx, s.channelRepo, &channelID, userID, userRole); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByChannel(ctx, channelID)
+}
+
+func (s *incomingService) RevokeHook(ctx context.Context, id, userID uuid.UUID, userRole string) error {
+	hook, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, s.channelRepo, &hook.ChannelID, userID, userRole); err != nil {
+		return err
+	}
+
+	if err := s.repo.Revoke(ctx, id); err != nil {
+		return err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventIncomingHookRevoked,
+		Resource: hook.ID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Revoked incoming webhook %q for channel %s", hook.Name, hook.ChannelID),
+	})
+
+	return nil
+}
+
+// Post creates a message from an external system. The message goes through
+// the same fan-out and audit path as messages sent by members.
+func (s *incomingService) Post(ctx context.Context, id uuid.UUID, token string, msg IncomingMessage) (*messages.Message, error) {
+	hook, err := s.repo.GetByID(ctx, id)
+	if err == ErrHookNotFound {
+		return nil, ErrInvalidHookToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Revoked hooks and wrong tokens look the same to the caller
+	if hook.Revoked || subtle.ConstantTimeCompare([]byte(hook.TokenHash), []byte(hashToken(token))) != 1 {
+		return nil, ErrInvalidHookToken
+	}
+
+	if strings.TrimSpace(msg.Text) == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	key := "hook:" + hook.ID.String()
+	if ok, wait := s.limiter.Allow(key, IncomingRateLimit, IncomingRateWindow); !ok {
+		return nil, &messages.RateLimitError{RetryAfter: wait}
+	}
+
+	message, err := s.messageSvc.PostIntegrationMessage(ctx, hook.ChannelID, hook.ID, hook.Name, msg.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.repo.Touch(ctx, hook.ID)
+	return message, nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types that can be subscribed to
+const (
+	EventMessageNew     = "MESSAGE_NEW"
+	EventMemberJoined   = "MEMBER_JOINED"
+	EventMemberLeft     = "MEMBER_LEFT"
+	EventChannelUpdated = "CHANNEL_UPDATED"
+	EventChannelDeleted = "CHANNEL_DELETED"
+	EventAudit          = "AUDIT"
+	EventPing           = "PING"
+)
+
+// knownEvents lists the events a subscription may ask for
+var knownEvents = map[string]bool{
+	EventMessageNew:     true,
+	EventMemberJoined:   true,
+	EventMemberLeft:     true,
+	EventChannelUpdated: true,
+	EventChannelDeleted: true,
+	EventAudit:          true,
+}
+
+// Retry policy for failed deliveries
+const (
+	MaxAttempts    = 6
+	InitialBackoff = 10 * time.Second
+	MaxBackoff     = 30 * time.Minute
+	RequestTimeout = 10 * time.Second
+)
+
+// DeliveryStatus tracks a single delivery through its retries
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead" // Gave up after MaxAttempts
+)
+
+// Subscription sends events to an external URL. Channel subscriptions only
+// see events from their channel; global ones (ChannelID nil) see everything,
+// including audit events.
+type Subscription struct {
+	ID        uuid.UUID  `json:"id" bson:"_id"`
+	ChannelID *uuid.UUID `json:"channel_id,omitempty" bson:"channel_id"`
+	URL       string     `json:"url" bson:"url"`
+	Secret    string     `json:"-" bson:"secret"`
+	Events    []string   `json:"events" bson:"events"` // Empty means all events
+	CreatedBy uuid.UUID  `json:"created_by" bson:"created_by"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+}
+
+// Wants reports whether the subscription should receive the event type
+func (s *Subscription) Wants(eventType string) bool {
+	if eventType == EventPing || len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the JSON body POSTed to subscribers
+type Event struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	ChannelID *uuid.UUID  `json:"channel_id,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Delivery is the log entry for one event sent to one subscription.
+// The payload is stored as sent so retries are byte-for-byte identical.
+type Delivery struct {
+	ID             uuid.UUID      `json:"id" bson:"_id"`
+	SubscriptionID uuid.UUID      `json:"subscription_id" bson:"subscription_id"`
+	EventID        uuid.UUID      `json:"event_id" bson:"event_id"`
+	EventType      string         `json:"event_type" bson:"event_type"`
+	Payload        string         `json:"payload" bson:"payload"`
+	Status         DeliveryStatus `json:"status" bson:"status"`
+	Attempts       int            `json:"attempts" bson:"attempts"`
+	LastStatusCode int            `json:"last_status_code,omitempty" bson:"last_status_code,omitempty"`
+	LastError      string         `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at" bson:"next_attempt_at"`
+	CreatedAt      time.Time      `json:"created_at" bson:"created_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+}
+
+// CreateSubscriptionRequest is the payload for creating a subscription.
+// Omitting channel_id creates a global subscription (admins only).
+type CreateSubscriptionRequest struct {
+	ChannelID *uuid.UUID `json:"channel_id,omitempty"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+}
+
+// Incoming webhook limits
+const (
+	IncomingRateLimit  = 20 // Messages per hook per IncomingRateWindow
+	IncomingRateWindow = time.Minute
+)
+
+// IncomingHook lets an external system post plaintext messages into a
+// channel as a named integration. Only the token hash is stored.
+type IncomingHook struct {
+	ID         uuid.UUID  `json:"id" bson:"_id"`
+	ChannelID  uuid.UUID  `json:"channel_id" bson:"channel_id"`
+	Name       string     `json:"name" bson:"name"` // Shown as the sender
+	TokenHash  string     `json:"-" bson:"token_hash"`
+	CreatedBy  uuid.UUID  `json:"created_by" bson:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" bson:"last_used_at,omitempty"`
+	Revoked    bool       `json:"revoked" bson:"revoked"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// CreateIncomingHookRequest is the payload for creating an incoming webhook
+type CreateIncomingHookRequest struct {
+	ChannelID uuid.UUID `json:"channel_id"`
+	Name      string    `json:"name"`
+}
+
+// IncomingMessage is the body external systems POST to an incoming webhook
+type IncomingMessage struct {
+	Text string `json:"text"`
+}
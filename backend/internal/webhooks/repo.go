@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SubscriptionRepo interface {
+	Create(ctx context.Context, sub *Subscription) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Subscription, error)
+	List(ctx context.Context, channelID *uuid.UUID) ([]*Subscription, error)
+	ListForEvent(ctx context.Context, channelID *uuid.UUID) ([]*Subscription, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type DeliveryRepo interface {
+	Create(ctx context.Context, d *Delivery) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Delivery, error)
+	Update(ctx context.Context, d *Delivery) error
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*Delivery, error)
+	List(ctx context.Context, subscriptionID uuid.UUID, status DeliveryStatus, limit int) ([]*Delivery, error)
+}
+
+type IncomingHookRepo interface {
+	Create(ctx context.Context, hook *IncomingHook) error
+	GetByID(ctx context.Context, id uuid.UUID) (*IncomingHook, error)
+	ListByChannel(ctx context.Context, channelID uuid.UUID) ([]*IncomingHook, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	Touch(ctx context.Context, id uuid.UUID) error
+}
+
+type mongoSubscriptionRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSubscriptionRepo(db *mongo.Database) SubscriptionRepo {
+	return &mongoSubscriptionRepo{
+		collection: db.Collection("webhook_subscriptions"),
+	}
+}
+
+func (r *mongoSubscriptionRepo) Create(ctx context.Context, sub *Subscription) error {
+	sub.ID = uuid.New()
+	sub.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, sub)
+	return err
+}
+
+func (r *mongoSubscriptionRepo) GetByID(ctx context.Context, id uuid.UUID) (*Subscription, error) {
+	var sub Subscription
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&sub)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// List returns the subscriptions of one channel, or the global ones when channelID is nil
+func (r *mongoSubscriptionRepo) List(ctx context.Context, channelID *uuid.UUID) ([]*Subscription, error) {
+	return r.find(ctx, bson.M{"channel_id": channelID})
+}
+
+// ListForEvent returns every subscription that can see an event from the
+// given channel: the channel's own plus all global ones
+func (r *mongoSubscriptionRepo) ListForEvent(ctx context.Context, channelID *uuid.UUID) ([]*Subscription, error) {
+	filter := bson.M{"channel_id": nil}
+	if channelID != nil {
+		filter = bson.M{"$or": []bson.M{
+			{"channel_id": nil},
+			{"channel_id": *channelID},
+		}}
+	}
+	return r.find(ctx, filter)
+}
+
+func (r *mongoSubscriptionRepo) find(ctx context.Context, filter bson.M) ([]*Subscription, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var subs []*Subscription
+	if err := cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func (r *mongoSubscriptionRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+type mongoDeliveryRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDeliveryRepo(db *mongo.Database) DeliveryRepo {
+	return &mongoDeliveryRepo{
+		collection: db.Collection("webhook_deliveries"),
+	}
+}
+
+func (r *mongoDeliveryRepo) Create(ctx context.Context, d *Delivery) error {
+	d.ID = uuid.New()
+	d.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, d)
+	return err
+}
+
+func (r *mongoDeliveryRepo) GetByID(ctx context.Context, id uuid.UUID) (*Delivery, error) {
+	var d Delivery
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *mongoDeliveryRepo) Update(ctx context.Context, d *Delivery) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": d.ID}, d)
+	return err
+}
+
+// ClaimDue atomically takes one pending delivery whose retry time has come
+// and pushes its next attempt out by lease, so concurrent workers skip it.
+// Returns nil when nothing is due.
+func (r *mongoDeliveryRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*Delivery, error) {
+	filter := bson.M{
+		"status":          DeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var d Delivery
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *mongoDeliveryRepo) List(ctx context.Context, subscriptionID uuid.UUID, status DeliveryStatus, limit int) ([]*Delivery, error) {
+	filter := bson.M{"subscription_id": subscriptionID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []*Delivery
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+type mongoIncomingHookRepo struct {
+	collection *mongo.Collection
+}
+
+func NewMongoIncomingHookRepo(db *mongo.Database) IncomingHookRepo {
+	return &mongoIncomingHookRepo{
+		collection: db.Collection("incoming_webhooks"),
+	}
+}
+
+func (r *mongoIncomingHookRepo) Create(ctx context.Context, hook *IncomingHook) error {
+	hook.ID = uuid.New()
+	hook.CreatedAt = time.Now()
+
+	_, err := r.collection.InsertOne(ctx, hook)
+	return err
+}
+
+func (r *mongoIncomingHookRepo) GetByID(ctx context.Context, id uuid.UUID) (*IncomingHook, error) {
+	var hook IncomingHook
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&hook)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrHookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (r *mongoIncomingHookRepo) ListByChannel(ctx context.Context, channelID uuid.UUID) ([]*IncomingHook, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := r.collection.Find(ctx, bson.M{"channel_id": channelID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var hooks []*IncomingHook
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (r *mongoIncomingHookRepo) Revoke(ctx context.Context, id uuid.UUID) error {
+	filter := bson.M{"_id": id, "revoked": false}
+	update := bson.M{"$set": bson.M{"revoked": true, "revoked_at": time.Now()}}
+	res, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrHookNotFound
+	}
+	return nil
+}
+
+func (r *mongoIncomingHookRepo) Touch(ctx context.Context, id uuid.UUID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": time.Now()}})
+	return err
+}
@@ -0,0 +1,211 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+
+	"telegraph/internal/acl"
+	"telegraph/internal/audit"
+	"telegraph/internal/channels"
+
+	"github.com/google/uuid"
+)
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, req CreateSubscriptionRequest, userID uuid.UUID, userRole string) (*Subscription, string, error)
+	ListSubscriptions(ctx context.Context, channelID *uuid.UUID, userID uuid.UUID, userRole string) ([]*Subscription, error)
+	DeleteSubscription(ctx context.Context, id, userID uuid.UUID, userRole string) error
+	Ping(ctx context.Context, id, userID uuid.UUID, userRole string) (*Delivery, error)
+	ListDeliveries(ctx context.Context, id uuid.UUID, status DeliveryStatus, userID uuid.UUID, userRole string) ([]*Delivery, error)
+	Redeliver(ctx context.Context, id, deliveryID, userID uuid.UUID, userRole string) (*Delivery, error)
+}
+
+type webhookService struct {
+	subs        SubscriptionRepo
+	deliveries  DeliveryRepo
+	dispatcher  *Dispatcher
+	channelRepo channels.ChannelRepo
+	audit       *audit.Logger
+}
+
+func NewWebhookService(subs SubscriptionRepo, deliveries DeliveryRepo, dispatcher *Dispatcher, channelRepo channels.ChannelRepo, audit *audit.Logger) WebhookService {
+	return &webhookService{subs: subs, deliveries: deliveries, dispatcher: dispatcher, channelRepo: channelRepo, audit: audit}
+}
+
+// CreateSubscription registers a URL and returns the signing secret.
+// The secret is only ever returned here.
+func (s *webhookService) CreateSubscription(ctx context.Context, req CreateSubscriptionRequest, userID uuid.UUID, userRole string) (*Subscription, string, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", ErrInvalidURL
+	}
+	if err := checkHost(ctx, u.Hostname()); err != nil {
+		return nil, "", err
+	}
+	for _, e := range req.Events {
+		if !knownEvents[e] {
+			return nil, "", ErrUnknownEvent
+		}
+	}
+
+	if err := s.authorize(ctx, req.ChannelID, userID, userRole); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := randomSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	sub := &Subscription{
+		ChannelID: req.ChannelID,
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    req.Events,
+		CreatedBy: userID,
+	}
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	if err := s.subs.Create(ctx, sub); err != nil {
+		return nil, "", err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventWebhookCreated,
+		Resource: sub.ID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Created webhook to %s (%s)", u.Host, scope(sub.ChannelID)),
+	})
+
+	return sub, secret, nil
+}
+
+func (s *webhookService) ListSubscriptions(ctx context.Context, channelID *uuid.UUID, userID uuid.UUID, userRole string) ([]*Subscription, error) {
+	if err := s.authorize(ctx, channelID, userID, userRole); err != nil {
+		return nil, err
+	}
+	return s.subs.List(ctx, channelID)
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id, userID uuid.UUID, userRole string) error {
+	sub, err := s.getAuthorized(ctx, id, userID, userRole)
+	if err != nil {
+		return err
+	}
+
+	if err := s.subs.Delete(ctx, sub.ID); err != nil {
+		return err
+	}
+
+	s.audit.Log(ctx, audit.AuditLog{
+		UserID:   &userID,
+		Action:   audit.EventWebhookDeleted,
+		Resource: sub.ID.String(),
+		Result:   "success",
+		Details:  fmt.Sprintf("Deleted webhook (%s)", scope(sub.ChannelID)),
+	})
+
+	return nil
+}
+
+// Ping sends a test event. Delivery failures are reported in the returned
+// delivery rather than as an error.
+func (s *webhookService) Ping(ctx context.Context, id, userID uuid.UUID, userRole string) (*Delivery, error) {
+	sub, err := s.getAuthorized(ctx, id, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.dispatcher.Ping(ctx, sub)
+	if delivery == nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, id uuid.UUID, status DeliveryStatus, userID uuid.UUID, userRole string) ([]*Delivery, error) {
+	sub, err := s.getAuthorized(ctx, id, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+	return s.deliveries.List(ctx, sub.ID, status, 100)
+}
+
+// Redeliver retries a dead-lettered delivery
+func (s *webhookService) Redeliver(ctx context.Context, id, deliveryID, userID uuid.UUID, userRole string) (*Delivery, error) {
+	sub, err := s.getAuthorized(ctx, id, userID, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.deliveries.GetByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.SubscriptionID != sub.ID {
+		return nil, ErrDeliveryNotFound
+	}
+	if delivery.Status != DeliveryDead {
+		return nil, ErrNotDead
+	}
+
+	// The outcome is recorded on the delivery itself
+	_ = s.dispatcher.Redeliver(ctx, sub, delivery)
+	return delivery, nil
+}
+
+func (s *webhookService) getAuthorized(ctx context.Context, id, userID uuid.UUID, userRole string) (*Subscription, error) {
+	sub, err := s.subs.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, sub.ChannelID, userID, userRole); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *webhookService) authorize(ctx context.Context, channelID *uuid.UUID, userID uuid.UUID, userRole string) error {
+	return authorize(ctx, s.channelRepo, channelID, userID, userRole)
+}
+
+// authorize allows channel owners to manage their channel's webhooks and
+// admins to manage any webhook, including global ones
+func authorize(ctx context.Context, channelRepo channels.ChannelRepo, channelID *uuid.UUID, userID uuid.UUID, userRole string) error {
+	if acl.HasPermission(userRole, acl.PermissionManageWebhooks) {
+		return nil
+	}
+	if channelID == nil {
+		return ErrNotAllowed
+	}
+
+	channel, err := channelRepo.GetByID(ctx, *channelID)
+	if err != nil {
+		return err
+	}
+	if channel.OwnerID != userID {
+		return ErrNotAllowed
+	}
+	return nil
+}
+
+func scope(channelID *uuid.UUID) string {
+	if channelID == nil {
+		return "global"
+	}
+	return "channel " + channelID.String()
+}
+
+// randomSecret returns 32 random bytes, hex encoded
+func randomSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -3,6 +3,7 @@ package ws
 import (
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -43,6 +44,22 @@ type Client struct {
 
 	// User ID associated with this client
 	userID string
+
+	// Device the connection belongs to ("" for clients without one, e.g. bots)
+	deviceID string
+
+	// Login session (refresh token family) the connection was opened from
+	sessionID string
+
+	// Close reason handed to the write pump by shutdown
+	closing   chan string
+	closeOnce sync.Once
+}
+
+// shutdown asks the write pump to flush what is already queued and then end
+// the connection with a close frame carrying reason
+func (c *Client) shutdown(reason string) {
+	c.closeOnce.Do(func() { c.closing <- reason })
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -107,6 +124,20 @@ func (c *Client) writePump() {
 			if err := w.Close(); err != nil {
 				return
 			}
+		case reason := <-c.closing:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			n := len(c.send)
+			for i := 0; i < n; i++ {
+				message, ok := <-c.send
+				if !ok {
+					break
+				}
+				if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+					return
+				}
+			}
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason))
+			return
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
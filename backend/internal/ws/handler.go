@@ -6,7 +6,7 @@ import (
 )
 
 // ServeWs handles websocket requests from the peer.
-func ServeWs(hub *Hub, userID string) http.HandlerFunc {
+func ServeWs(hub *Hub, userID, deviceID, sessionID string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
@@ -14,10 +14,13 @@ func ServeWs(hub *Hub, userID string) http.HandlerFunc {
 			return
 		}
 		client := &Client{
-			hub:    hub,
-			conn:   conn,
-			send:   make(chan []byte, 256),
-			userID: userID,
+			hub:       hub,
+			conn:      conn,
+			send:      make(chan []byte, 256),
+			userID:    userID,
+			deviceID:  deviceID,
+			sessionID: sessionID,
+			closing:   make(chan string, 1),
 		}
 		client.hub.register <- client
 
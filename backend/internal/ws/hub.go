@@ -129,6 +129,90 @@ func (h *Hub) SendToUser(userID string, message interface{}) {
 	}
 }
 
+// SendToUsers sends the same message to many users, marshaling it only once.
+// Used for channel fan-out where member lists can be large.
+func (h *Hub) SendToUsers(userIDs []string, message interface{}) {
+	msgBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	h.userLock.RLock()
+	defer h.userLock.RUnlock()
+
+	for _, userID := range userIDs {
+		for client := range h.userClients[userID] {
+			select {
+			case client.send <- msgBytes:
+			default:
+				log.Printf("Failed to send to client for user %s", userID)
+			}
+		}
+	}
+}
+
+// SendToDevice sends a message only to the connections of one of a user's devices
+func (h *Hub) SendToDevice(userID, deviceID string, message interface{}) {
+	msgBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
+		return
+	}
+
+	h.userLock.RLock()
+	defer h.userLock.RUnlock()
+
+	for client := range h.userClients[userID] {
+		if client.deviceID != deviceID {
+			continue
+		}
+		select {
+		case client.send <- msgBytes:
+		default:
+			log.Printf("Failed to send to device %s of user %s", deviceID, userID)
+		}
+	}
+}
+
+// DisconnectDevice closes every connection of a device once messages already
+// sent to it are flushed. The read pumps then unregister the clients as usual.
+func (h *Hub) DisconnectDevice(userID, deviceID string) {
+	h.userLock.RLock()
+	defer h.userLock.RUnlock()
+
+	for client := range h.userClients[userID] {
+		if client.deviceID == deviceID {
+			client.shutdown("device removed")
+		}
+	}
+}
+
+// DisconnectSession closes the connections opened from one login session
+func (h *Hub) DisconnectSession(userID, sessionID string) {
+	h.userLock.RLock()
+	defer h.userLock.RUnlock()
+
+	for client := range h.userClients[userID] {
+		if client.sessionID == sessionID {
+			client.shutdown("session revoked")
+		}
+	}
+}
+
+// DisconnectAllSessions closes every connection of a user except those of
+// keepSessionID, which may be empty to close them all
+func (h *Hub) DisconnectAllSessions(userID, keepSessionID string) {
+	h.userLock.RLock()
+	defer h.userLock.RUnlock()
+
+	for client := range h.userClients[userID] {
+		if keepSessionID == "" || client.sessionID != keepSessionID {
+			client.shutdown("session revoked")
+		}
+	}
+}
+
 // BroadcastPresence broadcasts user online/offline status to all connected users
 func (h *Hub) BroadcastPresence(userID string, online bool) {
 	presenceMsg := map[string]interface{}{